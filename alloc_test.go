@@ -0,0 +1,77 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+)
+
+func assertNoAllocs(t *testing.T, name string, f func()) {
+	t.Helper()
+	if allocs := testing.AllocsPerRun(100, f); allocs != 0 {
+		t.Errorf("%s: expected no allocations, got %v per run", name, allocs)
+	}
+}
+
+func TestHotPathAllocs(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	pcmf := make([]float32, FRAME_SIZE)
+	addSineFloat32(pcmf, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	packet := data[:n]
+	out := make([]int16, FRAME_SIZE)
+	outf := make([]float32, FRAME_SIZE)
+
+	assertNoAllocs(t, "Encode", func() { enc.Encode(pcm, data) })
+	assertNoAllocs(t, "EncodeFloat32", func() { enc.EncodeFloat32(pcmf, data) })
+	assertNoAllocs(t, "Decode", func() { dec.Decode(packet, out) })
+	assertNoAllocs(t, "DecodeFloat32", func() { dec.DecodeFloat32(packet, outf) })
+	assertNoAllocs(t, "DecodeFEC", func() { dec.DecodeFEC(packet, out) })
+	assertNoAllocs(t, "DecodePLC", func() { dec.DecodePLC(out) })
+	assertNoAllocs(t, "SetBitrate", func() { enc.SetBitrate(24000) })
+	assertNoAllocs(t, "Bitrate", func() { enc.Bitrate() })
+	assertNoAllocs(t, "LastPacketDuration", func() { dec.LastPacketDuration() })
+	assertNoAllocs(t, "Encoder.FinalRange", func() { enc.FinalRange() })
+	assertNoAllocs(t, "Decoder.FinalRange", func() { dec.FinalRange() })
+}
+
+func TestErrorPathAllocs(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(48000, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	out := make([]int16, 960)
+	// Code 3 packet with zero frames
+	invalid := []byte{0x03, 0x00}
+	if _, err := dec.Decode(invalid, out); err != ErrInvalidPacket {
+		t.Fatalf("Expected ErrInvalidPacket, got %v", err)
+	}
+	assertNoAllocs(t, "libopus error", func() { dec.Decode(invalid, out) })
+	assertNoAllocs(t, "argument error", func() { enc.Encode(nil, nil) })
+	if err := enc.SetBitrate(-5); err != ErrBadArg {
+		t.Fatalf("Expected ErrBadArg, got %v", err)
+	}
+	assertNoAllocs(t, "CTL error", func() { enc.SetBitrate(-5) })
+}
@@ -0,0 +1,75 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+)
+
+// The public API of upstream github.com/hraban/opus, with the exact
+// signatures, so code written against upstream builds unchanged against this
+// package. This fails to compile if any of it is changed.
+var (
+	_ func(int, int, Application) (*Encoder, error)  = NewEncoder
+	_ func(*Encoder, int, int, Application) error    = (*Encoder).Init
+	_ func(*Encoder, []int16, []byte) (int, error)   = (*Encoder).Encode
+	_ func(*Encoder, []float32, []byte) (int, error) = (*Encoder).EncodeFloat32
+	_ func(*Encoder, bool) error                     = (*Encoder).SetDTX
+	_ func(*Encoder) (bool, error)                   = (*Encoder).DTX
+	_ func(*Encoder) (int, error)                    = (*Encoder).SampleRate
+	_ func(*Encoder, int) error                      = (*Encoder).SetBitrate
+	_ func(*Encoder) error                           = (*Encoder).SetBitrateToAuto
+	_ func(*Encoder) error                           = (*Encoder).SetBitrateToMax
+	_ func(*Encoder) (int, error)                    = (*Encoder).Bitrate
+	_ func(*Encoder, int) error                      = (*Encoder).SetComplexity
+	_ func(*Encoder) (int, error)                    = (*Encoder).Complexity
+	_ func(*Encoder, Bandwidth) error                = (*Encoder).SetMaxBandwidth
+	_ func(*Encoder) (Bandwidth, error)              = (*Encoder).MaxBandwidth
+	_ func(*Encoder, bool) error                     = (*Encoder).SetInBandFEC
+	_ func(*Encoder) (bool, error)                   = (*Encoder).InBandFEC
+	_ func(*Encoder, int) error                      = (*Encoder).SetPacketLossPerc
+	_ func(*Encoder) (int, error)                    = (*Encoder).PacketLossPerc
+
+	_ func(int, int) (*Decoder, error)               = NewDecoder
+	_ func(*Decoder, int, int) error                 = (*Decoder).Init
+	_ func(*Decoder, []byte, []int16) (int, error)   = (*Decoder).Decode
+	_ func(*Decoder, []byte, []float32) (int, error) = (*Decoder).DecodeFloat32
+	_ func(*Decoder, []byte, []int16) error          = (*Decoder).DecodeFEC
+	_ func(*Decoder, []byte, []float32) error        = (*Decoder).DecodeFECFloat32
+	_ func(*Decoder, []int16) error                  = (*Decoder).DecodePLC
+	_ func(*Decoder, []float32) error                = (*Decoder).DecodePLCFloat32
+	_ func(*Decoder) (int, error)                    = (*Decoder).LastPacketDuration
+
+	_ func() string = Version
+)
+
+func TestAPIParityConstants(t *testing.T) {
+	errs := []Error{
+		ErrOK,
+		ErrBadArg,
+		ErrBufferTooSmall,
+		ErrInternalError,
+		ErrInvalidPacket,
+		ErrUnimplemented,
+		ErrInvalidState,
+		ErrAllocFail,
+	}
+	// Same values as the libopus error codes
+	for i, err := range errs {
+		if int(err) != -i {
+			t.Errorf("Expected %v to be %d, got %d", err, -i, int(err))
+		}
+	}
+	for _, bw := range []Bandwidth{Narrowband, Mediumband, Wideband, SuperWideband, Fullband} {
+		if bw.String() == "" {
+			t.Errorf("Missing name for bandwidth %d", int(bw))
+		}
+	}
+	for _, app := range []Application{AppVoIP, AppAudio, AppRestrictedLowdelay} {
+		if _, err := app.MarshalText(); err != nil {
+			t.Errorf("Unknown application %d", int(app))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// BitrateRamp moves the bitrate of an encoder gradually from one value to
+// another, e.g. from 16 to 64 kbit/s over the first two seconds of a call.
+// Abrupt bitrate changes cause an audible jump in quality; ramping spreads the
+// change out over many frames instead.
+//
+// Call Step once per frame, before encoding it.
+type BitrateRamp struct {
+	enc      *Encoder
+	from     int
+	to       int
+	duration time.Duration
+	elapsed  time.Duration
+}
+
+// NewBitrateRamp creates a ramp from bitrate from to bitrate to (both in
+// bits/s) over the given duration, and immediately sets the encoder to the
+// starting bitrate.
+func NewBitrateRamp(enc *Encoder, from, to int, duration time.Duration) (*BitrateRamp, error) {
+	if enc == nil {
+		return nil, fmt.Errorf("opus: nil encoder")
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("opus: ramp duration must be positive: %v", duration)
+	}
+	if err := enc.SetBitrate(from); err != nil {
+		return nil, err
+	}
+	return &BitrateRamp{
+		enc:      enc,
+		from:     from,
+		to:       to,
+		duration: duration,
+	}, nil
+}
+
+// Bitrate returns the bitrate for the current position on the ramp.
+func (r *BitrateRamp) Bitrate() int {
+	if r.elapsed >= r.duration {
+		return r.to
+	}
+	delta := int64(r.to-r.from) * int64(r.elapsed) / int64(r.duration)
+	return r.from + int(delta)
+}
+
+// Step advances the ramp by one frame of the given duration and applies the
+// resulting bitrate to the encoder. Once the ramp is done, Step is a no-op.
+func (r *BitrateRamp) Step(frame time.Duration) error {
+	if r.Done() {
+		return nil
+	}
+	r.elapsed += frame
+	return r.enc.SetBitrate(r.Bitrate())
+}
+
+// Done reports whether the ramp has reached its target bitrate.
+func (r *BitrateRamp) Done() bool {
+	return r.elapsed >= r.duration
+}
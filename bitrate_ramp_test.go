@@ -0,0 +1,61 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBitrateRamp(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	ramp, err := NewBitrateRamp(enc, 16000, 64000, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error creating bitrate ramp: %v", err)
+	}
+	br, err := enc.Bitrate()
+	if err != nil {
+		t.Fatalf("Error getting bitrate: %v", err)
+	}
+	if br != 16000 {
+		t.Errorf("Unexpected starting bitrate. Got %d, but expected %d", br, 16000)
+	}
+	prev := br
+	for i := 0; i < 5; i++ {
+		if err := ramp.Step(20 * time.Millisecond); err != nil {
+			t.Fatalf("Error stepping ramp: %v", err)
+		}
+		br, err := enc.Bitrate()
+		if err != nil {
+			t.Fatalf("Error getting bitrate: %v", err)
+		}
+		if br <= prev {
+			t.Errorf("Bitrate not increasing at step %d: %d after %d", i, br, prev)
+		}
+		prev = br
+	}
+	if !ramp.Done() {
+		t.Errorf("Expected ramp to be done")
+	}
+	if prev != 64000 {
+		t.Errorf("Unexpected final bitrate. Got %d, but expected %d", prev, 64000)
+	}
+}
+
+func TestBitrateRampInvalid(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if _, err := NewBitrateRamp(enc, 16000, 64000, 0); err == nil {
+		t.Errorf("Expected error for zero ramp duration")
+	}
+	if _, err := NewBitrateRamp(enc, -5, 64000, time.Second); err == nil {
+		t.Errorf("Expected error for invalid starting bitrate")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+)
+
+// SetChannelMap rearranges the channels of the decoded output: output channel
+// i is decoded channel m[i]. E.g. {1, 0} swaps left and right, and {0} keeps
+// only the left channel of a stereo stream. Decode and the other decode
+// functions then write len(m) channels per sample, at the start of pcm. The
+// buffer must still have room for all decoded channels, and the returned
+// sample counts stay per channel. A nil map restores the normal output.
+//
+// The mapping is applied in place right after decoding, saving downstream
+// code a pass over the audio.
+func (dec *Decoder) SetChannelMap(m []int) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	if m == nil {
+		dec.channelMap = nil
+		return nil
+	}
+	if dec.mono || dec.stereo {
+		return errOutputMode
+	}
+	if len(m) == 0 || len(m) > dec.channels {
+		return fmt.Errorf("opus: channel map must have 1 to %d channels, got %d", dec.channels, len(m))
+	}
+	for _, c := range m {
+		if c < 0 || c >= dec.channels {
+			return fmt.Errorf("opus: channel map refers to channel %d of %d", c, dec.channels)
+		}
+	}
+	dec.channelMap = append([]int(nil), m...)
+	return nil
+}
+
+var errOutputMode = fmt.Errorf("opus: only one of channel map, mono output and stereo output can be set")
+
+// SetMonoOutput makes the decoder always write mono, averaging the channels
+// of a stereo stream. Averaging, rather than summing, keeps a full scale
+// signal from clipping. Like with SetChannelMap, the buffer must still have
+// room for all decoded channels. Has no effect on a mono decoder.
+func (dec *Decoder) SetMonoOutput(enable bool) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	if enable && (dec.channelMap != nil || dec.stereo) {
+		return errOutputMode
+	}
+	dec.mono = enable
+	return nil
+}
+
+// SetStereoOutput makes a mono decoder write stereo, with the same audio on
+// both channels, for playback devices which only take stereo. The buffer must
+// have room for two channels, and the returned sample counts stay per
+// channel. Has no effect on a stereo decoder.
+func (dec *Decoder) SetStereoOutput(enable bool) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	if enable && (dec.channelMap != nil || dec.mono) {
+		return errOutputMode
+	}
+	dec.stereo = enable
+	return nil
+}
+
+// ChannelMap returns the channel map set with SetChannelMap, nil if none.
+func (dec *Decoder) ChannelMap() []int {
+	if dec.channelMap == nil {
+		return nil
+	}
+	return append([]int(nil), dec.channelMap...)
+}
+
+// outChannels returns the number of channels in the decoded output.
+func (dec *Decoder) outChannels() int {
+	if dec.mono {
+		return 1
+	}
+	if dec.stereo {
+		return 2
+	}
+	if dec.channelMap != nil {
+		return len(dec.channelMap)
+	}
+	return dec.channels
+}
+
+// bufChannels returns the number of channels output buffers must have room
+// for: the decoded ones, or more when upmixing.
+func (dec *Decoder) bufChannels() int {
+	if dec.stereo && dec.channels == 1 {
+		return 2
+	}
+	return dec.channels
+}
+
+// remap applies the channel map, downmix or upmix to n decoded samples per
+// channel, in place. Except for the upmix, output samples are never written
+// beyond the input sample they come from. Decoding fills the buffer up to its
+// capacity, not its length, so that is what is remapped.
+func (dec *Decoder) remap(pcm []int16, n int) {
+	pcm = pcm[:cap(pcm)]
+	if dec.mono {
+		downmix(pcm, n, dec.channels)
+		return
+	}
+	if dec.stereo && dec.channels == 1 {
+		upmix(pcm, n)
+		return
+	}
+	m := dec.channelMap
+	if m == nil {
+		return
+	}
+	var row [2]int16
+	for i := 0; i < n; i++ {
+		copy(row[:], pcm[i*dec.channels:(i+1)*dec.channels])
+		for j, c := range m {
+			pcm[i*len(m)+j] = row[c]
+		}
+	}
+}
+
+func (dec *Decoder) remapFloat32(pcm []float32, n int) {
+	pcm = pcm[:cap(pcm)]
+	if dec.mono {
+		downmixFloat32(pcm, n, dec.channels)
+		return
+	}
+	if dec.stereo && dec.channels == 1 {
+		upmixFloat32(pcm, n)
+		return
+	}
+	m := dec.channelMap
+	if m == nil {
+		return
+	}
+	var row [2]float32
+	for i := 0; i < n; i++ {
+		copy(row[:], pcm[i*dec.channels:(i+1)*dec.channels])
+		for j, c := range m {
+			pcm[i*len(m)+j] = row[c]
+		}
+	}
+}
+
+// downmix averages the channels of n interleaved samples per channel into
+// mono, in place. Like remap, it works up to the capacity of pcm.
+func downmix(pcm []int16, n, channels int) {
+	if channels == 1 {
+		return
+	}
+	pcm = pcm[:cap(pcm)]
+	for i := 0; i < n; i++ {
+		var sum int
+		for _, s := range pcm[i*channels : (i+1)*channels] {
+			sum += int(s)
+		}
+		pcm[i] = int16(sum / channels)
+	}
+}
+
+func downmixFloat32(pcm []float32, n, channels int) {
+	if channels == 1 {
+		return
+	}
+	pcm = pcm[:cap(pcm)]
+	for i := 0; i < n; i++ {
+		var sum float32
+		for _, s := range pcm[i*channels : (i+1)*channels] {
+			sum += s
+		}
+		pcm[i] = sum / float32(channels)
+	}
+}
+
+// upmix duplicates n mono samples into interleaved stereo, in place. pcm must
+// have capacity for 2n samples; its length doesn't matter.
+func upmix(pcm []int16, n int) {
+	pcm = pcm[:cap(pcm)]
+	for i := n - 1; i >= 0; i-- {
+		pcm[2*i], pcm[2*i+1] = pcm[i], pcm[i]
+	}
+}
+
+func upmixFloat32(pcm []float32, n int) {
+	pcm = pcm[:cap(pcm)]
+	for i := n - 1; i >= 0; i-- {
+		pcm[2*i], pcm[2*i+1] = pcm[i], pcm[i]
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelMap(t *testing.T) {
+	const G4 = 391.995
+	const E3 = 164.814
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 2, AppAudio)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	// Different tones left and right
+	left := make([]int16, FRAME_SIZE)
+	right := make([]int16, FRAME_SIZE)
+	addSine(left, SAMPLE_RATE, G4)
+	addSine(right, SAMPLE_RATE, E3)
+	pcm := make([]int16, 2*FRAME_SIZE)
+	for i := range left {
+		pcm[2*i] = left[i] / 2
+		pcm[2*i+1] = right[i] / 2
+	}
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	data = data[:n]
+
+	decode := func(m []int) []int16 {
+		dec, err := NewDecoder(SAMPLE_RATE, 2)
+		if err != nil {
+			t.Fatalf("Error creating new decoder: %v", err)
+		}
+		if err := dec.SetChannelMap(m); err != nil {
+			t.Fatalf("Error setting channel map %v: %v", m, err)
+		}
+		out := make([]int16, 2*FRAME_SIZE)
+		n, err := dec.Decode(data, out)
+		if err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		k := 2
+		if m != nil {
+			k = len(m)
+		}
+		return out[:n*k]
+	}
+	normal := decode(nil)
+	channel := func(c int) []int16 {
+		out := make([]int16, FRAME_SIZE)
+		for i := range out {
+			out[i] = normal[2*i+c]
+		}
+		return out
+	}
+	if got := decode([]int{0}); !reflect.DeepEqual(got, channel(0)) {
+		t.Errorf("Expected left channel only")
+	}
+	if got := decode([]int{1}); !reflect.DeepEqual(got, channel(1)) {
+		t.Errorf("Expected right channel only")
+	}
+	swapped := decode([]int{1, 0})
+	for i := 0; i < FRAME_SIZE; i++ {
+		if swapped[2*i] != normal[2*i+1] || swapped[2*i+1] != normal[2*i] {
+			t.Fatalf("Expected channels swapped at sample %d", i)
+		}
+	}
+
+	dec, err := NewDecoder(SAMPLE_RATE, 2)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	// Decoding fills the buffer up to its capacity, whatever its length
+	if err := dec.SetChannelMap([]int{1, 0}); err != nil {
+		t.Fatalf("Error setting channel map: %v", err)
+	}
+	out := make([]int16, 1, 2*FRAME_SIZE)
+	if _, err := dec.Decode(data, out); err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	if !reflect.DeepEqual(out[:2*FRAME_SIZE], swapped) {
+		t.Errorf("Expected channels swapped beyond the length of the buffer")
+	}
+	for _, m := range [][]int{{}, {2}, {0, 1, 0}, {-1}} {
+		if err := dec.SetChannelMap(m); err == nil {
+			t.Errorf("Expected error for channel map %v", m)
+		}
+	}
+}
+
+func TestMonoOutput(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 2, AppAudio)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]float32, 2*FRAME_SIZE)
+	addSineFloat32(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.EncodeFloat32(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	data = data[:n]
+
+	ref, err := NewDecoder(SAMPLE_RATE, 2)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	stereo := make([]float32, 2*FRAME_SIZE)
+	if _, err := ref.DecodeFloat32(data, stereo); err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 2)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetMonoOutput(true); err != nil {
+		t.Fatalf("Error enabling mono output: %v", err)
+	}
+	mono := make([]float32, 2*FRAME_SIZE)
+	n, err = dec.DecodeFloat32(data, mono)
+	if err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		if expected := (stereo[2*i] + stereo[2*i+1]) / 2; mono[i] != expected {
+			t.Fatalf("Sample %d is %f, expected the average %f", i, mono[i], expected)
+		}
+	}
+	if err := dec.SetChannelMap([]int{1}); err == nil {
+		t.Errorf("Expected error combining mono output with a channel map")
+	}
+}
+
+func TestStereoOutput(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	data = data[:n]
+
+	ref, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	mono := make([]int16, FRAME_SIZE)
+	if _, err := ref.Decode(data, mono); err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetStereoOutput(true); err != nil {
+		t.Fatalf("Error enabling stereo output: %v", err)
+	}
+	// Room for the mono frame only is not enough
+	if _, err := dec.Decode(data, make([]int16, FRAME_SIZE)); err == nil {
+		t.Errorf("Expected error for a buffer without room for stereo")
+	}
+	stereo := make([]int16, 2*FRAME_SIZE)
+	n, err = dec.Decode(data, stereo)
+	if err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	if n != FRAME_SIZE {
+		t.Fatalf("Expected %d samples per channel, got %d", FRAME_SIZE, n)
+	}
+	for i, s := range mono {
+		if stereo[2*i] != s || stereo[2*i+1] != s {
+			t.Fatalf("Sample %d is %d/%d, expected %d on both channels", i, stereo[2*i], stereo[2*i+1], s)
+		}
+	}
+	// Only the capacity of the buffer counts, not its length
+	dec2, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec2.SetStereoOutput(true); err != nil {
+		t.Fatalf("Error enabling stereo output: %v", err)
+	}
+	short := make([]int16, 1, 2*FRAME_SIZE)
+	if _, err := dec2.Decode(data, short); err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	if !reflect.DeepEqual(short[:2*FRAME_SIZE], stereo) {
+		t.Errorf("Expected upmix beyond the length of the buffer")
+	}
+	if err := dec.SetMonoOutput(true); err == nil {
+		t.Errorf("Expected error combining mono and stereo output")
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Finding is a single problem found by CheckStream.
+type Finding struct {
+	// Byte offset of the page in the input
+	Offset int64 `json:"offset"`
+	// Index of the page in the input, counting from 0
+	Page int `json:"page"`
+	// Serial number of the logical stream the page belongs to
+	Serial uint32 `json:"serial"`
+	// Machine readable category, one of the Finding* constants
+	Kind string `json:"kind"`
+	// Human readable description
+	Message string `json:"message"`
+}
+
+// Kinds of findings reported by CheckStream.
+const (
+	// Bytes between pages which are not part of any page
+	FindingGarbage = "garbage"
+	// The input ends in the middle of a page
+	FindingTruncated = "truncated"
+	// Page checksum mismatch
+	FindingCRC = "crc"
+	// Unsupported Ogg version or invalid page header
+	FindingPageHeader = "page-header"
+	// Missing, duplicate or out of order pages (page sequence numbers)
+	FindingSequence = "sequence"
+	// Granule position jumps ahead of the audio actually present
+	FindingGranuleHole = "granule-hole"
+	// Granule position lower than the audio already present
+	FindingGranuleBackwards = "granule-backwards"
+	// Invalid or misplaced OpusHead or OpusTags header
+	FindingHeader = "header"
+	// Channel count and channel mapping family don't fit together
+	FindingChannelMapping = "channel-mapping"
+	// Audio packet which isn't a valid Opus packet
+	FindingPacket = "packet"
+	// Missing or misplaced beginning/end of stream flags
+	FindingStreamFlags = "stream-flags"
+)
+
+const (
+	oggHeaderSize    = 27
+	oggFlagContinued = 0x01
+	oggFlagBOS       = 0x02
+	oggFlagEOS       = 0x04
+	oggNoGranule     = -1
+)
+
+var oggCRCTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return
+}()
+
+func oggCRC(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+type checkState int
+
+const (
+	expectHead checkState = iota
+	expectTags
+	expectAudio
+	notOpus
+)
+
+type logicalStream struct {
+	state   checkState
+	seq     uint32
+	granule int64
+	// Whether a granule position was seen on an audio page yet
+	started bool
+	// Audio samples of packets completed since the last granule position
+	pending int64
+	packet  []byte
+	eos     bool
+}
+
+type checker struct {
+	r        *bufio.Reader
+	offset   int64
+	page     int
+	streams  map[uint32]*logicalStream
+	findings []Finding
+}
+
+func (c *checker) report(offset int64, serial uint32, kind string, format string, args ...interface{}) {
+	c.findings = append(c.findings, Finding{
+		Offset:  offset,
+		Page:    c.page,
+		Serial:  serial,
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// CheckStream scans an Ogg Opus stream for problems: damaged or missing
+// pages, holes in the audio (granule position discontinuities), invalid
+// headers and channel mappings, and other violations of RFC 7845. It doesn't
+// decode any audio, so it's fast, and it doesn't need libopusfile.
+//
+// Problems in the stream are reported as findings; the error is only set if
+// reading from r fails. Logical streams which don't contain Opus (e.g. Ogg
+// Skeleton) are skipped.
+func CheckStream(r io.Reader) ([]Finding, error) {
+	c := &checker{
+		r:       bufio.NewReader(r),
+		streams: map[uint32]*logicalStream{},
+	}
+	for {
+		ok, err := c.nextPage()
+		if err != nil {
+			return c.findings, err
+		}
+		if !ok {
+			break
+		}
+		c.page++
+	}
+	for serial, s := range c.streams {
+		if s.state == notOpus {
+			continue
+		}
+		if s.state != expectAudio {
+			c.report(c.offset, serial, FindingHeader, "stream ends before its headers are complete")
+		}
+		if !s.eos {
+			c.report(c.offset, serial, FindingStreamFlags, "stream has no end of stream page")
+		}
+	}
+	return c.findings, nil
+}
+
+// sync skips to the next capture pattern. Returns false at the end of the
+// input.
+func (c *checker) sync() (bool, error) {
+	start := c.offset
+	for {
+		buf, err := c.r.Peek(4)
+		if len(buf) < 4 {
+			if len(buf) > 0 || c.offset > start {
+				c.report(start, 0, FindingTruncated, "%d trailing bytes after the last page", c.offset-start+int64(len(buf)))
+			}
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+		if string(buf) == "OggS" {
+			if c.offset > start {
+				c.report(start, 0, FindingGarbage, "%d bytes of garbage before page", c.offset-start)
+			}
+			return true, nil
+		}
+		c.r.Discard(1)
+		c.offset++
+	}
+}
+
+func (c *checker) readFull(buf []byte) (bool, error) {
+	n, err := io.ReadFull(c.r, buf)
+	c.offset += int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *checker) nextPage() (bool, error) {
+	ok, err := c.sync()
+	if !ok || err != nil {
+		return false, err
+	}
+	start := c.offset
+	header := make([]byte, oggHeaderSize)
+	if ok, err := c.readFull(header); !ok {
+		if err == nil {
+			c.report(start, 0, FindingTruncated, "input ends inside page header")
+		}
+		return false, err
+	}
+	nsegs := int(header[26])
+	lacing := make([]byte, nsegs)
+	if ok, err := c.readFull(lacing); !ok {
+		if err == nil {
+			c.report(start, 0, FindingTruncated, "input ends inside page header")
+		}
+		return false, err
+	}
+	size := 0
+	for _, l := range lacing {
+		size += int(l)
+	}
+	body := make([]byte, size)
+	if ok, err := c.readFull(body); !ok {
+		if err == nil {
+			c.report(start, binary.LittleEndian.Uint32(header[14:]), FindingTruncated, "input ends inside page body")
+		}
+		return false, err
+	}
+
+	serial := binary.LittleEndian.Uint32(header[14:])
+	if header[4] != 0 {
+		c.report(start, serial, FindingPageHeader, "unsupported Ogg version %d", header[4])
+		return true, nil
+	}
+	flags := header[5]
+	granule := int64(binary.LittleEndian.Uint64(header[6:]))
+	seq := binary.LittleEndian.Uint32(header[18:])
+	crc := binary.LittleEndian.Uint32(header[22:])
+	for i := 22; i < 26; i++ {
+		header[i] = 0
+	}
+	computed := oggCRC(oggCRC(oggCRC(0, header), lacing), body)
+	if computed != crc {
+		c.report(start, serial, FindingCRC, "page checksum %08x, expected %08x", crc, computed)
+		// Nothing on this page can be trusted
+		return true, nil
+	}
+
+	s, known := c.streams[serial]
+	if !known {
+		s = &logicalStream{seq: seq}
+		c.streams[serial] = s
+		if flags&oggFlagBOS == 0 {
+			c.report(start, serial, FindingStreamFlags, "first page of stream lacks beginning of stream flag")
+		}
+	} else {
+		if flags&oggFlagBOS != 0 {
+			c.report(start, serial, FindingStreamFlags, "beginning of stream flag on later page")
+		}
+		if s.eos {
+			c.report(start, serial, FindingStreamFlags, "page after end of stream")
+		}
+		if seq != s.seq {
+			c.report(start, serial, FindingSequence, "page sequence number %d, expected %d", seq, s.seq)
+			// Whatever was being assembled is lost
+			s.packet = nil
+			flags &^= oggFlagContinued
+		}
+	}
+	s.seq = seq + 1
+	if flags&oggFlagEOS != 0 {
+		s.eos = true
+	}
+	if s.state == notOpus {
+		return true, nil
+	}
+
+	// Split the body into packets
+	var packets [][]byte
+	if flags&oggFlagContinued == 0 && len(s.packet) > 0 {
+		c.report(start, serial, FindingPacket, "packet continued from previous page, but page isn't marked as continuation")
+		s.packet = nil
+	}
+	pos := 0
+	for _, l := range lacing {
+		s.packet = append(s.packet, body[pos:pos+int(l)]...)
+		pos += int(l)
+		if l < 255 {
+			packets = append(packets, s.packet)
+			s.packet = nil
+		}
+	}
+
+	headersOnPage := false
+	for i, p := range packets {
+		switch s.state {
+		case expectHead:
+			if !bytes.HasPrefix(p, []byte("OpusHead")) {
+				if !known {
+					// Some other codec, e.g. Ogg Skeleton
+					s.state = notOpus
+					return true, nil
+				}
+				c.report(start, serial, FindingHeader, "expected OpusHead packet")
+				continue
+			}
+			if len(packets) != 1 || len(s.packet) != 0 || flags&oggFlagBOS == 0 {
+				c.report(start, serial, FindingHeader, "OpusHead must be alone on the first page of the stream")
+			}
+			c.checkHead(start, serial, p)
+			s.state = expectTags
+			headersOnPage = true
+		case expectTags:
+			if !bytes.HasPrefix(p, []byte("OpusTags")) {
+				c.report(start, serial, FindingHeader, "expected OpusTags packet")
+			}
+			s.state = expectAudio
+			if i != len(packets)-1 || len(s.packet) != 0 {
+				c.report(start, serial, FindingHeader, "OpusTags must finish its page")
+			}
+			headersOnPage = true
+		case expectAudio:
+			n, err := packetSamples48k(p)
+			if err != nil {
+				c.report(start, serial, FindingPacket, "invalid Opus packet: %v", err)
+				continue
+			}
+			s.pending += int64(n)
+		}
+	}
+
+	if headersOnPage {
+		if granule != 0 {
+			c.report(start, serial, FindingHeader, "header page has granule position %d, expected 0", granule)
+		}
+		return true, nil
+	}
+	if s.state != expectAudio || granule == oggNoGranule {
+		return true, nil
+	}
+	expected := s.granule + s.pending
+	switch {
+	case !s.started:
+		// The first audio page may start at a later position, e.g. when
+		// recording started in the middle of a live stream.
+		if granule < s.pending && flags&oggFlagEOS == 0 {
+			c.report(start, serial, FindingGranuleBackwards, "granule position %d is less than the %d samples on the first audio page", granule, s.pending)
+		}
+		s.started = true
+	case granule > expected:
+		c.report(start, serial, FindingGranuleHole, "granule position %d, expected %d: %d samples missing", granule, expected, granule-expected)
+	case granule < expected && flags&oggFlagEOS == 0:
+		// Only the last page may trim samples off the end
+		c.report(start, serial, FindingGranuleBackwards, "granule position %d, expected %d", granule, expected)
+	}
+	s.granule = granule
+	s.pending = 0
+	return true, nil
+}
+
+// checkHead validates an OpusHead packet, RFC 7845 section 5.1.
+func (c *checker) checkHead(offset int64, serial uint32, p []byte) {
+	if len(p) < 19 {
+		c.report(offset, serial, FindingHeader, "OpusHead too short: %d bytes", len(p))
+		return
+	}
+	if version := p[8]; version>>4 != 0 {
+		c.report(offset, serial, FindingHeader, "unsupported OpusHead version %d", version)
+		return
+	}
+	channels := int(p[9])
+	if channels == 0 {
+		c.report(offset, serial, FindingChannelMapping, "channel count is 0")
+		return
+	}
+	family := p[18]
+	switch family {
+	case 0:
+		if channels > 2 {
+			c.report(offset, serial, FindingChannelMapping, "mapping family 0 allows 1 or 2 channels, got %d", channels)
+		}
+		if len(p) != 19 {
+			c.report(offset, serial, FindingHeader, "OpusHead with mapping family 0 has %d trailing bytes", len(p)-19)
+		}
+		return
+	case 1:
+		if channels > 8 {
+			c.report(offset, serial, FindingChannelMapping, "mapping family 1 allows at most 8 channels, got %d", channels)
+		}
+	}
+	if len(p) < 21+channels {
+		c.report(offset, serial, FindingHeader, "OpusHead too short for channel mapping table: %d bytes", len(p))
+		return
+	}
+	streams := int(p[19])
+	coupled := int(p[20])
+	if streams == 0 {
+		c.report(offset, serial, FindingChannelMapping, "stream count is 0")
+	}
+	if coupled > streams {
+		c.report(offset, serial, FindingChannelMapping, "%d coupled streams, but only %d streams", coupled, streams)
+	}
+	for i, m := range p[21 : 21+channels] {
+		if m != 255 && int(m) >= streams+coupled {
+			c.report(offset, serial, FindingChannelMapping, "channel %d maps to decoded channel %d, but there are only %d", i, m, streams+coupled)
+		}
+	}
+}
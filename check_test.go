@@ -0,0 +1,140 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+// oggPages splits a (well formed) Ogg stream into its pages.
+func oggPages(t *testing.T, data []byte) [][]byte {
+	var pages [][]byte
+	for len(data) > 0 {
+		nsegs := int(data[26])
+		size := oggHeaderSize + nsegs
+		for _, l := range data[oggHeaderSize : oggHeaderSize+nsegs] {
+			size += int(l)
+		}
+		pages = append(pages, data[:size:size])
+		data = data[size:]
+	}
+	return pages
+}
+
+// fixCRC recomputes the checksum of a page after modification.
+func fixCRC(page []byte) {
+	binary.LittleEndian.PutUint32(page[22:], 0)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(0, page))
+}
+
+func readSpeech(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("testdata/speech_8.opus")
+	if err != nil {
+		t.Fatalf("Error opening test file: %v", err)
+	}
+	return data
+}
+
+func checkKinds(t *testing.T, data []byte) map[string]int {
+	findings, err := CheckStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error checking stream: %v", err)
+	}
+	kinds := map[string]int{}
+	for _, f := range findings {
+		kinds[f.Kind]++
+	}
+	return kinds
+}
+
+func TestCheckStreamClean(t *testing.T) {
+	data := readSpeech(t)
+	findings, err := CheckStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error checking stream: %v", err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("Expected no findings in valid file, got: %+v", findings)
+	}
+}
+
+func TestCheckStreamCRC(t *testing.T) {
+	data := readSpeech(t)
+	pages := oggPages(t, data)
+	if len(pages) < 4 {
+		t.Fatalf("Test file too short: %d pages", len(pages))
+	}
+	last := pages[2]
+	last[len(last)-1] ^= 0xff
+	kinds := checkKinds(t, data)
+	if kinds[FindingCRC] != 1 {
+		t.Errorf("Expected 1 checksum finding, got: %v", kinds)
+	}
+}
+
+func TestCheckStreamMissingPage(t *testing.T) {
+	pages := oggPages(t, readSpeech(t))
+	if len(pages) < 5 {
+		t.Skipf("Test file too short: %d pages", len(pages))
+	}
+	var data []byte
+	for i, p := range pages {
+		if i != 3 {
+			data = append(data, p...)
+		}
+	}
+	kinds := checkKinds(t, data)
+	if kinds[FindingSequence] != 1 {
+		t.Errorf("Expected 1 sequence finding, got: %v", kinds)
+	}
+	if kinds[FindingGranuleHole] != 1 {
+		t.Errorf("Expected 1 granule hole finding, got: %v", kinds)
+	}
+}
+
+func TestCheckStreamGarbage(t *testing.T) {
+	pages := oggPages(t, readSpeech(t))
+	var data []byte
+	for i, p := range pages {
+		data = append(data, p...)
+		if i == 1 {
+			data = append(data, "junk"...)
+		}
+	}
+	kinds := checkKinds(t, data)
+	if kinds[FindingGarbage] != 1 || len(kinds) != 1 {
+		t.Errorf("Expected only 1 garbage finding, got: %v", kinds)
+	}
+}
+
+func TestCheckStreamTruncated(t *testing.T) {
+	data := readSpeech(t)
+	kinds := checkKinds(t, data[:len(data)-10])
+	if kinds[FindingTruncated] != 1 {
+		t.Errorf("Expected truncation finding, got: %v", kinds)
+	}
+	if kinds[FindingStreamFlags] != 1 {
+		t.Errorf("Expected missing end of stream finding, got: %v", kinds)
+	}
+}
+
+func TestCheckStreamChannelMapping(t *testing.T) {
+	pages := oggPages(t, readSpeech(t))
+	head := pages[0]
+	// Channel count lives at offset 9 of the OpusHead packet
+	head[oggHeaderSize+int(head[26])+9] = 6
+	fixCRC(head)
+	var data []byte
+	for _, p := range pages {
+		data = append(data, p...)
+	}
+	kinds := checkKinds(t, data)
+	if kinds[FindingChannelMapping] != 1 {
+		t.Errorf("Expected channel mapping finding, got: %v", kinds)
+	}
+}
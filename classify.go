@@ -0,0 +1,231 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// Content is the kind of audio found by a ContentClassifier.
+type Content int
+
+const (
+	// Not enough audio analyzed yet
+	ContentUnknown Content = iota
+	ContentSpeech
+	ContentMusic
+)
+
+var contentNames = map[Content]string{
+	ContentUnknown: "unknown",
+	ContentSpeech:  "speech",
+	ContentMusic:   "music",
+}
+
+func (c Content) String() string {
+	if name, ok := contentNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Content(%d)", int(c))
+}
+
+// Signal returns the encoder signal hint for the content.
+func (c Content) Signal() Signal {
+	switch c {
+	case ContentSpeech:
+		return SignalVoice
+	case ContentMusic:
+		return SignalMusic
+	}
+	return SignalAuto
+}
+
+// Application returns the encoder application best suited to the content.
+func (c Content) Application() Application {
+	if c == ContentSpeech {
+		return AppVoIP
+	}
+	return AppAudio
+}
+
+// Classifier parameters. Speech alternates voiced sounds, unvoiced sounds and
+// short pauses several times per second; music is much steadier. Each of the
+// three features below votes, the majority decides.
+const (
+	classifyFrame  = 20 * time.Millisecond
+	classifyWindow = 50 // frames, 1 s
+	// Fraction of frames with less than half the mean energy (pauses)
+	classifyLowEnergyRatio = 0.15
+	// Fraction of frames with over 1.5 times the mean zero crossing rate
+	// (unvoiced sounds)
+	classifyHighZCRRatio = 0.1
+	// Mean normalized spectral flux (fast changes of timbre)
+	classifyFlux = 0.1
+	// FFT size for the spectral flux
+	classifyFFTSize = 256
+)
+
+// ContentClassifier tells speech from music in a stream of PCM, using the low
+// energy frame ratio, the high zero crossing rate ratio and the spectral flux
+// over the last second. It is cheap enough to run next to an encoder, e.g. to
+// switch its signal hint on a radio stream mixing talk and music.
+type ContentClassifier struct {
+	channels  int
+	frameSize int
+	energies  []float64
+	zcrs      []float64
+	frame     int
+	// Position in the current frame
+	pos        int
+	energy     float64
+	crossings  int
+	prev       float64
+	spectrum   *spectrumTap
+	lastMags   []float64
+	flux       float64
+	fluxFrames int
+	content    Content
+}
+
+// NewContentClassifier creates a classifier for audio of the given format.
+func NewContentClassifier(sample_rate int, channels int) (*ContentClassifier, error) {
+	if err := validateSampleRate(sample_rate); err != nil {
+		return nil, err
+	}
+	if err := validateChannels(channels); err != nil {
+		return nil, err
+	}
+	c := &ContentClassifier{
+		channels:  channels,
+		frameSize: FrameSamples(sample_rate, classifyFrame),
+		energies:  make([]float64, classifyWindow),
+		zcrs:      make([]float64, classifyWindow),
+	}
+	// The tap is fed mono samples
+	spectrum, err := newSpectrumTap(1, classifyFFTSize, classifyFFTSize, c.addSpectrum)
+	if err != nil {
+		return nil, err
+	}
+	c.spectrum = spectrum
+	return c, nil
+}
+
+// Analyze feeds interleaved PCM to the classifier and returns the content of
+// the last second of audio, or ContentUnknown if less than a second has been
+// analyzed.
+func (c *ContentClassifier) Analyze(pcm []int16) Content {
+	for i := 0; i+c.channels <= len(pcm); i += c.channels {
+		var v float64
+		for _, s := range pcm[i : i+c.channels] {
+			v += float64(s)
+		}
+		c.sample(v / 32768 / float64(c.channels))
+	}
+	return c.content
+}
+
+// AnalyzeFloat32 is the same as Analyze, but for float32 audio.
+func (c *ContentClassifier) AnalyzeFloat32(pcm []float32) Content {
+	for i := 0; i+c.channels <= len(pcm); i += c.channels {
+		var v float64
+		for _, s := range pcm[i : i+c.channels] {
+			v += float64(s)
+		}
+		c.sample(v / float64(c.channels))
+	}
+	return c.content
+}
+
+// Content returns the result of the last analysis.
+func (c *ContentClassifier) Content() Content {
+	return c.content
+}
+
+// Apply sets the signal hint of the encoder to the classified content. It
+// does nothing while the content is unknown.
+func (c *ContentClassifier) Apply(enc *Encoder) error {
+	if c.content == ContentUnknown {
+		return nil
+	}
+	return enc.SetSignal(c.content.Signal())
+}
+
+func (c *ContentClassifier) sample(v float64) {
+	c.spectrum.sample(v)
+	c.energy += v * v
+	if c.pos > 0 && (v >= 0) != (c.prev >= 0) {
+		c.crossings++
+	}
+	c.prev = v
+	c.pos++
+	if c.pos < c.frameSize {
+		return
+	}
+	i := c.frame % classifyWindow
+	c.energies[i] = c.energy / float64(c.frameSize)
+	c.zcrs[i] = float64(c.crossings) / float64(c.frameSize)
+	c.energy, c.crossings, c.pos = 0, 0, 0
+	c.frame++
+	if c.frame%classifyWindow == 0 {
+		c.classify()
+	}
+}
+
+func (c *ContentClassifier) addSpectrum(mags []float64) {
+	if c.lastMags != nil {
+		var diff, norm float64
+		for i, m := range mags {
+			d := m - c.lastMags[i]
+			diff += d * d
+			norm += m*m + c.lastMags[i]*c.lastMags[i]
+		}
+		if norm > 0 {
+			c.flux += diff / norm
+		}
+		c.fluxFrames++
+	}
+	c.lastMags = append(c.lastMags[:0], mags...)
+}
+
+// classify decides on the content of the last window.
+func (c *ContentClassifier) classify() {
+	var meanEnergy, meanZCR float64
+	for i := range c.energies {
+		meanEnergy += c.energies[i]
+		meanZCR += c.zcrs[i]
+	}
+	meanEnergy /= classifyWindow
+	meanZCR /= classifyWindow
+	var lowEnergy, highZCR int
+	for i := range c.energies {
+		if c.energies[i] < meanEnergy/2 {
+			lowEnergy++
+		}
+		if c.zcrs[i] > meanZCR*1.5 {
+			highZCR++
+		}
+	}
+	votes := 0
+	if float64(lowEnergy)/classifyWindow > classifyLowEnergyRatio {
+		votes++
+	}
+	if float64(highZCR)/classifyWindow > classifyHighZCRRatio {
+		votes++
+	}
+	if c.fluxFrames > 0 && c.flux/float64(c.fluxFrames) > classifyFlux {
+		votes++
+	}
+	c.flux, c.fluxFrames = 0, 0
+	if meanEnergy == 0 {
+		// Silence says nothing either way
+		return
+	}
+	if votes >= 2 {
+		c.content = ContentSpeech
+	} else {
+		c.content = ContentMusic
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestContentClassifier(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const SECONDS = 3
+	const PART = SAMPLE_RATE / 10
+	// Speech-like: 100 ms voiced (low sine), 100 ms unvoiced (noise), 100 ms
+	// pause
+	speech := make([]float32, SAMPLE_RATE*SECONDS)
+	rng := rand.New(rand.NewSource(1))
+	for i := range speech {
+		switch i / PART % 3 {
+		case 0:
+			speech[i] = 0.5 * float32(math.Sin(2*math.Pi*200*float64(i)/SAMPLE_RATE))
+		case 1:
+			speech[i] = 0.3 * (2*rng.Float32() - 1)
+		}
+	}
+	// Music-like: a steady chord
+	music := make([]float32, SAMPLE_RATE*SECONDS)
+	for _, f := range []float64{261.63, 329.63, 392.00} {
+		addSineFloat32(music, SAMPLE_RATE, f)
+	}
+	for i := range music {
+		music[i] /= 4
+	}
+
+	cases := []struct {
+		pcm  []float32
+		want Content
+	}{
+		{speech, ContentSpeech},
+		{music, ContentMusic},
+	}
+	for _, c := range cases {
+		cl, err := NewContentClassifier(SAMPLE_RATE, 1)
+		if err != nil {
+			t.Fatalf("Error creating classifier: %v", err)
+		}
+		if got := cl.AnalyzeFloat32(c.pcm[:SAMPLE_RATE/2]); got != ContentUnknown {
+			t.Errorf("Expected unknown content after half a second, got %v", got)
+		}
+		if got := cl.AnalyzeFloat32(c.pcm[SAMPLE_RATE/2:]); got != c.want {
+			t.Errorf("Expected %v, got %v", c.want, got)
+		}
+	}
+	if ContentSpeech.Signal() != SignalVoice || ContentMusic.Application() != AppAudio {
+		t.Errorf("Wrong encoder settings for content")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// Command opusbench sweeps encoder settings over one or more 16 bit PCM .wav
+// files using opus.Sweep, and reports encode and decode speed, the resulting
+// bitrate, and the segmental SNR of the decoded audio for each combination.
+// Use it to find the cheapest settings that are good enough for your content.
+//
+// Usage:
+//
+//	opusbench [-bitrates 16000,32000] [-complexities 5,10] [-frames 20ms] [-app audio] [-csv] file.wav ...
+//
+// Speeds are given as multiples of real time. Segmental SNR is a crude,
+// non-perceptual measure: use it to compare settings against each other, not
+// as an absolute judgement of quality.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hraban/opus/v2"
+)
+
+func parseInts(s string) ([]int, error) {
+	var res []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func parseDurations(s string) ([]time.Duration, error) {
+	var res []time.Duration
+	for _, f := range strings.Split(s, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+func main() {
+	bitratesFlag := flag.String("bitrates", "12000,24000,48000,96000", "comma separated bitrates to try")
+	complexitiesFlag := flag.String("complexities", "0,5,10", "comma separated complexities to try")
+	framesFlag := flag.String("frames", "20ms", "comma separated frame durations to try")
+	appFlag := flag.String("app", "audio", "application: voip, audio or restricted-lowdelay")
+	csvFlag := flag.Bool("csv", false, "output CSV instead of a table")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: opusbench [flags] file.wav ...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	bitrates, err := parseInts(*bitratesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "opusbench: invalid bitrates: %v\n", err)
+		os.Exit(2)
+	}
+	complexities, err := parseInts(*complexitiesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "opusbench: invalid complexities: %v\n", err)
+		os.Exit(2)
+	}
+	frames, err := parseDurations(*framesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "opusbench: invalid frame durations: %v\n", err)
+		os.Exit(2)
+	}
+	var app opus.Application
+	if err := app.UnmarshalText([]byte(*appFlag)); err != nil {
+		fmt.Fprintf(os.Stderr, "opusbench: %v\n", err)
+		os.Exit(2)
+	}
+
+	type fileResult struct {
+		file string
+		opus.SweepResult
+	}
+	var results []fileResult
+	opts := opus.SweepOptions{
+		Application:    app,
+		Bitrates:       bitrates,
+		Complexities:   complexities,
+		FrameDurations: frames,
+	}
+	for _, name := range flag.Args() {
+		pcm, sampleRate, channels, err := readWAV(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "opusbench: %v\n", err)
+			os.Exit(1)
+		}
+		rs, err := opus.Sweep(pcm, sampleRate, channels, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "opusbench: %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		for _, r := range rs {
+			results = append(results, fileResult{name, r})
+		}
+	}
+
+	header := []string{"file", "bitrate", "complexity", "frame", "encode_x", "decode_x", "out_bitrate", "segsnr_db"}
+	rows := make([][]string, len(results))
+	for i, r := range results {
+		rows[i] = []string{
+			r.file,
+			strconv.Itoa(r.Bitrate),
+			strconv.Itoa(r.Complexity),
+			r.FrameDuration.String(),
+			strconv.FormatFloat(r.EncodeSpeed, 'f', 1, 64),
+			strconv.FormatFloat(r.DecodeSpeed, 'f', 1, 64),
+			strconv.FormatFloat(r.OutBitrate, 'f', 0, 64),
+			strconv.FormatFloat(r.SegmentalSNR, 'f', 2, 64),
+		}
+	}
+	if *csvFlag {
+		w := csv.NewWriter(os.Stdout)
+		w.Write(header)
+		w.WriteAll(rows)
+		if err := w.Error(); err != nil {
+			fmt.Fprintf(os.Stderr, "opusbench: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+	w.Flush()
+}
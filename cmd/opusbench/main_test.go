@@ -0,0 +1,22 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package main
+
+import (
+	"testing"
+)
+
+func TestReadWAV(t *testing.T) {
+	pcm, sampleRate, channels, err := readWAV("../../testdata/speech_8.wav")
+	if err != nil {
+		t.Fatalf("Error reading wav: %v", err)
+	}
+	if sampleRate != 48000 || channels != 1 {
+		t.Errorf("Unexpected format: %d Hz, %d channels", sampleRate, channels)
+	}
+	if len(pcm) == 0 {
+		t.Errorf("No samples read")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+)
+
+// readWAV reads a 16 bit PCM .wav file. Just enough to feed the benchmark;
+// use a proper library for anything else.
+func readWAV(name string) (pcm []int16, sampleRate int, channels int, err error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, fmt.Errorf("%s: not a .wav file", name)
+	}
+	var haveFmt bool
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		body := data[pos+8:]
+		if size > len(body) {
+			size = len(body)
+		}
+		body = body[:size]
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, 0, fmt.Errorf("%s: invalid fmt chunk", name)
+			}
+			format := binary.LittleEndian.Uint16(body[0:])
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits := binary.LittleEndian.Uint16(body[14:])
+			if format != 1 || bits != 16 {
+				return nil, 0, 0, fmt.Errorf("%s: only 16 bit PCM is supported", name)
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, 0, 0, fmt.Errorf("%s: data before fmt chunk", name)
+			}
+			pcm = make([]int16, size/2)
+			for i := range pcm {
+				pcm[i] = int16(binary.LittleEndian.Uint16(body[2*i:]))
+			}
+			return pcm, sampleRate, channels, nil
+		}
+		// Chunks are padded to an even size
+		pos += 8 + size + size%2
+	}
+	return nil, 0, 0, fmt.Errorf("%s: no data chunk", name)
+}
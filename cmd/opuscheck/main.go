@@ -0,0 +1,72 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// Command opuscheck scans Ogg Opus files for holes, damaged or out of order
+// pages, bad channel mappings and other spec violations. Findings are written
+// to stdout as JSON lines, one per finding. The exit status is 1 if anything
+// was found, 2 on errors.
+//
+// Usage:
+//
+//	opuscheck [file ...]
+//
+// Without arguments, opuscheck reads from stdin.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hraban/opus/v2"
+)
+
+type result struct {
+	File string `json:"file"`
+	opus.Finding
+}
+
+func check(name string, r io.Reader, out *json.Encoder) (int, error) {
+	findings, err := opus.CheckStream(r)
+	for _, f := range findings {
+		if err := out.Encode(result{File: name, Finding: f}); err != nil {
+			return 0, err
+		}
+	}
+	return len(findings), err
+}
+
+func main() {
+	out := json.NewEncoder(os.Stdout)
+	status := 0
+	if len(os.Args) < 2 {
+		n, err := check("-", os.Stdin, out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "opuscheck: %v\n", err)
+			os.Exit(2)
+		}
+		if n > 0 {
+			status = 1
+		}
+		os.Exit(status)
+	}
+	for _, name := range os.Args[1:] {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "opuscheck: %v\n", err)
+			status = 2
+			continue
+		}
+		n, err := check(name, f, out)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "opuscheck: %s: %v\n", name, err)
+			status = 2
+		} else if n > 0 && status == 0 {
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
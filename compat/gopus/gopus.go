@@ -0,0 +1,160 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// Package gopus mimics the API of layeh.com/gopus on top of this package, so
+// code using gopus can switch by changing its import path only:
+//
+//	import "github.com/hraban/opus/v2/compat/gopus"
+//
+// Only the encoder and decoder calls are covered. gopus' SetVbr has no
+// counterpart here and is left out.
+package gopus
+
+import (
+	"fmt"
+
+	"github.com/hraban/opus/v2"
+)
+
+// Application is the encoder application.
+type Application = opus.Application
+
+const (
+	Voip               = opus.AppVoIP
+	Audio              = opus.AppAudio
+	RestrictedLowDelay = opus.AppRestrictedLowdelay
+)
+
+// BitrateMaximum makes the encoder use as many bits as it can.
+const BitrateMaximum = -1
+
+// Encoder is a gopus style encoder.
+type Encoder struct {
+	enc         *opus.Encoder
+	sampleRate  int
+	channels    int
+	application Application
+	bitrate     int
+	data        []byte
+}
+
+// NewEncoder creates an encoder.
+func NewEncoder(sampleRate, channels int, application Application) (*Encoder, error) {
+	enc, err := opus.NewEncoder(sampleRate, channels, application)
+	if err != nil {
+		return nil, err
+	}
+	return &Encoder{
+		enc:         enc,
+		sampleRate:  sampleRate,
+		channels:    channels,
+		application: application,
+	}, nil
+}
+
+// Encode encodes frameSize samples per channel of pcm into a new packet of at
+// most maxDataBytes bytes.
+func (e *Encoder) Encode(pcm []int16, frameSize, maxDataBytes int) ([]byte, error) {
+	if len(pcm) < frameSize*e.channels {
+		return nil, fmt.Errorf("opus: pcm holds less than %d samples per channel", frameSize)
+	}
+	if cap(e.data) < maxDataBytes {
+		e.data = make([]byte, maxDataBytes)
+	}
+	n, err := e.enc.Encode(pcm[:frameSize*e.channels], e.data[:maxDataBytes])
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.data[:n]...), nil
+}
+
+// SetBitrate sets the target bitrate in bits per second, or BitrateMaximum.
+func (e *Encoder) SetBitrate(bitrate int) {
+	if bitrate == BitrateMaximum {
+		e.enc.SetBitrateToMax()
+	} else {
+		e.enc.SetBitrate(bitrate)
+	}
+	e.bitrate = bitrate
+}
+
+// Bitrate returns the target bitrate.
+func (e *Encoder) Bitrate() int {
+	bitrate, _ := e.enc.Bitrate()
+	return bitrate
+}
+
+// SetApplication changes the encoder application.
+func (e *Encoder) SetApplication(application Application) {
+	if e.enc.SwitchApplication(application) == nil {
+		e.application = application
+	}
+}
+
+// Application returns the encoder application.
+func (e *Encoder) Application() Application {
+	return e.application
+}
+
+// ResetState resets the encoder to a freshly created one, keeping the
+// application and bitrate.
+func (e *Encoder) ResetState() {
+	enc, err := opus.NewEncoder(e.sampleRate, e.channels, e.application)
+	if err != nil {
+		// Can't happen: the same parameters worked before
+		return
+	}
+	e.enc = enc
+	if e.bitrate != 0 {
+		e.SetBitrate(e.bitrate)
+	}
+}
+
+// Decoder is a gopus style decoder.
+type Decoder struct {
+	dec        *opus.Decoder
+	sampleRate int
+	channels   int
+}
+
+// NewDecoder creates a decoder.
+func NewDecoder(sampleRate, channels int) (*Decoder, error) {
+	dec, err := opus.NewDecoder(sampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{dec: dec, sampleRate: sampleRate, channels: channels}, nil
+}
+
+// Decode decodes a packet into a new slice, with room for frameSize samples
+// per channel. With fec set, the forward error correction data in the packet
+// is decoded instead, to recover the packet before it; frameSize must then be
+// the duration of the lost packet. A nil packet is concealed.
+func (d *Decoder) Decode(data []byte, frameSize int, fec bool) ([]int16, error) {
+	pcm := make([]int16, frameSize*d.channels)
+	switch {
+	case len(data) == 0:
+		if err := d.dec.DecodePLC(pcm); err != nil {
+			return nil, err
+		}
+		return pcm, nil
+	case fec:
+		if err := d.dec.DecodeFEC(data, pcm); err != nil {
+			return nil, err
+		}
+		return pcm, nil
+	}
+	n, err := d.dec.Decode(data, pcm)
+	if err != nil {
+		return nil, err
+	}
+	return pcm[:n*d.channels], nil
+}
+
+// ResetState resets the decoder to a freshly created one.
+func (d *Decoder) ResetState() {
+	if dec, err := opus.NewDecoder(d.sampleRate, d.channels); err == nil {
+		d.dec = dec
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package gopus
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const CHANNELS = 2
+	enc, err := NewEncoder(SAMPLE_RATE, CHANNELS, Audio)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	enc.SetBitrate(64000)
+	if b := enc.Bitrate(); b != 64000 {
+		t.Errorf("Expected bitrate 64000, got %d", b)
+	}
+	enc.SetApplication(Voip)
+	if a := enc.Application(); a != Voip {
+		t.Errorf("Expected application %v, got %v", Voip, a)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, CHANNELS)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*CHANNELS)
+	for i := range pcm {
+		pcm[i] = int16(math.Sin(2*math.Pi*G4*float64(i/CHANNELS)/SAMPLE_RATE) * 16000)
+	}
+	for i := 0; i < 10; i++ {
+		data, err := enc.Encode(pcm, FRAME_SIZE, 1000)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		out, err := dec.Decode(data, FRAME_SIZE, false)
+		if err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		if len(out) != FRAME_SIZE*CHANNELS {
+			t.Fatalf("Expected %d samples, got %d", FRAME_SIZE*CHANNELS, len(out))
+		}
+	}
+	// Lost packet
+	if out, err := dec.Decode(nil, FRAME_SIZE, false); err != nil || len(out) != FRAME_SIZE*CHANNELS {
+		t.Errorf("Couldn't conceal lost packet: %d samples, error %v", len(out), err)
+	}
+	enc.ResetState()
+	if b := enc.Bitrate(); b != 64000 {
+		t.Errorf("Expected bitrate to survive reset, got %d", b)
+	}
+	dec.ResetState()
+}
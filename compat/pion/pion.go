@@ -0,0 +1,112 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// Package pion mimics the decoder API of github.com/pion/opus on top of this
+// package, so code using it can switch by changing its import only:
+//
+//	import opus "github.com/hraban/opus/v2/compat/pion"
+//
+// Unlike pion/opus, all modes are supported, not only SILK. Output is mono
+// at 48 kHz.
+package pion
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/hraban/opus/v2"
+)
+
+// Bandwidth is the audio bandwidth of a packet.
+type Bandwidth = opus.Bandwidth
+
+const (
+	BandwidthNarrowband    = opus.Narrowband
+	BandwidthMediumband    = opus.Mediumband
+	BandwidthWideband      = opus.Wideband
+	BandwidthSuperwideband = opus.SuperWideband
+	BandwidthFullband      = opus.Fullband
+)
+
+// sampleRate is the rate of the decoded output.
+const sampleRate = 48000
+
+// Decoder is a pion/opus style decoder.
+type Decoder struct {
+	dec *opus.Decoder
+	pcm []float32
+}
+
+// NewDecoder creates a decoder. The libopus decoder is created on first use,
+// so the zero Decoder works too.
+func NewDecoder() Decoder {
+	return Decoder{}
+}
+
+func (d *Decoder) init() error {
+	if d.dec != nil {
+		return nil
+	}
+	dec, err := opus.NewDecoder(sampleRate, 1)
+	if err != nil {
+		return err
+	}
+	d.dec = dec
+	d.pcm = make([]float32, sampleRate*120/1000)
+	return nil
+}
+
+// Decode decodes a packet into out as signed 16 bit little endian samples,
+// and returns the bandwidth and channel layout of the packet.
+func (d *Decoder) Decode(in, out []byte) (bandwidth Bandwidth, isStereo bool, err error) {
+	if err := d.init(); err != nil {
+		return 0, false, err
+	}
+	bandwidth, isStereo, n, err := d.decode(in)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(out) < 2*n {
+		return 0, false, fmt.Errorf("opus: out too small: packet needs %d bytes", 2*n)
+	}
+	for i, s := range d.pcm[:n] {
+		v := s * 32768
+		if v > 32767 {
+			v = 32767
+		} else if v < -32768 {
+			v = -32768
+		}
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(v)))
+	}
+	return bandwidth, isStereo, nil
+}
+
+// DecodeFloat32 decodes a packet into out, and returns the bandwidth and
+// channel layout of the packet.
+func (d *Decoder) DecodeFloat32(in []byte, out []float32) (bandwidth Bandwidth, isStereo bool, err error) {
+	if err := d.init(); err != nil {
+		return 0, false, err
+	}
+	bandwidth, isStereo, n, err := d.decode(in)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(out) < n {
+		return 0, false, fmt.Errorf("opus: out too small: packet needs %d samples", n)
+	}
+	copy(out, d.pcm[:n])
+	return bandwidth, isStereo, nil
+}
+
+func (d *Decoder) decode(in []byte) (Bandwidth, bool, int, error) {
+	info, err := opus.ParsePacket(in)
+	if err != nil {
+		return 0, false, 0, err
+	}
+	n, err := d.dec.DecodeFloat32(in, d.pcm)
+	if err != nil {
+		return 0, false, 0, err
+	}
+	return info.Bandwidth, info.Stereo, n, nil
+}
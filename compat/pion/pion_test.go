@@ -0,0 +1,53 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package pion
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hraban/opus/v2"
+)
+
+func TestDecode(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := opus.NewEncoder(SAMPLE_RATE, 1, opus.AppVoIP)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.SetBitrate(32000); err != nil {
+		t.Fatalf("Error setting bitrate: %v", err)
+	}
+	if err := enc.SetMaxBandwidth(opus.Wideband); err != nil {
+		t.Fatalf("Error setting bandwidth: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	for i := range pcm {
+		pcm[i] = int16(math.Sin(2*math.Pi*G4*float64(i)/SAMPLE_RATE) * 16000)
+	}
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	dec := NewDecoder()
+	out := make([]byte, 2*FRAME_SIZE)
+	bw, stereo, err := dec.Decode(data[:n], out)
+	if err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	if bw != BandwidthWideband || stereo {
+		t.Errorf("Expected wideband mono packet, got %v, stereo %v", bw, stereo)
+	}
+	outf := make([]float32, FRAME_SIZE)
+	if _, _, err := dec.DecodeFloat32(data[:n], outf); err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	if _, _, err := dec.Decode(data[:n], out[:10]); err == nil {
+		t.Errorf("Expected error for too small output")
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultLongConcealment is the default length above which a stretch of
+// consecutive PLC is counted in ConcealStats.LongPLCStretches.
+const DefaultLongConcealment = 100 * time.Millisecond
+
+// ConcealStats describes how much of the audio produced by a decoder is
+// synthetic. All sample counts are per channel, at the decoder's sample rate.
+type ConcealStats struct {
+	// Samples decoded from actual packets
+	DecodedSamples int64
+	// Samples made up by packet loss concealment (DecodePLC, and empty
+	// packets in DecodeBatch). Only counted for successful calls, except for
+	// DecodeBatch, which counts the packets before the one that failed.
+	ConcealedSamples int64
+	// Frames recovered with DecodeFEC. Libopus falls back to PLC if the
+	// packet has no FEC data, which is counted here all the same.
+	FECFrames int64
+	// Samples recovered with DecodeFEC
+	FECSamples int64
+	// Number of uninterrupted stretches of PLC longer than the threshold set
+	// with SetLongConcealment. Lost packets in DecodeBatch don't count
+	// towards these.
+	LongPLCStretches int64
+}
+
+// concealTracker keeps the ConcealStats of a decoder up to date.
+type concealTracker struct {
+	stats ConcealStats
+	// Threshold for LongPLCStretches, in samples. Zero means the default.
+	long int
+	// Length of the current stretch of PLC, in samples
+	stretch int
+}
+
+func (t *concealTracker) threshold(sampleRate int) int {
+	if t.long == 0 {
+		return FrameSamples(sampleRate, DefaultLongConcealment)
+	}
+	return t.long
+}
+
+func (t *concealTracker) decoded(n int) {
+	t.stats.DecodedSamples += int64(n)
+	t.stretch = 0
+}
+
+func (t *concealTracker) fec(n int) {
+	t.stats.FECFrames++
+	t.stats.FECSamples += int64(n)
+	t.stretch = 0
+}
+
+func (t *concealTracker) plc(sampleRate, n int) {
+	t.stats.ConcealedSamples += int64(n)
+	long := t.threshold(sampleRate)
+	// Count each stretch once, when it crosses the threshold
+	if t.stretch <= long && t.stretch+n > long {
+		t.stats.LongPLCStretches++
+	}
+	t.stretch += n
+}
+
+// batch records the result of DecodeBatch. Stretches of PLC within a batch
+// aren't tracked, only whether the batch ended in one.
+func (t *concealTracker) batch(decoded, concealed int, endsLost bool) {
+	t.stats.DecodedSamples += int64(decoded)
+	t.stats.ConcealedSamples += int64(concealed)
+	if !endsLost {
+		t.stretch = 0
+	}
+}
+
+// ConcealStats returns the concealment statistics of the decoder since it was
+// created, or since the last call to ResetConcealStats.
+func (dec *Decoder) ConcealStats() ConcealStats {
+	return dec.conceal.stats
+}
+
+// ResetConcealStats sets all concealment statistics back to zero, e.g. at
+// the start of a new call. The threshold set with SetLongConcealment is
+// retained.
+func (dec *Decoder) ResetConcealStats() {
+	dec.conceal.stats = ConcealStats{}
+	dec.conceal.stretch = 0
+}
+
+// SetLongConcealment sets the length above which a stretch of consecutive PLC
+// is counted as long in ConcealStats. Defaults to DefaultLongConcealment.
+func (dec *Decoder) SetLongConcealment(d time.Duration) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	if d <= 0 {
+		return fmt.Errorf("opus: invalid concealment threshold: %v", d)
+	}
+	dec.conceal.long = FrameSamples(dec.sample_rate, d)
+	return nil
+}
+
+// ConcealStrategy is a way to fill gaps left by lost packets.
+type ConcealStrategy int
+
+const (
+	// Libopus packet loss concealment, see DecodePLC
+	ConcealPLC ConcealStrategy = iota
+	// Fill the gap with silence
+	ConcealSilence
+	// Libopus packet loss concealment, faded out to silence over the frame.
+	// Subsequent frames of the same gap are silent.
+	ConcealFadeOut
+)
+
+// ConcealPolicy decides which strategy Decoder.Conceal uses, depending on how
+// long the gap has been going on. Long stretches of PLC tend to sound worse
+// than silence, so a typical policy is to use PLC for the first 100 ms or so
+// and fade out to silence after that:
+//
+//	ConcealPolicy{Strategy: ConcealPLC, Limit: 100 * time.Millisecond, After: ConcealFadeOut}
+//
+// The zero value always uses PLC.
+type ConcealPolicy struct {
+	// Strategy for the start of a gap
+	Strategy ConcealStrategy
+	// How long to use Strategy before switching to After. Zero means no limit.
+	Limit time.Duration
+	// Strategy once the gap exceeds Limit
+	After ConcealStrategy
+}
+
+// SetConcealPolicy sets the policy used by Conceal and ConcealFloat32.
+func (dec *Decoder) SetConcealPolicy(p ConcealPolicy) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	for _, s := range []ConcealStrategy{p.Strategy, p.After} {
+		if s < ConcealPLC || s > ConcealFadeOut {
+			return fmt.Errorf("opus: invalid concealment strategy: %d", s)
+		}
+	}
+	if p.Limit < 0 {
+		return fmt.Errorf("opus: invalid concealment limit: %v", p.Limit)
+	}
+	dec.policy = p
+	return nil
+}
+
+// strategy returns the concealment strategy to use for the next frame, and
+// whether it's the first frame of the gap using that strategy.
+func (dec *Decoder) strategy() (ConcealStrategy, bool) {
+	p := dec.policy
+	s := p.Strategy
+	if p.Limit > 0 && dec.conceal.stretch >= FrameSamples(dec.sample_rate, p.Limit) {
+		s = p.After
+	}
+	first := dec.conceal.stretch == 0 || s != dec.lastStrategy
+	dec.lastStrategy = s
+	return s, first
+}
+
+// Conceal fills pcm for a lost packet, using the strategy chosen by the
+// policy set with SetConcealPolicy. Like DecodePLC, the buffer needs to be
+// exactly the duration of audio that is missing.
+func (dec *Decoder) Conceal(pcm []int16) error {
+	s, first := dec.strategy()
+	if s == ConcealSilence || s == ConcealFadeOut && !first {
+		return dec.concealSilence(len(pcm), cap(pcm), func() {
+			for i := range pcm[:cap(pcm)] {
+				pcm[i] = 0
+			}
+		})
+	}
+	if err := dec.DecodePLC(pcm); err != nil {
+		return err
+	}
+	if s == ConcealFadeOut {
+		// The output may have been remapped to fewer or more channels
+		n, ch := cap(pcm)/dec.bufChannels(), dec.outChannels()
+		pcm = pcm[:n*ch]
+		for i := range pcm {
+			pcm[i] = int16(int(pcm[i]) * (n - i/ch) / n)
+		}
+	}
+	return nil
+}
+
+// ConcealFloat32 is the same as Conceal, but for float32 audio.
+func (dec *Decoder) ConcealFloat32(pcm []float32) error {
+	s, first := dec.strategy()
+	if s == ConcealSilence || s == ConcealFadeOut && !first {
+		return dec.concealSilence(len(pcm), cap(pcm), func() {
+			for i := range pcm[:cap(pcm)] {
+				pcm[i] = 0
+			}
+		})
+	}
+	if err := dec.DecodePLCFloat32(pcm); err != nil {
+		return err
+	}
+	if s == ConcealFadeOut {
+		n, ch := cap(pcm)/dec.bufChannels(), dec.outChannels()
+		pcm = pcm[:n*ch]
+		for i := range pcm {
+			pcm[i] *= float32(n-i/ch) / float32(n)
+		}
+	}
+	return nil
+}
+
+// concealSilence checks the buffer the same way DecodePLC does, and calls
+// clear to fill it with silence.
+func (dec *Decoder) concealSilence(length, capacity int, clear func()) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	if !dec.guard.enter() {
+		return ErrConcurrentUse
+	}
+	defer dec.guard.leave()
+	dec.debug.touch()
+	if length == 0 {
+		return fmt.Errorf("opus: target buffer empty")
+	}
+	if capacity%dec.bufChannels() != 0 {
+		return errOutputChannels
+	}
+	n := capacity / dec.bufChannels()
+	if err := validateConcealSize(dec.sample_rate, n); err != nil {
+		return err
+	}
+	clear()
+	dec.conceal.plc(dec.sample_rate, n)
+	return nil
+}
@@ -0,0 +1,215 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConcealTrackerLongStretches(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	var tr concealTracker
+	// 100 ms of PLC is not longer than the default threshold
+	for i := 0; i < 5; i++ {
+		tr.plc(SAMPLE_RATE, FRAME_SIZE)
+	}
+	tr.decoded(FRAME_SIZE)
+	// 200 ms is, but only counts once
+	for i := 0; i < 10; i++ {
+		tr.plc(SAMPLE_RATE, FRAME_SIZE)
+	}
+	tr.fec(FRAME_SIZE)
+	want := ConcealStats{
+		DecodedSamples:   FRAME_SIZE,
+		ConcealedSamples: 15 * FRAME_SIZE,
+		FECFrames:        1,
+		FECSamples:       FRAME_SIZE,
+		LongPLCStretches: 1,
+	}
+	if tr.stats != want {
+		t.Errorf("Unexpected stats: %+v, expected %+v", tr.stats, want)
+	}
+}
+
+func TestDecoderConcealStats(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	pcm := make([]int16, FRAME_SIZE)
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	data = data[:n]
+
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetLongConcealment(40 * time.Millisecond); err != nil {
+		t.Fatalf("Error setting threshold: %v", err)
+	}
+	if _, err := dec.Decode(data, pcm); err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := dec.DecodePLC(pcm); err != nil {
+			t.Fatalf("Couldn't conceal data: %v", err)
+		}
+	}
+	if err := dec.DecodeFEC(data, pcm); err != nil {
+		t.Fatalf("Couldn't recover data: %v", err)
+	}
+	stats := dec.ConcealStats()
+	want := ConcealStats{
+		DecodedSamples:   FRAME_SIZE,
+		ConcealedSamples: 3 * FRAME_SIZE,
+		FECFrames:        1,
+		FECSamples:       FRAME_SIZE,
+		LongPLCStretches: 1,
+	}
+	if stats != want {
+		t.Errorf("Unexpected stats: %+v, expected %+v", stats, want)
+	}
+	dec.ResetConcealStats()
+	if stats := dec.ConcealStats(); stats != (ConcealStats{}) {
+		t.Errorf("Stats not reset: %+v", stats)
+	}
+}
+
+func TestDecoderConcealPolicy(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	pcm := make([]int16, FRAME_SIZE)
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	for i := 0; i < 5; i++ {
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		if _, err := dec.Decode(data[:n], pcm); err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+	}
+	err = dec.SetConcealPolicy(ConcealPolicy{
+		Strategy: ConcealPLC,
+		Limit:    40 * time.Millisecond,
+		After:    ConcealFadeOut,
+	})
+	if err != nil {
+		t.Fatalf("Error setting policy: %v", err)
+	}
+	frames := make([][]int16, 4)
+	for i := range frames {
+		frames[i] = make([]int16, FRAME_SIZE)
+		if err := dec.Conceal(frames[i]); err != nil {
+			t.Fatalf("Couldn't conceal frame %d: %v", i, err)
+		}
+	}
+	if frames[0][FRAME_SIZE-1] == 0 && frames[0][FRAME_SIZE-2] == 0 {
+		t.Errorf("Expected PLC output in first frame")
+	}
+	if frames[2][FRAME_SIZE-1] != 0 {
+		t.Errorf("Expected faded out frame to end in silence, got %d", frames[2][FRAME_SIZE-1])
+	}
+	for i, s := range frames[3] {
+		if s != 0 {
+			t.Fatalf("Expected silence after fade out, got %d at %d", s, i)
+		}
+	}
+	if stats := dec.ConcealStats(); stats.ConcealedSamples != 4*FRAME_SIZE {
+		t.Errorf("Expected %d concealed samples, got %d", 4*FRAME_SIZE, stats.ConcealedSamples)
+	}
+}
+
+func TestDecoderConcealOutputChannels(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetMonoOutput(true); err != nil {
+		t.Fatalf("Error setting mono output: %v", err)
+	}
+	mono := make([]int16, FRAME_SIZE)
+	addSine(mono, SAMPLE_RATE, G4)
+	pcm := interleave(mono, mono)
+	data := make([]byte, 1000)
+	for i := 0; i < 5; i++ {
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		if _, err := dec.Decode(data[:n], make([]int16, FRAME_SIZE*2)); err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+	}
+	if err := dec.SetConcealPolicy(ConcealPolicy{Strategy: ConcealFadeOut}); err != nil {
+		t.Fatalf("Error setting policy: %v", err)
+	}
+	// The buffer has room for both decoded channels, the output is mono
+	out := make([]int16, FRAME_SIZE*2)
+	if err := dec.Conceal(out); err != nil {
+		t.Fatalf("Couldn't conceal frame: %v", err)
+	}
+	if out[FRAME_SIZE-1] != 0 {
+		t.Errorf("Expected faded out mono frame to end in silence, got %d", out[FRAME_SIZE-1])
+	}
+
+	// Mono decoder upmixing to stereo: samples are counted per channel
+	dec, err = NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetStereoOutput(true); err != nil {
+		t.Fatalf("Error setting stereo output: %v", err)
+	}
+	if err := dec.SetConcealPolicy(ConcealPolicy{Strategy: ConcealSilence}); err != nil {
+		t.Fatalf("Error setting policy: %v", err)
+	}
+	if err := dec.Conceal(out); err != nil {
+		t.Fatalf("Couldn't conceal frame: %v", err)
+	}
+	if stats := dec.ConcealStats(); stats.ConcealedSamples != FRAME_SIZE {
+		t.Errorf("Expected %d concealed samples, got %d", FRAME_SIZE, stats.ConcealedSamples)
+	}
+}
+
+func TestDecoderConcealPolicyInvalid(t *testing.T) {
+	dec, err := NewDecoder(48000, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetConcealPolicy(ConcealPolicy{Strategy: 42}); err == nil {
+		t.Errorf("Expected error for invalid strategy")
+	}
+	if err := dec.SetConcealPolicy(ConcealPolicy{Limit: -time.Second}); err == nil {
+		t.Errorf("Expected error for negative limit")
+	}
+}
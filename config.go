@@ -0,0 +1,354 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+)
+
+// EncoderConfig holds every encoder setting this package supports, in a form
+// which can be stored in (JSON) configuration files and applied to encoders
+// reproducibly.
+//
+// Zero values stand for the libopus defaults, and ConfigOf reports defaults
+// as zero, so that a config only needs to list what it changes. Even the zero
+// config is valid: applied, it restores all defaults but the application.
+type EncoderConfig struct {
+	// Zero keeps the application the encoder was created with
+	Application Application `json:"application,omitempty"`
+	// Bitrate in bits/s. Zero means automatic. Reported as the effective
+	// bitrate, see ConfigOf.
+	Bitrate int `json:"bitrate,omitempty"`
+	// Zero means the default of 9. Use LowestComplexity for complexity 0.
+	Complexity int `json:"complexity,omitempty"`
+	// Zero means the default, Fullband
+	MaxBandwidth Bandwidth `json:"max_bandwidth,omitempty"`
+	// Forced bandpass, see SetBandwidth. Zero means automatic.
+	Bandwidth      Bandwidth `json:"bandwidth,omitempty"`
+	InBandFEC      bool      `json:"inband_fec"`
+	PacketLossPerc int       `json:"packet_loss_perc"`
+	DTX            bool      `json:"dtx"`
+	// See SetPredictionDisabled
+	PredictionDisabled bool `json:"prediction_disabled,omitempty"`
+	// Constant bitrate instead of the default VBR, see SetVBR
+	CBR bool `json:"cbr,omitempty"`
+	// Unconstrained instead of the default constrained VBR, see
+	// SetVBRConstraint
+	UnconstrainedVBR bool `json:"unconstrained_vbr,omitempty"`
+	// Zero means SignalAuto
+	Signal Signal `json:"signal,omitempty"`
+	// Depth of the signal in bits. Zero means the default of 24.
+	LSBDepth               int  `json:"lsb_depth,omitempty"`
+	PhaseInversionDisabled bool `json:"phase_inversion_disabled,omitempty"`
+	// Zero means the encoder decides, see SetForceChannels
+	ForceChannels int `json:"force_channels,omitempty"`
+	// Zero means FrameDurationArg
+	ExpertFrameDuration FrameDuration `json:"expert_frame_duration,omitempty"`
+}
+
+// LowestComplexity stands for complexity 0 in an EncoderConfig, where zero
+// means the default complexity.
+const LowestComplexity = -1
+
+// Defaults of libopus for the settings of EncoderConfig which zero can't
+// express directly.
+const (
+	defaultComplexity   = 9
+	defaultMaxBandwidth = Fullband
+	defaultLSBDepth     = 24
+)
+
+// ConfigOf reads the current configuration of an encoder.
+//
+// Note that libopus reports the effective bitrate, so the Bitrate of an
+// encoder set to an automatic bitrate will come back as a concrete number.
+func ConfigOf(enc *Encoder) (EncoderConfig, error) {
+	var cfg EncoderConfig
+	if enc.p == nil {
+		return cfg, enc.errUninitialized()
+	}
+	app, err := enc.Application()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Application = app
+	bitrate, err := enc.Bitrate()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Bitrate = bitrate
+	s, err := enc.settings()
+	if err != nil {
+		return cfg, err
+	}
+	switch s.complexity {
+	case defaultComplexity:
+	case 0:
+		cfg.Complexity = LowestComplexity
+	default:
+		cfg.Complexity = s.complexity
+	}
+	if s.maxBandwidth != defaultMaxBandwidth {
+		cfg.MaxBandwidth = s.maxBandwidth
+	}
+	cfg.Bandwidth = enc.forcedBandwidth
+	cfg.InBandFEC = s.inBandFEC
+	cfg.PacketLossPerc = s.packetLossPerc
+	cfg.DTX = s.dtx
+	if cfg.PredictionDisabled, err = enc.PredictionDisabled(); err != nil {
+		return cfg, err
+	}
+	vbr, err := enc.VBR()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.CBR = !vbr
+	constraint, err := enc.VBRConstraint()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.UnconstrainedVBR = !constraint
+	signal, err := enc.Signal()
+	if err != nil {
+		return cfg, err
+	}
+	if signal != SignalAuto {
+		cfg.Signal = signal
+	}
+	depth, err := enc.LSBDepth()
+	if err != nil {
+		return cfg, err
+	}
+	if depth != defaultLSBDepth {
+		cfg.LSBDepth = depth
+	}
+	if cfg.PhaseInversionDisabled, err = enc.PhaseInversionDisabled(); err != nil {
+		return cfg, err
+	}
+	if cfg.ForceChannels, err = enc.ForceChannels(); err != nil {
+		return cfg, err
+	}
+	fd, err := enc.ExpertFrameDuration()
+	if err != nil {
+		return cfg, err
+	}
+	if fd != FrameDurationArg {
+		cfg.ExpertFrameDuration = fd
+	}
+	return cfg, nil
+}
+
+// ApplyTo configures the encoder with all settings from the config. The
+// application is changed using SwitchApplication, so this is also safe to use
+// on an encoder which is already in use.
+func (cfg EncoderConfig) ApplyTo(enc *Encoder) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	if cfg.Application != 0 {
+		if err := enc.SwitchApplication(cfg.Application); err != nil {
+			return err
+		}
+	}
+	var err error
+	if cfg.Bitrate == 0 {
+		err = enc.SetBitrateToAuto()
+	} else {
+		err = enc.SetBitrate(cfg.Bitrate)
+	}
+	if err != nil {
+		return err
+	}
+	complexity := cfg.Complexity
+	switch complexity {
+	case 0:
+		complexity = defaultComplexity
+	case LowestComplexity:
+		complexity = 0
+	}
+	maxBandwidth := cfg.MaxBandwidth
+	if maxBandwidth == 0 {
+		maxBandwidth = defaultMaxBandwidth
+	}
+	err = enc.applySettings(encoderSettings{
+		complexity:     complexity,
+		maxBandwidth:   maxBandwidth,
+		inBandFEC:      cfg.InBandFEC,
+		packetLossPerc: cfg.PacketLossPerc,
+		dtx:            cfg.DTX,
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.Bandwidth == 0 {
+		err = enc.SetBandwidthToAuto()
+	} else {
+		err = enc.SetBandwidth(cfg.Bandwidth)
+	}
+	if err != nil {
+		return err
+	}
+	if err := enc.SetPredictionDisabled(cfg.PredictionDisabled); err != nil {
+		return err
+	}
+	if err := enc.SetVBR(!cfg.CBR); err != nil {
+		return err
+	}
+	if err := enc.SetVBRConstraint(!cfg.UnconstrainedVBR); err != nil {
+		return err
+	}
+	signal := cfg.Signal
+	if signal == 0 {
+		signal = SignalAuto
+	}
+	if err := enc.SetSignal(signal); err != nil {
+		return err
+	}
+	depth := cfg.LSBDepth
+	if depth == 0 {
+		depth = defaultLSBDepth
+	}
+	if err := enc.SetLSBDepth(depth); err != nil {
+		return err
+	}
+	if err := enc.SetPhaseInversionDisabled(cfg.PhaseInversionDisabled); err != nil {
+		return err
+	}
+	if err := enc.SetForceChannels(cfg.ForceChannels); err != nil {
+		return err
+	}
+	fd := cfg.ExpertFrameDuration
+	if fd == 0 {
+		fd = FrameDurationArg
+	}
+	return enc.SetExpertFrameDuration(fd)
+}
+
+var applicationNames = map[Application]string{
+	AppVoIP:               "voip",
+	AppAudio:              "audio",
+	AppRestrictedLowdelay: "restricted-lowdelay",
+}
+
+func (a Application) String() string {
+	if name, ok := applicationNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Application(%d)", int(a))
+}
+
+// MarshalText encodes the application by name, e.g. "voip".
+func (a Application) MarshalText() ([]byte, error) {
+	name, ok := applicationNames[a]
+	if !ok {
+		return nil, fmt.Errorf("opus: unknown application %d", int(a))
+	}
+	return []byte(name), nil
+}
+
+func (a *Application) UnmarshalText(text []byte) error {
+	for app, name := range applicationNames {
+		if name == string(text) {
+			*a = app
+			return nil
+		}
+	}
+	return fmt.Errorf("opus: unknown application %q", text)
+}
+
+var bandwidthNames = map[Bandwidth]string{
+	Narrowband:    "narrowband",
+	Mediumband:    "mediumband",
+	Wideband:      "wideband",
+	SuperWideband: "superwideband",
+	Fullband:      "fullband",
+}
+
+func (bw Bandwidth) String() string {
+	if name, ok := bandwidthNames[bw]; ok {
+		return name
+	}
+	return fmt.Sprintf("Bandwidth(%d)", int(bw))
+}
+
+// MarshalText encodes the bandwidth by name, e.g. "wideband".
+func (bw Bandwidth) MarshalText() ([]byte, error) {
+	name, ok := bandwidthNames[bw]
+	if !ok {
+		return nil, fmt.Errorf("opus: unknown bandwidth %d", int(bw))
+	}
+	return []byte(name), nil
+}
+
+func (bw *Bandwidth) UnmarshalText(text []byte) error {
+	for b, name := range bandwidthNames {
+		if name == string(text) {
+			*bw = b
+			return nil
+		}
+	}
+	return fmt.Errorf("opus: unknown bandwidth %q", text)
+}
+
+var signalNames = map[Signal]string{
+	SignalAuto:  "auto",
+	SignalVoice: "voice",
+	SignalMusic: "music",
+}
+
+func (s Signal) String() string {
+	if name, ok := signalNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Signal(%d)", int(s))
+}
+
+// MarshalText encodes the signal by name, e.g. "voice".
+func (s Signal) MarshalText() ([]byte, error) {
+	name, ok := signalNames[s]
+	if !ok {
+		return nil, fmt.Errorf("opus: unknown signal %d", int(s))
+	}
+	return []byte(name), nil
+}
+
+func (s *Signal) UnmarshalText(text []byte) error {
+	for sig, name := range signalNames {
+		if name == string(text) {
+			*s = sig
+			return nil
+		}
+	}
+	return fmt.Errorf("opus: unknown signal %q", text)
+}
+
+func (fd FrameDuration) String() string {
+	if fd == FrameDurationArg {
+		return "arg"
+	}
+	if d := fd.Duration(); d != 0 {
+		return d.String()
+	}
+	return fmt.Sprintf("FrameDuration(%d)", int(fd))
+}
+
+// MarshalText encodes the frame duration as a duration, e.g. "20ms", or "arg"
+// for FrameDurationArg.
+func (fd FrameDuration) MarshalText() ([]byte, error) {
+	if fd != FrameDurationArg && fd.Duration() == 0 {
+		return nil, fmt.Errorf("opus: unknown frame duration %d", int(fd))
+	}
+	return []byte(fd.String()), nil
+}
+
+func (fd *FrameDuration) UnmarshalText(text []byte) error {
+	for f := FrameDurationArg; f <= FrameDuration120ms; f++ {
+		if f.String() == string(text) {
+			*fd = f
+			return nil
+		}
+	}
+	return fmt.Errorf("opus: unknown frame duration %q", text)
+}
@@ -0,0 +1,157 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEncoderConfig(t *testing.T) {
+	cfg := EncoderConfig{
+		Application:    AppAudio,
+		Bitrate:        48000,
+		Complexity:     7,
+		MaxBandwidth:   SuperWideband,
+		InBandFEC:      true,
+		PacketLossPerc: 15,
+		DTX:            true,
+	}
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := cfg.ApplyTo(enc); err != nil {
+		t.Fatalf("Error applying config: %v", err)
+	}
+	got, err := ConfigOf(enc)
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+	if got != cfg {
+		t.Errorf("Config mismatch. Got %+v, expected %+v", got, cfg)
+	}
+}
+
+func TestEncoderConfigExpert(t *testing.T) {
+	cfg := EncoderConfig{
+		Application:            AppAudio,
+		Bitrate:                64000,
+		Complexity:             10,
+		CBR:                    true,
+		UnconstrainedVBR:       true,
+		Signal:                 SignalMusic,
+		LSBDepth:               16,
+		PhaseInversionDisabled: true,
+		ForceChannels:          1,
+		ExpertFrameDuration:    FrameDuration10ms,
+	}
+	enc, err := NewEncoder(48000, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := cfg.ApplyTo(enc); err != nil {
+		t.Fatalf("Error applying config: %v", err)
+	}
+	got, err := ConfigOf(enc)
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+	if got != cfg {
+		t.Errorf("Config mismatch. Got %+v, expected %+v", got, cfg)
+	}
+	// Zero values restore the defaults
+	def := EncoderConfig{Application: AppAudio, Bitrate: 64000, Complexity: 10}
+	if err := def.ApplyTo(enc); err != nil {
+		t.Fatalf("Error applying config: %v", err)
+	}
+	if got, err = ConfigOf(enc); err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+	if got != def {
+		t.Errorf("Config mismatch. Got %+v, expected %+v", got, def)
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Error marshalling config: %v", err)
+	}
+	var back EncoderConfig
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("Error unmarshalling config: %v", err)
+	}
+	if back != cfg {
+		t.Errorf("Round trip mismatch. Got %+v, expected %+v", back, cfg)
+	}
+}
+
+func TestEncoderConfigPartial(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	// Zero values are the defaults, and the application is kept
+	if err := (EncoderConfig{}).ApplyTo(enc); err != nil {
+		t.Fatalf("Error applying zero config: %v", err)
+	}
+	got, err := ConfigOf(enc)
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+	got.Bitrate = 0
+	if expected := (EncoderConfig{Application: AppVoIP}); got != expected {
+		t.Errorf("Config mismatch. Got %+v, expected %+v", got, expected)
+	}
+	cfg := EncoderConfig{
+		Application:        AppVoIP,
+		Bitrate:            16000,
+		Complexity:         LowestComplexity,
+		Bandwidth:          Wideband,
+		PredictionDisabled: true,
+	}
+	if err := cfg.ApplyTo(enc); err != nil {
+		t.Fatalf("Error applying config: %v", err)
+	}
+	cpx, err := enc.Complexity()
+	if err != nil {
+		t.Fatalf("Error getting complexity: %v", err)
+	}
+	if cpx != 0 {
+		t.Errorf("Expected complexity 0, got %d", cpx)
+	}
+	if got, err = ConfigOf(enc); err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+	if got != cfg {
+		t.Errorf("Config mismatch. Got %+v, expected %+v", got, cfg)
+	}
+}
+
+func TestEncoderConfigJSON(t *testing.T) {
+	cfg := EncoderConfig{
+		Application:  AppVoIP,
+		Complexity:   5,
+		MaxBandwidth: Wideband,
+		DTX:          true,
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Error marshalling config: %v", err)
+	}
+	const expected = `{"application":"voip","complexity":5,"max_bandwidth":"wideband","inband_fec":false,"packet_loss_perc":0,"dtx":true}`
+	if string(data) != expected {
+		t.Errorf("Unexpected JSON. Got %s, expected %s", data, expected)
+	}
+	var back EncoderConfig
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("Error unmarshalling config: %v", err)
+	}
+	if back != cfg {
+		t.Errorf("Round trip mismatch. Got %+v, expected %+v", back, cfg)
+	}
+	if err := json.Unmarshal([]byte(`{"application":"karaoke"}`), &back); err == nil {
+		t.Errorf("Expected error for unknown application")
+	}
+}
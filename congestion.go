@@ -0,0 +1,161 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CongestionController is a bandwidth estimator driven by transport feedback.
+// Transports report acknowledged and lost packets and round trip times; the
+// controller turns that into a target bitrate for the encoder.
+//
+// Implementations must be safe for concurrent use: feedback usually arrives
+// on a network goroutine while the encoder runs on another.
+type CongestionController interface {
+	// OnAck reports n packets as delivered.
+	OnAck(n int)
+	// OnLoss reports n packets as lost.
+	OnLoss(n int)
+	// OnRTT reports a round trip time measurement.
+	OnRTT(rtt time.Duration)
+	// TargetBitrate returns the bitrate (in bits/s) the encoder should use.
+	TargetBitrate() int
+}
+
+// LossEstimator is an optional interface for congestion controllers which
+// also track the observed packet loss, in percent.
+type LossEstimator interface {
+	LossPerc() int
+}
+
+// ApplyCongestionControl sets the encoder bitrate to the controller's target.
+// If the controller also implements LossEstimator, the expected packet loss
+// is updated to match and inband FEC is switched on whenever loss is
+// observed. Call this periodically, e.g. once per frame.
+func (enc *Encoder) ApplyCongestionControl(cc CongestionController) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	if err := enc.SetBitrate(cc.TargetBitrate()); err != nil {
+		return err
+	}
+	le, ok := cc.(LossEstimator)
+	if !ok {
+		return nil
+	}
+	loss := le.LossPerc()
+	if err := enc.SetPacketLossPerc(loss); err != nil {
+		return err
+	}
+	return enc.SetInBandFEC(loss > 0)
+}
+
+// GCCController is a reference CongestionController loosely modelled after
+// Google Congestion Control: a loss based controller which backs off under
+// heavy loss and probes upwards when the path is clean, combined with a crude
+// delay based controller which backs off when the RTT rises well above the
+// lowest RTT seen so far.
+type GCCController struct {
+	mu      sync.Mutex
+	min     int
+	max     int
+	bitrate int
+	acked   int
+	lost    int
+	loss    int
+	minRTT  time.Duration
+	rtt     time.Duration
+	// Whether an RTT sample arrived since the previous TargetBitrate
+	newRTT bool
+}
+
+var _ CongestionController = (*GCCController)(nil)
+var _ LossEstimator = (*GCCController)(nil)
+
+// NewGCCController creates a controller starting at bitrate start and never
+// leaving the range [min, max] (all in bits/s).
+func NewGCCController(min, max, start int) (*GCCController, error) {
+	if min <= 0 || max < min {
+		return nil, fmt.Errorf("opus: invalid bitrate range [%d, %d]", min, max)
+	}
+	if start < min || start > max {
+		return nil, fmt.Errorf("opus: start bitrate %d outside range [%d, %d]", start, min, max)
+	}
+	return &GCCController{
+		min:     min,
+		max:     max,
+		bitrate: start,
+	}, nil
+}
+
+func (c *GCCController) OnAck(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.acked += n
+}
+
+func (c *GCCController) OnLoss(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lost += n
+}
+
+func (c *GCCController) OnRTT(rtt time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.minRTT == 0 || rtt < c.minRTT {
+		c.minRTT = rtt
+	}
+	if c.rtt == 0 {
+		c.rtt = rtt
+	} else {
+		// Exponential moving average, as in RFC 6298
+		c.rtt = (7*c.rtt + rtt) / 8
+	}
+	c.newRTT = true
+}
+
+// TargetBitrate updates the estimate with the feedback received since the
+// previous call and returns the new target.
+func (c *GCCController) TargetBitrate() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if total := c.acked + c.lost; total > 0 {
+		lossFrac := float64(c.lost) / float64(total)
+		c.loss = int(lossFrac*100 + 0.5)
+		switch {
+		case lossFrac > 0.10:
+			c.bitrate = int(float64(c.bitrate) * (1 - 0.5*lossFrac))
+		case lossFrac < 0.02:
+			c.bitrate = int(float64(c.bitrate) * 1.05)
+		}
+		c.acked = 0
+		c.lost = 0
+	}
+	// Back off once per RTT sample, not on every call, or polling without
+	// feedback would drive the bitrate down to the minimum.
+	if c.newRTT && c.rtt > c.minRTT*3/2 {
+		c.bitrate = c.bitrate * 85 / 100
+	}
+	c.newRTT = false
+	if c.bitrate < c.min {
+		c.bitrate = c.min
+	}
+	if c.bitrate > c.max {
+		c.bitrate = c.max
+	}
+	return c.bitrate
+}
+
+// LossPerc returns the packet loss percentage observed over the last update
+// interval.
+func (c *GCCController) LossPerc() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loss
+}
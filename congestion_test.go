@@ -0,0 +1,117 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGCCController(t *testing.T) {
+	cc, err := NewGCCController(8000, 128000, 32000)
+	if err != nil {
+		t.Fatalf("Error creating controller: %v", err)
+	}
+	// Clean path: probe upwards
+	cc.OnAck(100)
+	if br := cc.TargetBitrate(); br <= 32000 {
+		t.Errorf("Expected bitrate increase on clean path, got %d", br)
+	}
+	if lp := cc.LossPerc(); lp != 0 {
+		t.Errorf("Unexpected loss percentage: %d", lp)
+	}
+	// Heavy loss: back off
+	before := cc.TargetBitrate()
+	cc.OnAck(70)
+	cc.OnLoss(30)
+	if br := cc.TargetBitrate(); br >= before {
+		t.Errorf("Expected bitrate decrease under loss: %d >= %d", br, before)
+	}
+	if lp := cc.LossPerc(); lp != 30 {
+		t.Errorf("Unexpected loss percentage. Got %d, expected 30", lp)
+	}
+	// Never below the floor
+	for i := 0; i < 100; i++ {
+		cc.OnLoss(100)
+		cc.TargetBitrate()
+	}
+	if br := cc.TargetBitrate(); br != 8000 {
+		t.Errorf("Expected bitrate clamped to 8000, got %d", br)
+	}
+}
+
+func TestGCCControllerRTT(t *testing.T) {
+	cc, err := NewGCCController(8000, 128000, 64000)
+	if err != nil {
+		t.Fatalf("Error creating controller: %v", err)
+	}
+	cc.OnRTT(20 * time.Millisecond)
+	for i := 0; i < 20; i++ {
+		cc.OnRTT(200 * time.Millisecond)
+	}
+	br := cc.TargetBitrate()
+	if br >= 64000 {
+		t.Errorf("Expected bitrate decrease on rising RTT, got %d", br)
+	}
+	// Without new feedback, the estimate must hold
+	for i := 0; i < 10; i++ {
+		if again := cc.TargetBitrate(); again != br {
+			t.Fatalf("Expected bitrate to stay at %d without feedback, got %d", br, again)
+		}
+	}
+	cc.OnRTT(200 * time.Millisecond)
+	if again := cc.TargetBitrate(); again >= br {
+		t.Errorf("Expected bitrate decrease on a new RTT sample, got %d", again)
+	}
+}
+
+func TestGCCControllerInvalid(t *testing.T) {
+	if _, err := NewGCCController(0, 1000, 500); err == nil {
+		t.Errorf("Expected error for zero minimum bitrate")
+	}
+	if _, err := NewGCCController(1000, 500, 700); err == nil {
+		t.Errorf("Expected error for max < min")
+	}
+	if _, err := NewGCCController(1000, 5000, 6000); err == nil {
+		t.Errorf("Expected error for start outside range")
+	}
+}
+
+func TestEncoder_ApplyCongestionControl(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	cc, err := NewGCCController(8000, 128000, 32000)
+	if err != nil {
+		t.Fatalf("Error creating controller: %v", err)
+	}
+	cc.OnAck(80)
+	cc.OnLoss(20)
+	if err := enc.ApplyCongestionControl(cc); err != nil {
+		t.Fatalf("Error applying congestion control: %v", err)
+	}
+	br, err := enc.Bitrate()
+	if err != nil {
+		t.Fatalf("Error getting bitrate: %v", err)
+	}
+	if br != cc.TargetBitrate() {
+		t.Errorf("Encoder bitrate %d doesn't match target %d", br, cc.TargetBitrate())
+	}
+	lp, err := enc.PacketLossPerc()
+	if err != nil {
+		t.Fatalf("Error getting loss percentage: %v", err)
+	}
+	if lp != 20 {
+		t.Errorf("Unexpected loss percentage. Got %d, expected 20", lp)
+	}
+	fec, err := enc.InBandFEC()
+	if err != nil {
+		t.Fatalf("Error getting fec: %v", err)
+	}
+	if !fec {
+		t.Errorf("Expected FEC to be enabled under loss")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"runtime"
+)
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+
+int
+bridge_encoder_ctl_set_int32(OpusEncoder *st, int request, opus_int32 value)
+{
+	return opus_encoder_ctl(st, request, value);
+}
+
+int
+bridge_encoder_ctl_get_int32(OpusEncoder *st, int request, opus_int32 *value)
+{
+	return opus_encoder_ctl(st, request, value);
+}
+
+int
+bridge_decoder_ctl_set_int32(OpusDecoder *st, int request, opus_int32 value)
+{
+	return opus_decoder_ctl(st, request, value);
+}
+
+int
+bridge_decoder_ctl_get_int32(OpusDecoder *st, int request, opus_int32 *value)
+{
+	return opus_decoder_ctl(st, request, value);
+}
+*/
+import "C"
+
+// The CTL escape hatch gives access to requests of newer libopus versions
+// which this package doesn't wrap yet, by their number from opus_defines.h.
+// Only requests with a single opus_int32 argument are supported, and libopus
+// can't tell the argument types apart: passing a value where it expects a
+// pointer, or the reverse, corrupts memory. It is up to the caller to only use
+// suitable requests. As a safeguard, requests known to take other arguments
+// are rejected, as are setters with odd numbers and getters with even ones,
+// libopus numbering its requests that way.
+
+// ctlDenied lists the requests known not to take a single opus_int32.
+var ctlDenied = map[int]bool{
+	// CELT_GET_MODE: takes a pointer to a pointer
+	10015: true,
+	// OPUS_SET_ENERGY_MASK: takes a pointer to an array
+	10026: true,
+	// OPUS_SET_DNN_BLOB: takes a pointer and a length
+	4052: true,
+	// OPUS_MULTISTREAM_GET_ENCODER_STATE and _DECODER_STATE: take a stream
+	// index and a pointer
+	5120: true,
+	5122: true,
+}
+
+func checkCtlRequest(request int, get bool) error {
+	if ctlDenied[request] {
+		return fmt.Errorf("opus: CTL request %d doesn't take a single opus_int32", request)
+	}
+	if get != (request%2 == 1) {
+		kind := "setter"
+		if get {
+			kind = "getter"
+		}
+		return fmt.Errorf("opus: CTL request %d is not a %s", request, kind)
+	}
+	return nil
+}
+
+// CtlSetInt32 sends a setter request (an OPUS_SET_*_REQUEST number) with the
+// given value to the encoder.
+func (enc *Encoder) CtlSetInt32(request int, value int32) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	if err := checkCtlRequest(request, false); err != nil {
+		return err
+	}
+	res := C.bridge_encoder_ctl_set_int32(enc.p, C.int(request), C.opus_int32(value))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// CtlGetInt32 sends a getter request (an OPUS_GET_*_REQUEST number) to the
+// encoder and returns the value.
+func (enc *Encoder) CtlGetInt32(request int) (int32, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	if err := checkCtlRequest(request, true); err != nil {
+		return 0, err
+	}
+	res := C.bridge_encoder_ctl_get_int32(enc.p, C.int(request), &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return int32(enc.ctl), nil
+}
+
+// CtlSetInt32 sends a setter request (an OPUS_SET_*_REQUEST number) with the
+// given value to the decoder.
+func (dec *Decoder) CtlSetInt32(request int, value int32) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	if err := checkCtlRequest(request, false); err != nil {
+		return err
+	}
+	res := C.bridge_decoder_ctl_set_int32(dec.p, C.int(request), C.opus_int32(value))
+	runtime.KeepAlive(dec)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// CtlGetInt32 sends a getter request (an OPUS_GET_*_REQUEST number) to the
+// decoder and returns the value.
+func (dec *Decoder) CtlGetInt32(request int) (int32, error) {
+	if dec.p == nil {
+		return 0, dec.errUninitialized()
+	}
+	if err := checkCtlRequest(request, true); err != nil {
+		return 0, err
+	}
+	res := C.bridge_decoder_ctl_get_int32(dec.p, C.int(request), &dec.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return int32(dec.ctl), nil
+}
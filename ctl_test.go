@@ -0,0 +1,85 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import "testing"
+
+// Request numbers from opus_defines.h
+const (
+	OPUS_SET_COMPLEXITY_REQUEST  = 4010
+	OPUS_GET_COMPLEXITY_REQUEST  = 4011
+	OPUS_SET_GAIN_REQUEST        = 4034
+	OPUS_GET_GAIN_REQUEST        = 4045
+	OPUS_SET_DNN_BLOB_REQUEST    = 4052
+	OPUS_SET_ENERGY_MASK_REQUEST = 10026
+)
+
+func TestEncoderCtl(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.CtlSetInt32(OPUS_SET_COMPLEXITY_REQUEST, 4); err != nil {
+		t.Fatalf("Error setting complexity through CTL: %v", err)
+	}
+	cpx, err := enc.Complexity()
+	if err != nil {
+		t.Fatalf("Error getting complexity: %v", err)
+	}
+	if cpx != 4 {
+		t.Errorf("Expected complexity 4, got %d", cpx)
+	}
+	v, err := enc.CtlGetInt32(OPUS_GET_COMPLEXITY_REQUEST)
+	if err != nil {
+		t.Fatalf("Error getting complexity through CTL: %v", err)
+	}
+	if v != 4 {
+		t.Errorf("Expected complexity 4 through CTL, got %d", v)
+	}
+	if err := enc.CtlSetInt32(OPUS_SET_COMPLEXITY_REQUEST, 11); err == nil {
+		t.Errorf("Expected error setting an invalid complexity")
+	}
+	// Mixing up setters and getters is refused before reaching libopus
+	if err := enc.CtlSetInt32(OPUS_GET_COMPLEXITY_REQUEST, 4); err == nil {
+		t.Errorf("Expected error using a getter as a setter")
+	}
+	if _, err := enc.CtlGetInt32(OPUS_SET_COMPLEXITY_REQUEST); err == nil {
+		t.Errorf("Expected error using a setter as a getter")
+	}
+	// Even setters which take pointers
+	for _, req := range []int{OPUS_SET_DNN_BLOB_REQUEST, OPUS_SET_ENERGY_MASK_REQUEST} {
+		if err := enc.CtlSetInt32(req, 0); err == nil {
+			t.Errorf("Expected error for CTL request %d", req)
+		}
+	}
+}
+
+func TestDecoderCtl(t *testing.T) {
+	dec, err := NewDecoder(48000, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.CtlSetInt32(OPUS_SET_GAIN_REQUEST, -256); err != nil {
+		t.Fatalf("Error setting gain through CTL: %v", err)
+	}
+	gain, err := dec.CtlGetInt32(OPUS_GET_GAIN_REQUEST)
+	if err != nil {
+		t.Fatalf("Error getting gain through CTL: %v", err)
+	}
+	if gain != -256 {
+		t.Errorf("Expected gain -256, got %d", gain)
+	}
+}
+
+func TestCtlUninitialized(t *testing.T) {
+	var enc Encoder
+	if err := enc.CtlSetInt32(OPUS_SET_COMPLEXITY_REQUEST, 4); err != errEncUninitialized {
+		t.Errorf("Expected \"unitialized encoder\" error: %v", err)
+	}
+	var dec Decoder
+	if _, err := dec.CtlGetInt32(OPUS_GET_GAIN_REQUEST); err != errDecUninitialized {
+		t.Errorf("Expected \"unitialized decoder\" error: %v", err)
+	}
+}
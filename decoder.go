@@ -13,22 +13,130 @@ import (
 #cgo pkg-config: opus
 #include <opus.h>
 
+int
+bridge_decoder_get_sample_rate(OpusDecoder *st, opus_int32 *sample_rate)
+{
+	return opus_decoder_ctl(st, OPUS_GET_SAMPLE_RATE(sample_rate));
+}
+
 int
 bridge_decoder_get_last_packet_duration(OpusDecoder *st, opus_int32 *samples)
 {
 	return opus_decoder_ctl(st, OPUS_GET_LAST_PACKET_DURATION(samples));
 }
+
+int
+bridge_decoder_get_final_range(OpusDecoder *st, opus_int32 *final_range)
+{
+	// Read into the opus_int32 CTL result field, which has the same size
+	return opus_decoder_ctl(st, OPUS_GET_FINAL_RANGE((opus_uint32 *)final_range));
+}
+
+// Decode a series of packets, stored back to back in data, in one go. Stops
+// at the first error and returns it. The number of samples (per channel)
+// decoded up to that point is always stored in *total, split into those from
+// actual packets in *decoded and those from PLC in *concealed.
+//
+// Lost packets (length 0) are concealed for the duration of the last packet,
+// or of the next one at the start of the batch. PLC fills all the room it is
+// given, so it must not get the whole rest of the buffer.
+int
+bridge_decoder_decode_batch(OpusDecoder *st, const unsigned char *data,
+	const opus_int32 *lens, int npackets, opus_int16 *pcm, int frame_size,
+	int channels, int *total, int *decoded, int *concealed)
+{
+	int i, j, n, res;
+	opus_int32 fs, lost;
+	const unsigned char *next;
+	*total = 0;
+	*decoded = 0;
+	*concealed = 0;
+	for (i = 0; i < npackets; i++) {
+		n = frame_size - *total;
+		if (lens[i] == 0) {
+			res = opus_decoder_ctl(st, OPUS_GET_LAST_PACKET_DURATION(&lost));
+			if (res != OPUS_OK) {
+				return res;
+			}
+			next = data;
+			for (j = i + 1; lost == 0 && j < npackets; j++) {
+				if (lens[j] > 0) {
+					lost = opus_decoder_get_nb_samples(st, next, lens[j]);
+					if (lost < 0) {
+						return lost;
+					}
+				}
+				next += lens[j];
+			}
+			if (lost == 0) {
+				res = opus_decoder_ctl(st, OPUS_GET_SAMPLE_RATE(&fs));
+				if (res != OPUS_OK) {
+					return res;
+				}
+				lost = fs / 50;
+			}
+			if (lost < n) {
+				n = lost;
+			}
+		}
+		n = opus_decode(st, lens[i] ? data : NULL, lens[i],
+			pcm + *total * channels, n, 0);
+		if (n < 0) {
+			return n;
+		}
+		data += lens[i];
+		*total += n;
+		if (lens[i]) {
+			*decoded += n;
+		} else {
+			*concealed += n;
+		}
+	}
+	return OPUS_OK;
+}
 */
 import "C"
 
 var errDecUninitialized = fmt.Errorf("opus decoder uninitialized")
 
+// See the encoder's errors of the per frame path.
+var (
+	errTargetEmpty    = fmt.Errorf("opus: target buffer empty")
+	errTargetChannels = fmt.Errorf("opus: target buffer capacity must be multiple of channels")
+	errOutputChannels = fmt.Errorf("opus: output buffer capacity must be multiple of channels")
+)
+
 type Decoder struct {
 	p *C.struct_OpusDecoder
-	// Same purpose as encoder struct
+	// Same purpose as encoder struct. Nil if the decoder lives on the C heap,
+	// see NewDecoderCHeap.
 	mem         []byte
 	sample_rate int
 	channels    int
+	// Only set in lifecycle debug mode
+	debug *lifecycleRecord
+	// Detects concurrent use, see EnableConcurrencyCheck
+	guard guard
+	// See ConcealStats
+	conceal concealTracker
+	// See SetConcealPolicy
+	policy       ConcealPolicy
+	lastStrategy ConcealStrategy
+	// Soft clipping state per channel, nil if disabled. See SetSoftClip
+	softClipMem []float32
+	// Only set when timing, see EnableTiming
+	timing *callTimer
+	// Result of CTL getters, see Encoder
+	ctl C.opus_int32
+	// Samples before conversion, see DecodeBytes
+	bytesBuf []int16
+	// Output channels, nil for all. See SetChannelMap
+	channelMap []int
+	// See SetMonoOutput and SetStereoOutput
+	mono   bool
+	stereo bool
+	// Set by Close, see ErrClosed
+	closed bool
 }
 
 // NewDecoder allocates a new Opus decoder and initializes it with the
@@ -39,19 +147,51 @@ func NewDecoder(sample_rate int, channels int) (*Decoder, error) {
 	if err != nil {
 		return nil, err
 	}
+	dec.track()
 	return &dec, nil
 }
 
+// NewDecoderCHeap is like NewDecoder, but allocates the decoder state on the C
+// heap, for the same reasons as NewEncoderCHeap. The caller is responsible for
+// calling Close to free the memory.
+func NewDecoderCHeap(sample_rate int, channels int) (*Decoder, error) {
+	if err := validateSampleRate(sample_rate); err != nil {
+		return nil, err
+	}
+	if err := validateChannels(channels); err != nil {
+		return nil, err
+	}
+	var errno C.int
+	p := C.opus_decoder_create(
+		C.opus_int32(sample_rate),
+		C.int(channels),
+		&errno)
+	if errno != 0 {
+		return nil, opusError(int(errno))
+	}
+	dec := &Decoder{
+		p:           p,
+		sample_rate: sample_rate,
+		channels:    channels,
+	}
+	dec.track()
+	return dec, nil
+}
+
 func (dec *Decoder) Init(sample_rate int, channels int) error {
 	if dec.p != nil {
 		return fmt.Errorf("opus decoder already initialized")
 	}
-	if channels != 1 && channels != 2 {
-		return fmt.Errorf("Number of channels must be 1 or 2: %d", channels)
+	if err := validateSampleRate(sample_rate); err != nil {
+		return err
+	}
+	if err := validateChannels(channels); err != nil {
+		return err
 	}
 	size := C.opus_decoder_get_size(C.int(channels))
 	dec.sample_rate = sample_rate
 	dec.channels = channels
+	dec.closed = false
 	dec.mem = make([]byte, size)
 	dec.p = (*C.OpusDecoder)(unsafe.Pointer(&dec.mem[0]))
 	errno := C.opus_decoder_init(
@@ -59,7 +199,44 @@ func (dec *Decoder) Init(sample_rate int, channels int) error {
 		C.opus_int32(sample_rate),
 		C.int(channels))
 	if errno != 0 {
-		return Error(errno)
+		return opusError(int(errno))
+	}
+	return nil
+}
+
+// reinit puts the decoder back into the state of a freshly initialized one,
+// without allocating.
+func (dec *Decoder) reinit() error {
+	errno := C.opus_decoder_init(
+		dec.p,
+		C.opus_int32(dec.sample_rate),
+		C.int(dec.channels))
+	if errno != 0 {
+		return opusError(int(errno))
+	}
+	dec.conceal = concealTracker{}
+	dec.policy = ConcealPolicy{}
+	dec.lastStrategy = ConcealPLC
+	dec.timing = nil
+	dec.softClipMem = nil
+	dec.channelMap = nil
+	dec.mono = false
+	dec.stereo = false
+	return nil
+}
+
+// validateBufferSize checks that a buffer with room for the given number of
+// samples per channel is large enough for the packet.
+func (dec *Decoder) validateBufferSize(data []byte, samples int) error {
+	n := int(C.opus_decoder_get_nb_samples(
+		dec.p,
+		(*C.uchar)(&data[0]),
+		C.opus_int32(len(data))))
+	if n < 0 {
+		return opusError(n)
+	}
+	if n > samples {
+		return fmt.Errorf("opus: target buffer too small: packet decodes to %d samples per channel, buffer has room for %d", n, samples)
 	}
 	return nil
 }
@@ -68,27 +245,39 @@ func (dec *Decoder) Init(sample_rate int, channels int) error {
 // number of samples correctly written to the target buffer.
 func (dec *Decoder) Decode(data []byte, pcm []int16) (int, error) {
 	if dec.p == nil {
-		return 0, errDecUninitialized
+		return 0, dec.errUninitialized()
+	}
+	if !dec.guard.enter() {
+		return 0, ErrConcurrentUse
 	}
+	defer dec.guard.leave()
+	dec.debug.touch()
 	if len(data) == 0 {
-		return 0, fmt.Errorf("opus: no data supplied")
+		return 0, errNoData
 	}
 	if len(pcm) == 0 {
-		return 0, fmt.Errorf("opus: target buffer empty")
+		return 0, errTargetEmpty
+	}
+	if cap(pcm)%dec.bufChannels() != 0 {
+		return 0, errTargetChannels
 	}
-	if cap(pcm)%dec.channels != 0 {
-		return 0, fmt.Errorf("opus: target buffer capacity must be multiple of channels")
+	if err := dec.validateBufferSize(data, cap(pcm)/dec.bufChannels()); err != nil {
+		return 0, err
 	}
+	start := dec.timing.start()
 	n := int(C.opus_decode(
 		dec.p,
 		(*C.uchar)(&data[0]),
 		C.opus_int32(len(data)),
 		(*C.opus_int16)(&pcm[0]),
-		C.int(cap(pcm)/dec.channels),
+		C.int(cap(pcm)/dec.bufChannels()),
 		0))
+	dec.timing.stop(start, dec.sample_rate, n)
 	if n < 0 {
-		return 0, Error(n)
+		return 0, opusError(n)
 	}
+	dec.remap(pcm, n)
+	dec.conceal.decoded(n)
 	return n, nil
 }
 
@@ -96,27 +285,40 @@ func (dec *Decoder) Decode(data []byte, pcm []int16) (int, error) {
 // number of samples correctly written to the target buffer.
 func (dec *Decoder) DecodeFloat32(data []byte, pcm []float32) (int, error) {
 	if dec.p == nil {
-		return 0, errDecUninitialized
+		return 0, dec.errUninitialized()
 	}
+	if !dec.guard.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer dec.guard.leave()
+	dec.debug.touch()
 	if len(data) == 0 {
-		return 0, fmt.Errorf("opus: no data supplied")
+		return 0, errNoData
 	}
 	if len(pcm) == 0 {
-		return 0, fmt.Errorf("opus: target buffer empty")
+		return 0, errTargetEmpty
+	}
+	if cap(pcm)%dec.bufChannels() != 0 {
+		return 0, errTargetChannels
 	}
-	if cap(pcm)%dec.channels != 0 {
-		return 0, fmt.Errorf("opus: target buffer capacity must be multiple of channels")
+	if err := dec.validateBufferSize(data, cap(pcm)/dec.bufChannels()); err != nil {
+		return 0, err
 	}
+	start := dec.timing.start()
 	n := int(C.opus_decode_float(
 		dec.p,
 		(*C.uchar)(&data[0]),
 		C.opus_int32(len(data)),
 		(*C.float)(&pcm[0]),
-		C.int(cap(pcm)/dec.channels),
+		C.int(cap(pcm)/dec.bufChannels()),
 		0))
+	dec.timing.stop(start, dec.sample_rate, n)
 	if n < 0 {
-		return 0, Error(n)
+		return 0, opusError(n)
 	}
+	dec.softClip(pcm, n)
+	dec.remapFloat32(pcm, n)
+	dec.conceal.decoded(n)
 	return n, nil
 }
 
@@ -137,27 +339,39 @@ func (dec *Decoder) DecodeFloat32(data []byte, pcm []float32) (int, error) {
 // available in the provided packet.
 func (dec *Decoder) DecodeFEC(data []byte, pcm []int16) error {
 	if dec.p == nil {
-		return errDecUninitialized
+		return dec.errUninitialized()
+	}
+	if !dec.guard.enter() {
+		return ErrConcurrentUse
 	}
+	defer dec.guard.leave()
+	dec.debug.touch()
 	if len(data) == 0 {
-		return fmt.Errorf("opus: no data supplied")
+		return errNoData
 	}
 	if len(pcm) == 0 {
-		return fmt.Errorf("opus: target buffer empty")
+		return errTargetEmpty
 	}
-	if cap(pcm)%dec.channels != 0 {
-		return fmt.Errorf("opus: target buffer capacity must be multiple of channels")
+	if cap(pcm)%dec.bufChannels() != 0 {
+		return errTargetChannels
 	}
+	if err := validateConcealSize(dec.sample_rate, cap(pcm)/dec.bufChannels()); err != nil {
+		return err
+	}
+	start := dec.timing.start()
 	n := int(C.opus_decode(
 		dec.p,
 		(*C.uchar)(&data[0]),
 		C.opus_int32(len(data)),
 		(*C.opus_int16)(&pcm[0]),
-		C.int(cap(pcm)/dec.channels),
+		C.int(cap(pcm)/dec.bufChannels()),
 		1))
+	dec.timing.stop(start, dec.sample_rate, n)
 	if n < 0 {
-		return Error(n)
+		return opusError(n)
 	}
+	dec.remap(pcm, n)
+	dec.conceal.fec(n)
 	return nil
 }
 
@@ -166,27 +380,40 @@ func (dec *Decoder) DecodeFEC(data []byte, pcm []int16) error {
 // The supplied buffer needs to be exactly the duration of audio that is missing
 func (dec *Decoder) DecodeFECFloat32(data []byte, pcm []float32) error {
 	if dec.p == nil {
-		return errDecUninitialized
+		return dec.errUninitialized()
+	}
+	if !dec.guard.enter() {
+		return ErrConcurrentUse
 	}
+	defer dec.guard.leave()
+	dec.debug.touch()
 	if len(data) == 0 {
-		return fmt.Errorf("opus: no data supplied")
+		return errNoData
 	}
 	if len(pcm) == 0 {
-		return fmt.Errorf("opus: target buffer empty")
+		return errTargetEmpty
+	}
+	if cap(pcm)%dec.bufChannels() != 0 {
+		return errTargetChannels
 	}
-	if cap(pcm)%dec.channels != 0 {
-		return fmt.Errorf("opus: target buffer capacity must be multiple of channels")
+	if err := validateConcealSize(dec.sample_rate, cap(pcm)/dec.bufChannels()); err != nil {
+		return err
 	}
+	start := dec.timing.start()
 	n := int(C.opus_decode_float(
 		dec.p,
 		(*C.uchar)(&data[0]),
 		C.opus_int32(len(data)),
 		(*C.float)(&pcm[0]),
-		C.int(cap(pcm)/dec.channels),
+		C.int(cap(pcm)/dec.bufChannels()),
 		1))
+	dec.timing.stop(start, dec.sample_rate, n)
 	if n < 0 {
-		return Error(n)
+		return opusError(n)
 	}
+	dec.softClip(pcm, n)
+	dec.remapFloat32(pcm, n)
+	dec.conceal.fec(n)
 	return nil
 }
 
@@ -204,24 +431,36 @@ func (dec *Decoder) DecodeFECFloat32(data []byte, pcm []float32) error {
 // packet, not from the next one.
 func (dec *Decoder) DecodePLC(pcm []int16) error {
 	if dec.p == nil {
-		return errDecUninitialized
+		return dec.errUninitialized()
 	}
+	if !dec.guard.enter() {
+		return ErrConcurrentUse
+	}
+	defer dec.guard.leave()
+	dec.debug.touch()
 	if len(pcm) == 0 {
-		return fmt.Errorf("opus: target buffer empty")
+		return errTargetEmpty
+	}
+	if cap(pcm)%dec.bufChannels() != 0 {
+		return errOutputChannels
 	}
-	if cap(pcm)%dec.channels != 0 {
-		return fmt.Errorf("opus: output buffer capacity must be multiple of channels")
+	if err := validateConcealSize(dec.sample_rate, cap(pcm)/dec.bufChannels()); err != nil {
+		return err
 	}
+	start := dec.timing.start()
 	n := int(C.opus_decode(
 		dec.p,
 		nil,
 		0,
 		(*C.opus_int16)(&pcm[0]),
-		C.int(cap(pcm)/dec.channels),
+		C.int(cap(pcm)/dec.bufChannels()),
 		0))
+	dec.timing.stop(start, dec.sample_rate, n)
 	if n < 0 {
-		return Error(n)
+		return opusError(n)
 	}
+	dec.remap(pcm, n)
+	dec.conceal.plc(dec.sample_rate, n)
 	return nil
 }
 
@@ -229,34 +468,210 @@ func (dec *Decoder) DecodePLC(pcm []int16) error {
 // The supplied buffer needs to be exactly the duration of audio that is missing.
 func (dec *Decoder) DecodePLCFloat32(pcm []float32) error {
 	if dec.p == nil {
-		return errDecUninitialized
+		return dec.errUninitialized()
+	}
+	if !dec.guard.enter() {
+		return ErrConcurrentUse
 	}
+	defer dec.guard.leave()
+	dec.debug.touch()
 	if len(pcm) == 0 {
-		return fmt.Errorf("opus: target buffer empty")
+		return errTargetEmpty
 	}
-	if cap(pcm)%dec.channels != 0 {
-		return fmt.Errorf("opus: output buffer capacity must be multiple of channels")
+	if cap(pcm)%dec.bufChannels() != 0 {
+		return errOutputChannels
 	}
+	if err := validateConcealSize(dec.sample_rate, cap(pcm)/dec.bufChannels()); err != nil {
+		return err
+	}
+	start := dec.timing.start()
 	n := int(C.opus_decode_float(
 		dec.p,
 		nil,
 		0,
 		(*C.float)(&pcm[0]),
-		C.int(cap(pcm)/dec.channels),
+		C.int(cap(pcm)/dec.bufChannels()),
 		0))
+	dec.timing.stop(start, dec.sample_rate, n)
 	if n < 0 {
-		return Error(n)
+		return opusError(n)
+	}
+	dec.softClip(pcm, n)
+	dec.remapFloat32(pcm, n)
+	dec.conceal.plc(dec.sample_rate, n)
+	return nil
+}
+
+// SetSoftClip enables soft clipping of the output of DecodeFloat32 and the
+// other float32 variants. Decoded audio can exceed the range [-1, 1], e.g.
+// for loud masters; soft clipping bends it back smoothly instead of leaving it
+// to hard clip on conversion to integer samples later. The int16 variants
+// always clip internally.
+func (dec *Decoder) SetSoftClip(enable bool) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	if !enable {
+		dec.softClipMem = nil
+	} else if dec.softClipMem == nil {
+		dec.softClipMem = make([]float32, dec.channels)
 	}
 	return nil
 }
 
+// softClip applies soft clipping to n samples per channel, if enabled.
+func (dec *Decoder) softClip(pcm []float32, n int) {
+	if dec.softClipMem == nil || n == 0 {
+		return
+	}
+	C.opus_pcm_soft_clip(
+		(*C.float)(&pcm[0]),
+		C.int(n),
+		C.int(dec.channels),
+		(*C.float)(&dec.softClipMem[0]))
+}
+
+// SampleRate returns the decoder sample rate in Hz.
+func (dec *Decoder) SampleRate() (int, error) {
+	if dec.p == nil {
+		return 0, dec.errUninitialized()
+	}
+	res := C.bridge_decoder_get_sample_rate(dec.p, &dec.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return int(dec.ctl), nil
+}
+
+// Channels returns the number of channels the decoder outputs.
+func (dec *Decoder) Channels() (int, error) {
+	if dec.p == nil {
+		return 0, dec.errUninitialized()
+	}
+	return dec.channels, nil
+}
+
 // LastPacketDuration gets the duration (in samples)
 // of the last packet successfully decoded or concealed.
 func (dec *Decoder) LastPacketDuration() (int, error) {
-	var samples C.opus_int32
-	res := C.bridge_decoder_get_last_packet_duration(dec.p, &samples)
+	if dec.p == nil {
+		return 0, dec.errUninitialized()
+	}
+	res := C.bridge_decoder_get_last_packet_duration(dec.p, &dec.ctl)
 	if res != C.OPUS_OK {
-		return 0, Error(res)
+		return 0, opusError(int(res))
+	}
+	return int(dec.ctl), nil
+}
+
+// FinalRange returns the final state of the range coder after the last
+// decoded packet, see Encoder.FinalRange. It is undefined after concealment.
+func (dec *Decoder) FinalRange() (uint32, error) {
+	if dec.p == nil {
+		return 0, dec.errUninitialized()
+	}
+	res := C.bridge_decoder_get_final_range(dec.p, &dec.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return uint32(dec.ctl), nil
+}
+
+// DecodeBatch decodes a series of consecutive packets into the supplied
+// buffer using a single cgo call, which saves considerable overhead when
+// working through a large backlog of packets. Empty packets are treated as
+// lost and concealed with PLC, for the duration of the packet before them, or
+// of the one after them at the start of the batch.
+//
+// On success, returns the total number of samples (per channel) written. On
+// error, decoding stops at the offending packet and the returned count covers
+// the packets decoded before it.
+func (dec *Decoder) DecodeBatch(packets [][]byte, pcm []int16) (int, error) {
+	if dec.p == nil {
+		return 0, dec.errUninitialized()
+	}
+	if !dec.guard.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer dec.guard.leave()
+	dec.debug.touch()
+	if len(packets) == 0 {
+		return 0, errNoData
+	}
+	if len(pcm) == 0 {
+		return 0, errTargetEmpty
+	}
+	if cap(pcm)%dec.bufChannels() != 0 {
+		return 0, errTargetChannels
+	}
+	// C code may not hold on to Go pointers inside Go memory, so the packets
+	// are flattened into a single buffer first.
+	size := 0
+	for _, p := range packets {
+		size += len(p)
+	}
+	data := make([]byte, 0, size)
+	lens := make([]C.opus_int32, len(packets))
+	for i, p := range packets {
+		data = append(data, p...)
+		lens[i] = C.opus_int32(len(p))
+	}
+	var dataPtr *C.uchar
+	if size > 0 {
+		dataPtr = (*C.uchar)(&data[0])
+	}
+	var total, decoded, concealed C.int
+	start := dec.timing.start()
+	res := C.bridge_decoder_decode_batch(
+		dec.p,
+		dataPtr,
+		&lens[0],
+		C.int(len(packets)),
+		(*C.opus_int16)(&pcm[0]),
+		C.int(cap(pcm)/dec.bufChannels()),
+		C.int(dec.channels),
+		&total,
+		&decoded,
+		&concealed)
+	dec.timing.stop(start, dec.sample_rate, int(total))
+	dec.remap(pcm, int(total))
+	if res != C.OPUS_OK {
+		// Decoding stopped at a packet which failed, not at a lost one
+		dec.conceal.batch(int(decoded), int(concealed), false)
+		return int(total), opusError(int(res))
+	}
+	dec.conceal.batch(int(decoded), int(concealed), len(packets[len(packets)-1]) == 0)
+	return int(total), nil
+}
+
+// Close releases the decoder, see Encoder.Close.
+func (dec *Decoder) Close() error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	dec.release()
+	dec.debug.close()
+	dec.closed = true
+	return nil
+}
+
+// release is the decoder counterpart of Encoder.release.
+func (dec *Decoder) release() {
+	if dec.mem == nil {
+		C.opus_decoder_destroy(dec.p)
+	} else {
+		for i := range dec.mem {
+			dec.mem[i] = 0
+		}
+	}
+	dec.p = nil
+	dec.mem = nil
+}
+
+// errUninitialized is the error for using the decoder without state.
+func (dec *Decoder) errUninitialized() error {
+	if dec.closed {
+		return ErrClosed
 	}
-	return int(samples), nil
+	return errDecUninitialized
 }
@@ -31,6 +31,31 @@ func TestDecoderUnitialized(t *testing.T) {
 	}
 }
 
+func TestDecoder_SampleRateChannels(t *testing.T) {
+	for _, f := range []int{8000, 12000, 16000, 24000, 48000} {
+		for _, channels := range []int{1, 2} {
+			dec, err := NewDecoder(f, channels)
+			if err != nil || dec == nil {
+				t.Fatalf("Error creating new decoder (%d Hz, %d channels): %v", f, channels, err)
+			}
+			f2, err := dec.SampleRate()
+			if err != nil {
+				t.Fatalf("Error getting sample rate (%d Hz): %v", f, err)
+			}
+			if f2 != f {
+				t.Errorf("Unexpected sample rate reported by %d Hz decoder: %d", f, f2)
+			}
+			c, err := dec.Channels()
+			if err != nil {
+				t.Fatalf("Error getting channels (%d): %v", channels, err)
+			}
+			if c != channels {
+				t.Errorf("Unexpected channels reported by %d channel decoder: %d", channels, c)
+			}
+		}
+	}
+}
+
 func TestDecoder_GetLastPacketDuration(t *testing.T) {
 	const G4 = 391.995
 	const SAMPLE_RATE = 48000
@@ -66,3 +91,220 @@ func TestDecoder_GetLastPacketDuration(t *testing.T) {
 		t.Fatalf("Wrong duration length. Expected %d. Got %d", n, samples)
 	}
 }
+
+func TestDecoder_DecodeBatch(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const NUMBER_OF_FRAMES = 5
+	enc, err := NewEncoder(SAMPLE_RATE, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	left := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	right := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	addSine(left, SAMPLE_RATE, G4)
+	addSine(right, SAMPLE_RATE, G4/2)
+	pcm := interleave(left, right)
+	packets := make([][]byte, NUMBER_OF_FRAMES)
+	for i := range packets {
+		data := make([]byte, 1000)
+		n, err := enc.Encode(pcm[i*FRAME_SIZE*2:(i+1)*FRAME_SIZE*2], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		packets[i] = data[:n]
+	}
+
+	// Reference: one packet at a time
+	ref, err := NewDecoder(SAMPLE_RATE, 2)
+	if err != nil || ref == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	var expected []int16
+	for _, p := range packets {
+		buf := make([]int16, FRAME_SIZE*2)
+		n, err := ref.Decode(p, buf)
+		if err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		expected = append(expected, buf[:n*2]...)
+	}
+
+	dec, err := NewDecoder(SAMPLE_RATE, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	out := make([]int16, len(pcm))
+	n, err := dec.DecodeBatch(packets, out)
+	if err != nil {
+		t.Fatalf("Couldn't batch decode data: %v", err)
+	}
+	if n != FRAME_SIZE*NUMBER_OF_FRAMES {
+		t.Fatalf("Length mismatch: %d samples expected, %d out", FRAME_SIZE*NUMBER_OF_FRAMES, n)
+	}
+	if maxDiff(out, expected) != 0 {
+		t.Errorf("Batch decoding differs from decoding packet by packet")
+	}
+}
+
+func TestDecoder_DecodeBatchSmallBuffer(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	// Room for only one and a half frames
+	out := make([]int16, FRAME_SIZE*3/2)
+	n, err = dec.DecodeBatch([][]byte{data[:n], data[:n]}, out)
+	if err == nil {
+		t.Fatalf("Expected error for too small buffer")
+	}
+	if n != FRAME_SIZE {
+		t.Errorf("Expected first packet to be decoded, got %d samples", n)
+	}
+	// Stats cover the packets decoded before the failure
+	if stats := dec.ConcealStats(); stats.DecodedSamples != FRAME_SIZE {
+		t.Errorf("Expected %d decoded samples in stats, got %d", FRAME_SIZE, stats.DecodedSamples)
+	}
+}
+
+func TestDecoder_DecodeBatchLostPacket(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const NUMBER_OF_FRAMES = 5
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	addSine(pcm, SAMPLE_RATE, 440)
+	packets := make([][]byte, NUMBER_OF_FRAMES)
+	for i := range packets {
+		data := make([]byte, 1000)
+		n, err := enc.Encode(pcm[i*FRAME_SIZE:(i+1)*FRAME_SIZE], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		packets[i] = data[:n]
+	}
+	// Lose a packet in the middle
+	packets[2] = nil
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	// Plenty of room: concealment must not use more than one frame of it
+	out := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES*2)
+	n, err := dec.DecodeBatch(packets, out)
+	if err != nil {
+		t.Fatalf("Couldn't batch decode data: %v", err)
+	}
+	if n != FRAME_SIZE*NUMBER_OF_FRAMES {
+		t.Fatalf("Length mismatch: %d samples expected, %d out", FRAME_SIZE*NUMBER_OF_FRAMES, n)
+	}
+
+	stats := dec.ConcealStats()
+	if stats.DecodedSamples != FRAME_SIZE*(NUMBER_OF_FRAMES-1) || stats.ConcealedSamples != FRAME_SIZE {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Lost first packet: concealed for the duration of the next one
+	packets[0] = nil
+	dec, err = NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	n, err = dec.DecodeBatch(packets, out)
+	if err != nil {
+		t.Fatalf("Couldn't batch decode data: %v", err)
+	}
+	if n != FRAME_SIZE*NUMBER_OF_FRAMES {
+		t.Fatalf("Length mismatch: %d samples expected, %d out", FRAME_SIZE*NUMBER_OF_FRAMES, n)
+	}
+}
+
+func TestDecoderCHeap(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	dec, err := NewDecoderCHeap(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if n, err = dec.Decode(data[:n], pcm); err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	if n != FRAME_SIZE {
+		t.Errorf("Length mismatch: %d samples in, %d out", FRAME_SIZE, n)
+	}
+	if err := dec.Close(); err != nil {
+		t.Fatalf("Error closing decoder: %v", err)
+	}
+	if _, err := dec.Decode(data, pcm); err != ErrClosed {
+		t.Errorf("Expected \"closed\" error: %v", err)
+	}
+}
+
+func TestDecoder_SoftClip(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetSoftClip(true); err != nil {
+		t.Fatalf("Error enabling soft clipping: %v", err)
+	}
+	// A full scale square wave overshoots when decoded
+	pcm := make([]float32, FRAME_SIZE)
+	for i := range pcm {
+		pcm[i] = 1
+		if (i/48)%2 == 0 {
+			pcm[i] = -1
+		}
+	}
+	data := make([]byte, 1000)
+	for f := 0; f < 10; f++ {
+		n, err := enc.EncodeFloat32(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		out := make([]float32, FRAME_SIZE)
+		n, err = dec.DecodeFloat32(data[:n], out)
+		if err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		for i, s := range out[:n] {
+			if s > 1 || s < -1 {
+				t.Fatalf("Sample %d of frame %d not clipped: %v", i, f, s)
+			}
+		}
+	}
+}
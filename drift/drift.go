@@ -0,0 +1,158 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// Package drift compensates for clock drift between a sender and a receiver
+// of audio. The sender's sample clock and the local audio device never run at
+// exactly the same rate; over a long call the difference (typically tens of
+// ppm) slowly fills up or drains the receive buffer. A Compensator measures
+// the long-term skew between the two clocks and corrects it by resampling the
+// decoded audio by a tiny amount, which is inaudible.
+package drift
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxSkew is the largest correction applied, as a fraction: 0.005 is 5000
+// ppm. Anything beyond that isn't drift but a misconfigured sample rate or a
+// broken timestamp.
+const MaxSkew = 0.005
+
+// minSpan is the number of local samples (10 s at 48 kHz) that must be
+// observed before the skew estimate is trusted. Network jitter swamps the
+// drift on short timescales.
+const minSpan = 48000 * 10
+
+// Compensator estimates the skew between a remote and the local clock, and
+// resamples audio to correct it. It is not safe for concurrent use.
+type Compensator struct {
+	channels int
+	// Least squares fit of remote against local time, relative to the first
+	// observation to keep the sums precise
+	l0, r0           int64
+	n                float64
+	sx, sy, sxx, sxy float64
+	span             int64
+	observed         bool
+	skew             float64
+	// Resampling state: position of the next output sample in the current
+	// input buffer, and the last input frame of the previous buffer
+	pos     float64
+	last    []float64
+	started bool
+}
+
+// NewCompensator creates a compensator for interleaved audio with the given
+// number of channels.
+func NewCompensator(channels int) (*Compensator, error) {
+	if channels <= 0 {
+		return nil, fmt.Errorf("drift: invalid number of channels: %d", channels)
+	}
+	return &Compensator{
+		channels: channels,
+		skew:     1,
+		last:     make([]float64, channels),
+	}, nil
+}
+
+// Observe records a pair of clock readings, both in samples at the same
+// sample rate: local is the position of the local audio device (e.g. total
+// samples played), remote the sender's timestamp of the audio arriving at
+// that moment (e.g. an RTP timestamp, unwrapped). Call it regularly, e.g.
+// for every received packet.
+func (c *Compensator) Observe(local, remote int64) {
+	if !c.observed {
+		c.l0, c.r0 = local, remote
+		c.observed = true
+	}
+	x := float64(local - c.l0)
+	y := float64(remote - c.r0)
+	c.n++
+	c.sx += x
+	c.sy += y
+	c.sxx += x * x
+	c.sxy += x * y
+	if span := local - c.l0; span > c.span {
+		c.span = span
+	}
+	if c.span < minSpan {
+		return
+	}
+	den := c.n*c.sxx - c.sx*c.sx
+	if den == 0 {
+		return
+	}
+	skew := (c.n*c.sxy - c.sx*c.sy) / den
+	c.skew = math.Max(1-MaxSkew, math.Min(1+MaxSkew, skew))
+}
+
+// Skew returns the estimated rate of the remote clock relative to the local
+// one: above 1 means the sender produces audio faster than it is played back
+// locally. Returns 1 until enough observations have been made.
+func (c *Compensator) Skew() float64 {
+	return c.skew
+}
+
+// Process resamples interleaved audio to correct for the estimated skew,
+// returning approximately len(pcm)/Skew() samples. Consecutive calls are
+// treated as one continuous signal.
+func (c *Compensator) Process(pcm []int16) []int16 {
+	in := make([]float64, len(pcm))
+	for i, s := range pcm {
+		in[i] = float64(s)
+	}
+	out := c.process(in)
+	res := make([]int16, len(out))
+	for i, s := range out {
+		res[i] = int16(math.Round(s))
+	}
+	return res
+}
+
+// ProcessFloat32 is the same as Process, but for float32 audio.
+func (c *Compensator) ProcessFloat32(pcm []float32) []float32 {
+	in := make([]float64, len(pcm))
+	for i, s := range pcm {
+		in[i] = float64(s)
+	}
+	out := c.process(in)
+	res := make([]float32, len(out))
+	for i, s := range out {
+		res[i] = float32(s)
+	}
+	return res
+}
+
+// process resamples by linear interpolation. Input frame -1 is the last frame
+// of the previous call.
+func (c *Compensator) process(in []float64) []float64 {
+	ch := c.channels
+	n := len(in) / ch
+	if n == 0 {
+		return nil
+	}
+	if !c.started {
+		copy(c.last, in[:ch])
+		c.started = true
+	}
+	sample := func(i, k int) float64 {
+		if i < 0 {
+			return c.last[k]
+		}
+		return in[i*ch+k]
+	}
+	out := make([]float64, 0, int(float64(n)/c.skew+2)*ch)
+	for ; c.pos < float64(n-1); c.pos += c.skew {
+		i := int(math.Floor(c.pos))
+		frac := c.pos - float64(i)
+		for k := 0; k < ch; k++ {
+			a, b := sample(i, k), sample(i+1, k)
+			out = append(out, a+(b-a)*frac)
+		}
+	}
+	c.pos -= float64(n)
+	copy(c.last, in[(n-1)*ch:n*ch])
+	return out
+}
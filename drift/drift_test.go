@@ -0,0 +1,69 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package drift
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSkewEstimate(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const SKEW = 1.0001 // 100 ppm
+	c, err := NewCompensator(1)
+	if err != nil {
+		t.Fatalf("Error creating compensator: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	// One minute of 20 ms packets, with up to 30 ms of jitter
+	for i := int64(0); i < 3000; i++ {
+		local := i * SAMPLE_RATE / 50
+		jitter := rng.Int63n(SAMPLE_RATE * 30 / 1000)
+		remote := int64(float64(local)*SKEW) - jitter + 12345
+		c.Observe(local, remote)
+		if i == 10 && c.Skew() != 1 {
+			t.Errorf("Expected no estimate yet, got %v", c.Skew())
+		}
+	}
+	if ppm := (c.Skew() - SKEW) * 1e6; math.Abs(ppm) > 10 {
+		t.Errorf("Skew estimate off by %v ppm: %v", ppm, c.Skew())
+	}
+}
+
+func TestProcess(t *testing.T) {
+	c, err := NewCompensator(2)
+	if err != nil {
+		t.Fatalf("Error creating compensator: %v", err)
+	}
+	c.skew = 1.001
+	pcm := make([]int16, 2*960)
+	total := 0
+	for i := 0; i < 50; i++ {
+		for j := range pcm {
+			pcm[j] = int16(j % 2)
+		}
+		out := c.Process(pcm)
+		if len(out)%2 != 0 {
+			t.Fatalf("Output not interleaved: %d samples", len(out))
+		}
+		for j, s := range out {
+			if s != int16(j%2) {
+				t.Fatalf("Unexpected sample %d at %d", s, j)
+			}
+		}
+		total += len(out) / 2
+	}
+	want := 50 * 960 / 1.001
+	if math.Abs(float64(total)-want) > 2 {
+		t.Errorf("Expected about %v samples, got %d", want, total)
+	}
+}
+
+func TestNewCompensatorInvalid(t *testing.T) {
+	if _, err := NewCompensator(0); err == nil {
+		t.Errorf("Expected error for 0 channels")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// checkDuration verifies that a PCM buffer of the given length (interleaved)
+// holds exactly d worth of audio.
+func checkDuration(sample_rate int, channels int, length int, d time.Duration) error {
+	expected := FrameSamples(sample_rate, d) * channels
+	if length != expected {
+		return fmt.Errorf("opus: pcm buffer holds %v of audio (%d samples), expected %v (%d samples)",
+			SamplesDuration(sample_rate, length/channels), length, d, expected)
+	}
+	return nil
+}
+
+// EncodeDuration is like Encode, but additionally checks that the PCM data is
+// exactly d long. Most users think in frame durations, and this catches
+// mistakes in the sample arithmetic early, with a clear error.
+func (enc *Encoder) EncodeDuration(pcm []int16, d time.Duration, data []byte) (int, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	if err := checkDuration(enc.sample_rate, enc.channels, len(pcm), d); err != nil {
+		return 0, err
+	}
+	return enc.Encode(pcm, data)
+}
+
+// EncodeFloat32Duration is the float32 counterpart of EncodeDuration.
+func (enc *Encoder) EncodeFloat32Duration(pcm []float32, d time.Duration, data []byte) (int, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	if err := checkDuration(enc.sample_rate, enc.channels, len(pcm), d); err != nil {
+		return 0, err
+	}
+	return enc.EncodeFloat32(pcm, data)
+}
+
+// DecodeDuration is like Decode, but returns the duration of the decoded
+// audio instead of the number of samples.
+func (dec *Decoder) DecodeDuration(data []byte, pcm []int16) (time.Duration, error) {
+	n, err := dec.Decode(data, pcm)
+	if err != nil {
+		return 0, err
+	}
+	return SamplesDuration(dec.sample_rate, n), nil
+}
+
+// DecodeFloat32Duration is the float32 counterpart of DecodeDuration.
+func (dec *Decoder) DecodeFloat32Duration(data []byte, pcm []float32) (time.Duration, error) {
+	n, err := dec.DecodeFloat32(data, pcm)
+	if err != nil {
+		return 0, err
+	}
+	return SamplesDuration(dec.sample_rate, n), nil
+}
@@ -0,0 +1,54 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCodecDuration(t *testing.T) {
+	const SAMPLE_RATE = 16000
+	const CHANNELS = 2
+	const FRAME = 20 * time.Millisecond
+	enc, err := NewEncoder(SAMPLE_RATE, CHANNELS, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, CHANNELS)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	pcm := make([]int16, FrameSamples(SAMPLE_RATE, FRAME)*CHANNELS)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, 1000)
+	n, err := enc.EncodeDuration(pcm, FRAME, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	d, err := dec.DecodeDuration(data[:n], pcm)
+	if err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	if d != FRAME {
+		t.Errorf("Unexpected decoded duration. Got %v, expected %v", d, FRAME)
+	}
+	// Forgot that the buffer is interleaved stereo
+	if _, err := enc.EncodeDuration(pcm, 2*FRAME, data); err == nil {
+		t.Errorf("Expected error for mismatching duration")
+	}
+	if _, err := enc.EncodeFloat32Duration(make([]float32, len(pcm)), 10*time.Millisecond, data); err == nil {
+		t.Errorf("Expected error for mismatching duration")
+	}
+}
+
+func TestSamplesDuration(t *testing.T) {
+	if d := SamplesDuration(48000, 120); d != 2500*time.Microsecond {
+		t.Errorf("Unexpected duration: %v", d)
+	}
+	if d := SamplesDuration(8000, FrameSamples(8000, 60*time.Millisecond)); d != 60*time.Millisecond {
+		t.Errorf("Unexpected duration: %v", d)
+	}
+}
@@ -6,12 +6,16 @@ package opus
 
 import (
 	"fmt"
+	"runtime"
+	"time"
 	"unsafe"
 )
 
 /*
 #cgo pkg-config: opus
 #include <opus.h>
+#include <stdlib.h>
+#include <string.h>
 
 int
 bridge_encoder_set_dtx(OpusEncoder *st, opus_int32 use_dtx)
@@ -68,6 +72,18 @@ bridge_encoder_get_max_bandwidth(OpusEncoder *st, opus_int32 *max_bw)
 	return opus_encoder_ctl(st, OPUS_GET_MAX_BANDWIDTH(max_bw));
 }
 
+int
+bridge_encoder_set_bandwidth(OpusEncoder *st, opus_int32 bw)
+{
+	return opus_encoder_ctl(st, OPUS_SET_BANDWIDTH(bw));
+}
+
+int
+bridge_encoder_get_bandwidth(OpusEncoder *st, opus_int32 *bw)
+{
+	return opus_encoder_ctl(st, OPUS_GET_BANDWIDTH(bw));
+}
+
 int
 bridge_encoder_set_inband_fec(OpusEncoder *st, opus_int32 fec)
 {
@@ -92,6 +108,139 @@ bridge_encoder_get_packet_loss_perc(OpusEncoder *st, opus_int32 *loss_perc)
 	return opus_encoder_ctl(st, OPUS_GET_PACKET_LOSS_PERC(loss_perc));
 }
 
+int
+bridge_encoder_set_vbr(OpusEncoder *st, opus_int32 vbr)
+{
+	return opus_encoder_ctl(st, OPUS_SET_VBR(vbr));
+}
+
+int
+bridge_encoder_get_vbr(OpusEncoder *st, opus_int32 *vbr)
+{
+	return opus_encoder_ctl(st, OPUS_GET_VBR(vbr));
+}
+
+int
+bridge_encoder_set_vbr_constraint(OpusEncoder *st, opus_int32 constraint)
+{
+	return opus_encoder_ctl(st, OPUS_SET_VBR_CONSTRAINT(constraint));
+}
+
+int
+bridge_encoder_get_vbr_constraint(OpusEncoder *st, opus_int32 *constraint)
+{
+	return opus_encoder_ctl(st, OPUS_GET_VBR_CONSTRAINT(constraint));
+}
+
+int
+bridge_encoder_set_lsb_depth(OpusEncoder *st, opus_int32 depth)
+{
+	return opus_encoder_ctl(st, OPUS_SET_LSB_DEPTH(depth));
+}
+
+int
+bridge_encoder_get_lsb_depth(OpusEncoder *st, opus_int32 *depth)
+{
+	return opus_encoder_ctl(st, OPUS_GET_LSB_DEPTH(depth));
+}
+
+int
+bridge_encoder_set_phase_inversion_disabled(OpusEncoder *st, opus_int32 disabled)
+{
+	return opus_encoder_ctl(st, OPUS_SET_PHASE_INVERSION_DISABLED(disabled));
+}
+
+int
+bridge_encoder_get_phase_inversion_disabled(OpusEncoder *st, opus_int32 *disabled)
+{
+	return opus_encoder_ctl(st, OPUS_GET_PHASE_INVERSION_DISABLED(disabled));
+}
+
+int
+bridge_encoder_set_force_channels(OpusEncoder *st, opus_int32 channels)
+{
+	return opus_encoder_ctl(st, OPUS_SET_FORCE_CHANNELS(channels));
+}
+
+int
+bridge_encoder_get_force_channels(OpusEncoder *st, opus_int32 *channels)
+{
+	return opus_encoder_ctl(st, OPUS_GET_FORCE_CHANNELS(channels));
+}
+
+int
+bridge_encoder_set_expert_frame_duration(OpusEncoder *st, opus_int32 duration)
+{
+	return opus_encoder_ctl(st, OPUS_SET_EXPERT_FRAME_DURATION(duration));
+}
+
+int
+bridge_encoder_get_expert_frame_duration(OpusEncoder *st, opus_int32 *duration)
+{
+	return opus_encoder_ctl(st, OPUS_GET_EXPERT_FRAME_DURATION(duration));
+}
+
+int
+bridge_encoder_get_in_dtx(OpusEncoder *st, opus_int32 *in_dtx)
+{
+	return opus_encoder_ctl(st, OPUS_GET_IN_DTX(in_dtx));
+}
+
+int
+bridge_encoder_set_application(OpusEncoder *st, opus_int32 application)
+{
+	return opus_encoder_ctl(st, OPUS_SET_APPLICATION(application));
+}
+
+int
+bridge_encoder_get_application(OpusEncoder *st, opus_int32 *application)
+{
+	return opus_encoder_ctl(st, OPUS_GET_APPLICATION(application));
+}
+
+int
+bridge_encoder_get_final_range(OpusEncoder *st, opus_int32 *final_range)
+{
+	// Read into the opus_int32 CTL result field, which has the same size
+	return opus_encoder_ctl(st, OPUS_GET_FINAL_RANGE((opus_uint32 *)final_range));
+}
+
+int
+bridge_encoder_set_signal(OpusEncoder *st, opus_int32 signal)
+{
+	return opus_encoder_ctl(st, OPUS_SET_SIGNAL(signal));
+}
+
+int
+bridge_encoder_get_signal(OpusEncoder *st, opus_int32 *signal)
+{
+	return opus_encoder_ctl(st, OPUS_GET_SIGNAL(signal));
+}
+
+int
+bridge_encoder_get_lookahead(OpusEncoder *st, opus_int32 *lookahead)
+{
+	return opus_encoder_ctl(st, OPUS_GET_LOOKAHEAD(lookahead));
+}
+
+int
+bridge_encoder_set_prediction_disabled(OpusEncoder *st, opus_int32 disabled)
+{
+	return opus_encoder_ctl(st, OPUS_SET_PREDICTION_DISABLED(disabled));
+}
+
+int
+bridge_encoder_get_prediction_disabled(OpusEncoder *st, opus_int32 *disabled)
+{
+	return opus_encoder_ctl(st, OPUS_GET_PREDICTION_DISABLED(disabled));
+}
+
+int
+bridge_encoder_reset_state(OpusEncoder *st)
+{
+	return opus_encoder_ctl(st, OPUS_RESET_STATE);
+}
+
 */
 import "C"
 
@@ -110,15 +259,94 @@ const (
 	Fullband = Bandwidth(C.OPUS_BANDWIDTH_FULLBAND)
 )
 
+// Signal is a hint to the encoder about the kind of audio being encoded.
+type Signal int
+
+const (
+	// Let the encoder detect the kind of signal
+	SignalAuto = Signal(C.OPUS_AUTO)
+	// Bias the encoder towards SILK, for speech
+	SignalVoice = Signal(C.OPUS_SIGNAL_VOICE)
+	// Bias the encoder towards CELT, for music
+	SignalMusic = Signal(C.OPUS_SIGNAL_MUSIC)
+)
+
+// FrameDuration is the duration of the frames the encoder codes, see
+// SetExpertFrameDuration.
+type FrameDuration int
+
+const (
+	// Use the duration of the audio passed to Encode (the default)
+	FrameDurationArg   = FrameDuration(C.OPUS_FRAMESIZE_ARG)
+	FrameDuration2_5ms = FrameDuration(C.OPUS_FRAMESIZE_2_5_MS)
+	FrameDuration5ms   = FrameDuration(C.OPUS_FRAMESIZE_5_MS)
+	FrameDuration10ms  = FrameDuration(C.OPUS_FRAMESIZE_10_MS)
+	FrameDuration20ms  = FrameDuration(C.OPUS_FRAMESIZE_20_MS)
+	FrameDuration40ms  = FrameDuration(C.OPUS_FRAMESIZE_40_MS)
+	FrameDuration60ms  = FrameDuration(C.OPUS_FRAMESIZE_60_MS)
+	FrameDuration80ms  = FrameDuration(C.OPUS_FRAMESIZE_80_MS)
+	FrameDuration100ms = FrameDuration(C.OPUS_FRAMESIZE_100_MS)
+	FrameDuration120ms = FrameDuration(C.OPUS_FRAMESIZE_120_MS)
+)
+
+// Duration returns the frame duration as a time.Duration, or 0 for
+// FrameDurationArg and unknown values.
+func (fd FrameDuration) Duration() time.Duration {
+	i := int(fd - FrameDuration2_5ms)
+	if i < 0 || i >= len(validFrameDurations) {
+		return 0
+	}
+	return validFrameDurations[i]
+}
+
+// frameDurationOf returns the FrameDuration for d, FrameDurationArg for zero.
+func frameDurationOf(d time.Duration) (FrameDuration, error) {
+	if d == 0 {
+		return FrameDurationArg, nil
+	}
+	for i, v := range validFrameDurations {
+		if v == d {
+			return FrameDuration2_5ms + FrameDuration(i), nil
+		}
+	}
+	return 0, fmt.Errorf("opus: invalid frame duration: %v", d)
+}
+
 var errEncUninitialized = fmt.Errorf("opus encoder uninitialized")
 
+// Errors of the per frame path are allocated once, so that path never
+// allocates.
+var (
+	errNoData        = fmt.Errorf("opus: no data supplied")
+	errNoTarget      = fmt.Errorf("opus: no target buffer")
+	errInputChannels = fmt.Errorf("opus: input buffer length must be multiple of channels")
+)
+
 // Encoder contains the state of an Opus encoder for libopus.
 type Encoder struct {
-	p        *C.struct_OpusEncoder
-	channels int
+	p           *C.struct_OpusEncoder
+	sample_rate int
+	channels    int
 	// Memory for the encoder struct allocated on the Go heap to allow Go GC to
-	// manage it (and obviate need to free())
+	// manage it (and obviate need to free()). Nil if the encoder lives on the
+	// C heap, see NewEncoderCHeap.
 	mem []byte
+	// Only set in lifecycle debug mode
+	debug *lifecycleRecord
+	// Detects concurrent use, see EnableConcurrencyCheck
+	guard guard
+	// Only set when tracing, see EnableTrace
+	trace *encoderTrace
+	// Only set when timing, see EnableTiming
+	timing *callTimer
+	// Result of CTL getters. A field rather than a local, because locals
+	// passed to C escape to the heap.
+	ctl C.opus_int32
+	// Set by Close, see ErrClosed
+	closed bool
+	// Bandpass forced with SetBandwidth, zero if automatic. libopus only
+	// reports the bandpass in use.
+	forcedBandwidth Bandwidth
 }
 
 // NewEncoder allocates a new Opus encoder and initializes it with the
@@ -129,9 +357,36 @@ func NewEncoder(sample_rate int, channels int, application Application) (*Encode
 	if err != nil {
 		return nil, err
 	}
+	enc.track()
 	return &enc, nil
 }
 
+// NewEncoderCHeap is like NewEncoder, but allocates the encoder state on the C
+// heap instead of the Go heap. This keeps the (opaque, pointer free) state out
+// of the memory the Go GC has to scan and account for, which adds up for
+// applications running thousands of encoders. The caller is responsible for
+// calling Close to free the memory.
+func NewEncoderCHeap(sample_rate int, channels int, application Application) (*Encoder, error) {
+	if err := validateSampleRate(sample_rate); err != nil {
+		return nil, err
+	}
+	if err := validateChannels(channels); err != nil {
+		return nil, err
+	}
+	var errno C.int
+	p := C.opus_encoder_create(
+		C.opus_int32(sample_rate),
+		C.int(channels),
+		C.int(application),
+		&errno)
+	if errno != 0 {
+		return nil, opusError(int(errno))
+	}
+	enc := &Encoder{p: p, sample_rate: sample_rate, channels: channels}
+	enc.track()
+	return enc, nil
+}
+
 // Init initializes a pre-allocated opus encoder. Unless the encoder has been
 // created using NewEncoder, this method must be called exactly once in the
 // life-time of this object, before calling any other methods.
@@ -139,11 +394,17 @@ func (enc *Encoder) Init(sample_rate int, channels int, application Application)
 	if enc.p != nil {
 		return fmt.Errorf("opus encoder already initialized")
 	}
-	if channels != 1 && channels != 2 {
-		return fmt.Errorf("Number of channels must be 1 or 2: %d", channels)
+	if err := validateSampleRate(sample_rate); err != nil {
+		return err
+	}
+	if err := validateChannels(channels); err != nil {
+		return err
 	}
 	size := C.opus_encoder_get_size(C.int(channels))
+	enc.sample_rate = sample_rate
 	enc.channels = channels
+	enc.closed = false
+	enc.forcedBandwidth = 0
 	enc.mem = make([]byte, size)
 	enc.p = (*C.OpusEncoder)(unsafe.Pointer(&enc.mem[0]))
 	errno := int(C.opus_encoder_init(
@@ -152,7 +413,26 @@ func (enc *Encoder) Init(sample_rate int, channels int, application Application)
 		C.int(channels),
 		C.int(application)))
 	if errno != 0 {
-		return Error(int(errno))
+		return opusError(int(errno))
+	}
+	return nil
+}
+
+// reinit puts the encoder back into the state of a freshly initialized one,
+// including all settings, without allocating.
+func (enc *Encoder) reinit(sample_rate int, application Application) error {
+	enc.sample_rate = sample_rate
+	enc.forcedBandwidth = 0
+	// Measurements of the previous user
+	enc.trace = nil
+	enc.timing = nil
+	errno := int(C.opus_encoder_init(
+		enc.p,
+		C.opus_int32(sample_rate),
+		C.int(enc.channels),
+		C.int(application)))
+	if errno != 0 {
+		return opusError(int(errno))
 	}
 	return nil
 }
@@ -161,28 +441,41 @@ func (enc *Encoder) Init(sample_rate int, channels int, application Application)
 // returns the number of bytes used up by the encoded data.
 func (enc *Encoder) Encode(pcm []int16, data []byte) (int, error) {
 	if enc.p == nil {
-		return 0, errEncUninitialized
+		return 0, enc.errUninitialized()
+	}
+	if !enc.guard.enter() {
+		return 0, ErrConcurrentUse
 	}
+	defer enc.guard.leave()
+	enc.debug.touch()
 	if len(pcm) == 0 {
-		return 0, fmt.Errorf("opus: no data supplied")
+		return 0, errNoData
 	}
 	if len(data) == 0 {
-		return 0, fmt.Errorf("opus: no target buffer")
+		return 0, errNoTarget
 	}
 	// libopus talks about samples as 1 sample containing multiple channels. So
 	// e.g. 20 samples of 2-channel data is actually 40 raw data points.
 	if len(pcm)%enc.channels != 0 {
-		return 0, fmt.Errorf("opus: input buffer length must be multiple of channels")
+		return 0, errInputChannels
 	}
 	samples := len(pcm) / enc.channels
+	if err := validateFrameSize(enc.sample_rate, enc.channels, samples); err != nil {
+		return 0, err
+	}
+	start := enc.timing.start()
 	n := int(C.opus_encode(
 		enc.p,
 		(*C.opus_int16)(&pcm[0]),
 		C.int(samples),
 		(*C.uchar)(&data[0]),
 		C.opus_int32(cap(data))))
+	enc.timing.stop(start, enc.sample_rate, samples)
 	if n < 0 {
-		return 0, Error(n)
+		return 0, opusError(n)
+	}
+	if enc.trace != nil {
+		enc.trace.record(enc, data[:n])
 	}
 	return n, nil
 }
@@ -191,39 +484,56 @@ func (enc *Encoder) Encode(pcm []int16, data []byte) (int, error) {
 // returns the number of bytes used up by the encoded data.
 func (enc *Encoder) EncodeFloat32(pcm []float32, data []byte) (int, error) {
 	if enc.p == nil {
-		return 0, errEncUninitialized
+		return 0, enc.errUninitialized()
+	}
+	if !enc.guard.enter() {
+		return 0, ErrConcurrentUse
 	}
+	defer enc.guard.leave()
+	enc.debug.touch()
 	if len(pcm) == 0 {
-		return 0, fmt.Errorf("opus: no data supplied")
+		return 0, errNoData
 	}
 	if len(data) == 0 {
-		return 0, fmt.Errorf("opus: no target buffer")
+		return 0, errNoTarget
 	}
 	if len(pcm)%enc.channels != 0 {
-		return 0, fmt.Errorf("opus: input buffer length must be multiple of channels")
+		return 0, errInputChannels
 	}
 	samples := len(pcm) / enc.channels
+	if err := validateFrameSize(enc.sample_rate, enc.channels, samples); err != nil {
+		return 0, err
+	}
+	start := enc.timing.start()
 	n := int(C.opus_encode_float(
 		enc.p,
 		(*C.float)(&pcm[0]),
 		C.int(samples),
 		(*C.uchar)(&data[0]),
 		C.opus_int32(cap(data))))
+	enc.timing.stop(start, enc.sample_rate, samples)
 	if n < 0 {
-		return 0, Error(n)
+		return 0, opusError(n)
+	}
+	if enc.trace != nil {
+		enc.trace.record(enc, data[:n])
 	}
 	return n, nil
 }
 
 // SetDTX configures the encoder's use of discontinuous transmission (DTX).
 func (enc *Encoder) SetDTX(dtx bool) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
 	i := 0
 	if dtx {
 		i = 1
 	}
 	res := C.bridge_encoder_set_dtx(enc.p, C.opus_int32(i))
+	runtime.KeepAlive(enc)
 	if res != C.OPUS_OK {
-		return Error(res)
+		return opusError(int(res))
 	}
 	return nil
 }
@@ -231,38 +541,73 @@ func (enc *Encoder) SetDTX(dtx bool) error {
 // DTX reports whether this encoder is configured to use discontinuous
 // transmission (DTX).
 func (enc *Encoder) DTX() (bool, error) {
-	var dtx C.opus_int32
-	res := C.bridge_encoder_get_dtx(enc.p, &dtx)
+	if enc.p == nil {
+		return false, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_dtx(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return false, opusError(int(res))
+	}
+	return enc.ctl != 0, nil
+}
+
+// InDTX reports whether the last encoded frame was a DTX frame, i.e. the
+// encoder found nothing worth sending. The packets of such frames only serve
+// to keep the decoder's comfort noise going, and callers may choose not to
+// send them at all.
+func (enc *Encoder) InDTX() (bool, error) {
+	if enc.p == nil {
+		return false, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_in_dtx(enc.p, &enc.ctl)
 	if res != C.OPUS_OK {
-		return false, Error(res)
+		return false, opusError(int(res))
 	}
-	return dtx != 0, nil
+	return enc.ctl != 0, nil
 }
 
 // SampleRate returns the encoder sample rate in Hz.
 func (enc *Encoder) SampleRate() (int, error) {
-	var sr C.opus_int32
-	res := C.bridge_encoder_get_sample_rate(enc.p, &sr)
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_sample_rate(enc.p, &enc.ctl)
 	if res != C.OPUS_OK {
-		return 0, Error(res)
+		return 0, opusError(int(res))
 	}
-	return int(sr), nil
+	return int(enc.ctl), nil
+}
+
+// Channels returns the number of channels the encoder takes as input.
+func (enc *Encoder) Channels() (int, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	return enc.channels, nil
 }
 
 // SetBitrate sets the bitrate of the Encoder
 func (enc *Encoder) SetBitrate(bitrate int) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
 	res := C.bridge_encoder_set_bitrate(enc.p, C.opus_int32(bitrate))
+	runtime.KeepAlive(enc)
 	if res != C.OPUS_OK {
-		return Error(res)
+		return opusError(int(res))
 	}
 	return nil
 }
 
 // SetBitrateToAuto will allow the encoder to automatically set the bitrate
 func (enc *Encoder) SetBitrateToAuto() error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
 	res := C.bridge_encoder_set_bitrate(enc.p, C.opus_int32(C.OPUS_AUTO))
+	runtime.KeepAlive(enc)
 	if res != C.OPUS_OK {
-		return Error(res)
+		return opusError(int(res))
 	}
 	return nil
 }
@@ -270,101 +615,671 @@ func (enc *Encoder) SetBitrateToAuto() error {
 // SetBitrateToMax causes the encoder to use as much rate as it can. This can be
 // useful for controlling the rate by adjusting the output buffer size.
 func (enc *Encoder) SetBitrateToMax() error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
 	res := C.bridge_encoder_set_bitrate(enc.p, C.opus_int32(C.OPUS_BITRATE_MAX))
+	runtime.KeepAlive(enc)
 	if res != C.OPUS_OK {
-		return Error(res)
+		return opusError(int(res))
 	}
 	return nil
 }
 
 // Bitrate returns the bitrate of the Encoder
 func (enc *Encoder) Bitrate() (int, error) {
-	var bitrate C.opus_int32
-	res := C.bridge_encoder_get_bitrate(enc.p, &bitrate)
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_bitrate(enc.p, &enc.ctl)
 	if res != C.OPUS_OK {
-		return 0, Error(res)
+		return 0, opusError(int(res))
 	}
-	return int(bitrate), nil
+	return int(enc.ctl), nil
 }
 
 // SetComplexity sets the encoder's computational complexity
 func (enc *Encoder) SetComplexity(complexity int) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
 	res := C.bridge_encoder_set_complexity(enc.p, C.opus_int32(complexity))
+	runtime.KeepAlive(enc)
 	if res != C.OPUS_OK {
-		return Error(res)
+		return opusError(int(res))
 	}
 	return nil
 }
 
 // Complexity returns the computational complexity used by the encoder
 func (enc *Encoder) Complexity() (int, error) {
-	var complexity C.opus_int32
-	res := C.bridge_encoder_get_complexity(enc.p, &complexity)
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_complexity(enc.p, &enc.ctl)
 	if res != C.OPUS_OK {
-		return 0, Error(res)
+		return 0, opusError(int(res))
 	}
-	return int(complexity), nil
+	return int(enc.ctl), nil
 }
 
 // SetMaxBandwidth configures the maximum bandpass that the encoder will select
 // automatically
 func (enc *Encoder) SetMaxBandwidth(maxBw Bandwidth) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
 	res := C.bridge_encoder_set_max_bandwidth(enc.p, C.opus_int32(maxBw))
+	runtime.KeepAlive(enc)
 	if res != C.OPUS_OK {
-		return Error(res)
+		return opusError(int(res))
 	}
 	return nil
 }
 
 // MaxBandwidth gets the encoder's configured maximum allowed bandpass.
 func (enc *Encoder) MaxBandwidth() (Bandwidth, error) {
-	var maxBw C.opus_int32
-	res := C.bridge_encoder_get_max_bandwidth(enc.p, &maxBw)
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_max_bandwidth(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return Bandwidth(enc.ctl), nil
+}
+
+// SetBandwidth forces the encoder to use the given bandpass, regardless of
+// what it would select for the bitrate and signal, e.g. narrowband for a PSTN
+// gateway. Unlike SetMaxBandwidth, this also rules out narrower bandpasses.
+func (enc *Encoder) SetBandwidth(bw Bandwidth) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	res := C.bridge_encoder_set_bandwidth(enc.p, C.opus_int32(bw))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	enc.forcedBandwidth = bw
+	return nil
+}
+
+// SetBandwidthToAuto lets the encoder select the bandpass again (the
+// default), up to the maximum set with SetMaxBandwidth.
+func (enc *Encoder) SetBandwidthToAuto() error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	res := C.bridge_encoder_set_bandwidth(enc.p, C.opus_int32(C.OPUS_AUTO))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	enc.forcedBandwidth = 0
+	return nil
+}
+
+// Bandwidth gets the bandpass the encoder actually used for the last frame,
+// as opposed to the configured maximum.
+func (enc *Encoder) Bandwidth() (Bandwidth, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_bandwidth(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return Bandwidth(enc.ctl), nil
+}
+
+// SetSignal hints the encoder about the kind of audio it's encoding, which
+// helps it pick the right mode. Defaults to SignalAuto.
+func (enc *Encoder) SetSignal(signal Signal) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	res := C.bridge_encoder_set_signal(enc.p, C.opus_int32(signal))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// Signal gets the encoder's configured signal hint.
+func (enc *Encoder) Signal() (Signal, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_signal(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return Signal(enc.ctl), nil
+}
+
+// Lookahead gets the number of samples (per channel) the encoder looks ahead,
+// which adds to the delay on top of the frame duration. The decoder's output
+// is delayed by the same amount. Scaled to GranuleSampleRate, this is the
+// pre-skip to put in the OpusHead of an Ogg Opus stream.
+func (enc *Encoder) Lookahead() (int, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_lookahead(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return int(enc.ctl), nil
+}
+
+// SetPredictionDisabled configures whether the encoder may use information
+// from previous frames. With prediction disabled, every frame can be decoded
+// on its own, at the cost of quality for the same bitrate.
+func (enc *Encoder) SetPredictionDisabled(disabled bool) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	i := 0
+	if disabled {
+		i = 1
+	}
+	res := C.bridge_encoder_set_prediction_disabled(enc.p, C.opus_int32(i))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// PredictionDisabled gets whether the encoder's inter-frame prediction is
+// disabled.
+func (enc *Encoder) PredictionDisabled() (bool, error) {
+	if enc.p == nil {
+		return false, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_prediction_disabled(enc.p, &enc.ctl)
 	if res != C.OPUS_OK {
-		return 0, Error(res)
+		return false, opusError(int(res))
 	}
-	return Bandwidth(maxBw), nil
+	return enc.ctl != 0, nil
 }
 
 // SetInBandFEC configures the encoder's use of inband forward error
-// correction (FEC)
+// correction (FEC). With FEC, packets carry a low bitrate copy of the previous
+// frame, which the receiver can recover with Decoder.DecodeFEC when a packet
+// is lost. It only applies to SILK frames, and only takes effect with a
+// nonzero SetPacketLossPerc.
 func (enc *Encoder) SetInBandFEC(fec bool) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
 	i := 0
 	if fec {
 		i = 1
 	}
 	res := C.bridge_encoder_set_inband_fec(enc.p, C.opus_int32(i))
+	runtime.KeepAlive(enc)
 	if res != C.OPUS_OK {
-		return Error(res)
+		return opusError(int(res))
 	}
 	return nil
 }
 
 // InBandFEC gets the encoder's configured inband forward error correction (FEC)
 func (enc *Encoder) InBandFEC() (bool, error) {
-	var fec C.opus_int32
-	res := C.bridge_encoder_get_inband_fec(enc.p, &fec)
+	if enc.p == nil {
+		return false, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_inband_fec(enc.p, &enc.ctl)
 	if res != C.OPUS_OK {
-		return false, Error(res)
+		return false, opusError(int(res))
 	}
-	return fec != 0, nil
+	return enc.ctl != 0, nil
 }
 
-// SetPacketLossPerc configures the encoder's expected packet loss percentage.
+// SetPacketLossPerc configures the encoder's expected packet loss percentage,
+// from 0 (the default) to 100. Higher values make the encoder more robust
+// against loss at the cost of quality. It is also what drives in-band FEC:
+// libopus only adds redundancy for a nonzero expected loss, so SetInBandFEC on
+// its own has no effect.
 func (enc *Encoder) SetPacketLossPerc(lossPerc int) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
 	res := C.bridge_encoder_set_packet_loss_perc(enc.p, C.opus_int32(lossPerc))
+	runtime.KeepAlive(enc)
 	if res != C.OPUS_OK {
-		return Error(res)
+		return opusError(int(res))
 	}
 	return nil
 }
 
 // PacketLossPerc gets the encoder's configured packet loss percentage.
 func (enc *Encoder) PacketLossPerc() (int, error) {
-	var lossPerc C.opus_int32
-	res := C.bridge_encoder_get_packet_loss_perc(enc.p, &lossPerc)
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_packet_loss_perc(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return int(enc.ctl), nil
+}
+
+// SetVBR configures whether the encoder uses a variable bitrate (VBR, the
+// default) or a constant bitrate (CBR). With CBR every packet has the same
+// size for a given frame size and bitrate, which hides the content from
+// traffic analysis at the cost of quality.
+func (enc *Encoder) SetVBR(vbr bool) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	i := 0
+	if vbr {
+		i = 1
+	}
+	res := C.bridge_encoder_set_vbr(enc.p, C.opus_int32(i))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// VBR gets whether the encoder uses a variable bitrate.
+func (enc *Encoder) VBR() (bool, error) {
+	if enc.p == nil {
+		return false, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_vbr(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return false, opusError(int(res))
+	}
+	return enc.ctl != 0, nil
+}
+
+// SetVBRConstraint configures whether the variable bitrate is constrained
+// (the default). Constrained VBR keeps the bitrate within what a hypothetical
+// bit reservoir of one frame allows, which bounds short-term spikes, e.g. for
+// live streaming over a fixed capacity link. Has no effect in CBR mode.
+func (enc *Encoder) SetVBRConstraint(constraint bool) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	i := 0
+	if constraint {
+		i = 1
+	}
+	res := C.bridge_encoder_set_vbr_constraint(enc.p, C.opus_int32(i))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// VBRConstraint gets whether the encoder's variable bitrate is constrained.
+func (enc *Encoder) VBRConstraint() (bool, error) {
+	if enc.p == nil {
+		return false, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_vbr_constraint(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return false, opusError(int(res))
+	}
+	return enc.ctl != 0, nil
+}
+
+// SetLSBDepth configures the depth of the signal being encoded, in bits,
+// from 8 to 24 (the default). Audio converted from e.g. a 16 bit source has
+// nothing but dither noise below that depth, and telling the encoder avoids
+// wasting bits on it.
+func (enc *Encoder) SetLSBDepth(depth int) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	res := C.bridge_encoder_set_lsb_depth(enc.p, C.opus_int32(depth))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// LSBDepth gets the encoder's configured signal depth in bits.
+func (enc *Encoder) LSBDepth() (int, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_lsb_depth(enc.p, &enc.ctl)
 	if res != C.OPUS_OK {
-		return 0, Error(res)
+		return 0, opusError(int(res))
+	}
+	return int(enc.ctl), nil
+}
+
+// SetPhaseInversionDisabled configures whether the encoder may use phase
+// inversion for intensity stereo. Phase inversion saves bits, but sounds bad
+// when the receiver downmixes the stereo stream to mono, so disable it if
+// that is likely.
+func (enc *Encoder) SetPhaseInversionDisabled(disabled bool) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	i := 0
+	if disabled {
+		i = 1
+	}
+	res := C.bridge_encoder_set_phase_inversion_disabled(enc.p, C.opus_int32(i))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// PhaseInversionDisabled gets whether the encoder's use of phase inversion is
+// disabled.
+func (enc *Encoder) PhaseInversionDisabled() (bool, error) {
+	if enc.p == nil {
+		return false, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_phase_inversion_disabled(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return false, opusError(int(res))
+	}
+	return enc.ctl != 0, nil
+}
+
+// SetForceChannels forces the encoder to code the audio as mono (1) or stereo
+// (2), regardless of what it would pick for the signal and bitrate. E.g. a
+// stereo encoder can be forced to send mono when the available bandwidth
+// drops, without recreating it. 0 lets the encoder decide again (the
+// default).
+func (enc *Encoder) SetForceChannels(channels int) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	v := C.opus_int32(channels)
+	if channels == 0 {
+		v = C.OPUS_AUTO
+	}
+	res := C.bridge_encoder_set_force_channels(enc.p, v)
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// ForceChannels gets the number of channels the encoder is forced to code, or
+// 0 if the encoder decides.
+func (enc *Encoder) ForceChannels() (int, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_force_channels(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	if enc.ctl == C.OPUS_AUTO {
+		return 0, nil
+	}
+	return int(enc.ctl), nil
+}
+
+// SetExpertFrameDuration makes the encoder code frames of a fixed duration,
+// instead of the duration of the audio passed to Encode. Encode must then be
+// given at least that much audio, and only codes the first frame duration of
+// it. With FrameDurationArg (the default), the duration of the audio decides.
+func (enc *Encoder) SetExpertFrameDuration(duration FrameDuration) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	res := C.bridge_encoder_set_expert_frame_duration(enc.p, C.opus_int32(duration))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// ExpertFrameDuration gets the encoder's configured frame duration.
+func (enc *Encoder) ExpertFrameDuration() (FrameDuration, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_expert_frame_duration(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return FrameDuration(enc.ctl), nil
+}
+
+// Reset puts the encoder back into the state it was in before the first frame
+// was encoded, e.g. to reuse it for a new stream without allocating a new
+// one. All settings, like the bitrate and complexity, are kept.
+func (enc *Encoder) Reset() error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	res := C.bridge_encoder_reset_state(enc.p)
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// encoderSettings is a snapshot of the user configurable encoder settings
+// which survive a change of application mode.
+type encoderSettings struct {
+	complexity     int
+	maxBandwidth   Bandwidth
+	inBandFEC      bool
+	packetLossPerc int
+	dtx            bool
+}
+
+func (enc *Encoder) settings() (encoderSettings, error) {
+	var s encoderSettings
+	var err error
+	if s.complexity, err = enc.Complexity(); err != nil {
+		return s, err
+	}
+	if s.maxBandwidth, err = enc.MaxBandwidth(); err != nil {
+		return s, err
+	}
+	if s.inBandFEC, err = enc.InBandFEC(); err != nil {
+		return s, err
+	}
+	if s.packetLossPerc, err = enc.PacketLossPerc(); err != nil {
+		return s, err
+	}
+	if s.dtx, err = enc.DTX(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func (enc *Encoder) applySettings(s encoderSettings) error {
+	if err := enc.SetComplexity(s.complexity); err != nil {
+		return err
+	}
+	if err := enc.SetMaxBandwidth(s.maxBandwidth); err != nil {
+		return err
+	}
+	if err := enc.SetInBandFEC(s.inBandFEC); err != nil {
+		return err
+	}
+	if err := enc.SetPacketLossPerc(s.packetLossPerc); err != nil {
+		return err
+	}
+	return enc.SetDTX(s.dtx)
+}
+
+// SetApplication changes the application mode of the encoder. libopus only
+// allows this before the first frame is encoded; use SwitchApplication to
+// change it in the middle of a stream.
+func (enc *Encoder) SetApplication(app Application) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	res := C.bridge_encoder_set_application(enc.p, C.opus_int32(app))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// Application gets the encoder's application mode.
+func (enc *Encoder) Application() (Application, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_application(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return Application(enc.ctl), nil
+}
+
+// FinalRange returns the final state of the range coder after the last
+// encoded packet. After decoding the same packet, Decoder.FinalRange returns
+// the same value, which makes it possible to check that an encoder and a
+// decoder, e.g. on different platforms, agree bit for bit.
+func (enc *Encoder) FinalRange() (uint32, error) {
+	if enc.p == nil {
+		return 0, enc.errUninitialized()
+	}
+	res := C.bridge_encoder_get_final_range(enc.p, &enc.ctl)
+	if res != C.OPUS_OK {
+		return 0, opusError(int(res))
+	}
+	return uint32(enc.ctl), nil
+}
+
+// SwitchApplication changes the application mode of the encoder, e.g. from
+// AppVoIP to AppAudio, in the middle of a stream.
+//
+// Switching without an audible glitch is not possible with libopus: it
+// refuses a new application once the first frame has been encoded, and the
+// only way around that is to reset the encoder state before switching. The
+// reset keeps all settings, but clears the history of the signal, which
+// causes a discontinuity at the switch, much like a packet loss on the
+// receiving end. To keep it inaudible, switch while the input is silent, e.g.
+// when InDTX reports true. Before the first frame, the switch is seamless.
+func (enc *Encoder) SwitchApplication(app Application) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	cur, err := enc.Application()
+	if err != nil {
+		return err
+	}
+	if cur == app {
+		return nil
+	}
+	// Nothing encoded yet: libopus accepts the change as is.
+	res := C.bridge_encoder_set_application(enc.p, C.opus_int32(app))
+	runtime.KeepAlive(enc)
+	if res == C.OPUS_OK {
+		return nil
+	}
+	if res != C.OPUS_BAD_ARG {
+		return opusError(int(res))
+	}
+	res = C.bridge_encoder_reset_state(enc.p)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	res = C.bridge_encoder_set_application(enc.p, C.opus_int32(app))
+	runtime.KeepAlive(enc)
+	if res != C.OPUS_OK {
+		return opusError(int(res))
+	}
+	return nil
+}
+
+// Clone returns an independent copy of the encoder, including all of its
+// internal state. Encoding with the clone does not affect the original and
+// vice versa, which makes it possible to speculatively encode the same frame
+// in different ways, e.g. at two bitrates, and pick one afterwards.
+//
+// This relies on the libopus encoder state being position independent: it is
+// a single block of memory without pointers into itself, so a byte-for-byte
+// copy is a valid encoder. The clone lives on the same heap as the original;
+// if that is the C heap, the clone must be closed separately.
+func (enc *Encoder) Clone() (*Encoder, error) {
+	if enc.p == nil {
+		return nil, enc.errUninitialized()
+	}
+	if enc.mem == nil {
+		size := C.opus_encoder_get_size(C.int(enc.channels))
+		p := C.malloc(C.size_t(size))
+		if p == nil {
+			return nil, ErrAllocFail
+		}
+		C.memcpy(p, unsafe.Pointer(enc.p), C.size_t(size))
+		clone := &Encoder{
+			p:               (*C.OpusEncoder)(p),
+			sample_rate:     enc.sample_rate,
+			channels:        enc.channels,
+			forcedBandwidth: enc.forcedBandwidth,
+		}
+		clone.track()
+		return clone, nil
+	}
+	clone := &Encoder{
+		sample_rate:     enc.sample_rate,
+		channels:        enc.channels,
+		mem:             make([]byte, len(enc.mem)),
+		forcedBandwidth: enc.forcedBandwidth,
+	}
+	copy(clone.mem, enc.mem)
+	clone.p = (*C.OpusEncoder)(unsafe.Pointer(&clone.mem[0]))
+	clone.track()
+	return clone, nil
+}
+
+// Close releases the encoder. For encoders on the C heap (see
+// NewEncoderCHeap) this frees the memory; encoders on the Go heap have their
+// state zeroed, and the memory is left to the garbage collector. Either way,
+// all further use of the encoder fails with ErrClosed.
+//
+// Encoders on the C heap which are never closed are freed by a finalizer as
+// a safety net, but as with files, relying on that is a bug.
+func (enc *Encoder) Close() error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	enc.release()
+	enc.debug.close()
+	enc.closed = true
+	return nil
+}
+
+// release frees the encoder state on the C heap, or zeroes it on the Go heap,
+// so nothing derived from the audio lingers in memory.
+func (enc *Encoder) release() {
+	if enc.mem == nil {
+		C.opus_encoder_destroy(enc.p)
+	} else {
+		for i := range enc.mem {
+			enc.mem[i] = 0
+		}
+	}
+	enc.p = nil
+	enc.mem = nil
+}
+
+// errUninitialized is the error for using the encoder without state.
+func (enc *Encoder) errUninitialized() error {
+	if enc.closed {
+		return ErrClosed
 	}
-	return int(lossPerc), nil
+	return errEncUninitialized
 }
@@ -4,7 +4,11 @@
 
 package opus
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+	"time"
+)
 
 func TestEncoderNew(t *testing.T) {
 	enc, err := NewEncoder(48000, 1, AppVoIP)
@@ -50,6 +54,46 @@ func TestEncoderDTX(t *testing.T) {
 	}
 }
 
+func TestEncoderInDTX(t *testing.T) {
+	const SAMPLE_RATE = 16000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const G4 = 391.995
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.SetDTX(true); err != nil {
+		t.Fatalf("Error setting DTX: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	in, err := enc.InDTX()
+	if err != nil {
+		t.Fatalf("Error getting in DTX: %v", err)
+	}
+	if in {
+		t.Errorf("Expected no DTX for a tone")
+	}
+	// After a stretch of silence the encoder goes into DTX
+	silence := make([]int16, FRAME_SIZE)
+	for i := 0; i < 50 && !in; i++ {
+		if _, err := enc.Encode(silence, data); err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		in, err = enc.InDTX()
+		if err != nil {
+			t.Fatalf("Error getting in DTX: %v", err)
+		}
+	}
+	if !in {
+		t.Errorf("Expected DTX after a second of silence")
+	}
+}
+
 func TestEncoderSampleRate(t *testing.T) {
 	sample_rates := []int{8000, 12000, 16000, 24000, 48000}
 	for _, f := range sample_rates {
@@ -67,6 +111,26 @@ func TestEncoderSampleRate(t *testing.T) {
 	}
 }
 
+func TestEncoderChannels(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		enc, err := NewEncoder(48000, channels, AppVoIP)
+		if err != nil || enc == nil {
+			t.Fatalf("Error creating new encoder with %d channels: %v", channels, err)
+		}
+		c, err := enc.Channels()
+		if err != nil {
+			t.Fatalf("Error getting channels (%d): %v", channels, err)
+		}
+		if c != channels {
+			t.Errorf("Unexpected channels reported by %d channel encoder: %d", channels, c)
+		}
+	}
+	var enc Encoder
+	if _, err := enc.Channels(); err != errEncUninitialized {
+		t.Errorf("Expected errEncUninitialized, got %v", err)
+	}
+}
+
 func TestEncoder_SetGetBitrate(t *testing.T) {
 	enc, err := NewEncoder(8000, 1, AppVoIP)
 	if err != nil || enc == nil {
@@ -260,6 +324,90 @@ func TestEncoder_SetGetMaxBandwidth(t *testing.T) {
 	}
 }
 
+func TestEncoder_SetGetBandwidth(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const G4 = 391.995
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.SetBitrate(64000); err != nil {
+		t.Fatalf("Error setting bitrate: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*3)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	encode := func(pcm []int16) PacketInfo {
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		info, err := ParsePacket(data[:n])
+		if err != nil {
+			t.Fatalf("Couldn't parse packet: %v", err)
+		}
+		return info
+	}
+	if err := enc.SetBandwidth(Narrowband); err != nil {
+		t.Fatalf("Error setting bandwidth: %v", err)
+	}
+	if info := encode(pcm[:FRAME_SIZE]); info.Bandwidth != Narrowband {
+		t.Errorf("Expected a narrowband packet, got %v", info.Bandwidth)
+	}
+	bw, err := enc.Bandwidth()
+	if err != nil {
+		t.Fatalf("Error getting bandwidth: %v", err)
+	}
+	if bw != Narrowband {
+		t.Errorf("Expected current bandwidth %v, got %v", Narrowband, bw)
+	}
+	if err := enc.SetBandwidthToAuto(); err != nil {
+		t.Fatalf("Error setting bandwidth to auto: %v", err)
+	}
+	encode(pcm[FRAME_SIZE : 2*FRAME_SIZE])
+	info := encode(pcm[2*FRAME_SIZE:])
+	if info.Bandwidth == Narrowband {
+		t.Errorf("Expected a wider bandpass at 64 kbit/s with automatic bandwidth")
+	}
+	bw, err = enc.Bandwidth()
+	if err != nil {
+		t.Fatalf("Error getting bandwidth: %v", err)
+	}
+	if bw != info.Bandwidth {
+		t.Errorf("Expected current bandwidth %v to match the packet, got %v", info.Bandwidth, bw)
+	}
+	if err := enc.SetBandwidth(Bandwidth(1234)); err == nil {
+		t.Errorf("Expected error setting an invalid bandwidth")
+	}
+}
+
+func TestEncoder_SetGetSignal(t *testing.T) {
+	enc, err := NewEncoder(8000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Errorf("Error creating new encoder: %v", err)
+	}
+	vals := []Signal{
+		SignalAuto,
+		SignalVoice,
+		SignalMusic,
+	}
+	for _, signal := range vals {
+		err := enc.SetSignal(signal)
+		if err != nil {
+			t.Error("Error setting signal:", err)
+		}
+		signalRead, err := enc.Signal()
+		if err != nil {
+			t.Error("Error getting signal", err)
+		}
+		if signalRead != signal {
+			t.Errorf("Unexpected signal value. Got %d, but expected %d",
+				signalRead, signal)
+		}
+	}
+}
+
 func TestEncoder_SetGetInBandFEC(t *testing.T) {
 	enc, err := NewEncoder(8000, 1, AppVoIP)
 	if err != nil || enc == nil {
@@ -335,3 +483,576 @@ func TestEncoder_SetGetInvalidPacketLossPerc(t *testing.T) {
 		}
 	}
 }
+
+func TestEncoder_SetGetVBR(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const G4 = 391.995
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	vbr, err := enc.VBR()
+	if err != nil {
+		t.Fatalf("Error getting vbr: %v", err)
+	}
+	if !vbr {
+		t.Errorf("Expected VBR to be enabled by default")
+	}
+	if err := enc.SetVBR(false); err != nil {
+		t.Fatalf("Error setting vbr: %v", err)
+	}
+	vbr, err = enc.VBR()
+	if err != nil {
+		t.Fatalf("Error getting vbr: %v", err)
+	}
+	if vbr {
+		t.Errorf("Expected VBR to be disabled")
+	}
+	if err := enc.SetBitrate(32000); err != nil {
+		t.Fatalf("Error setting bitrate: %v", err)
+	}
+	// With CBR every packet has the same size
+	pcm := make([]int16, FRAME_SIZE*5)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	size := 0
+	for i := 0; i < 5; i++ {
+		n, err := enc.Encode(pcm[i*FRAME_SIZE:(i+1)*FRAME_SIZE], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		if i == 0 {
+			size = n
+		} else if n != size {
+			t.Errorf("Packet %d has size %d in CBR mode, expected %d", i, n, size)
+		}
+	}
+}
+
+func TestEncoder_SetGetVBRConstraint(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	constraint, err := enc.VBRConstraint()
+	if err != nil {
+		t.Fatalf("Error getting vbr constraint: %v", err)
+	}
+	if !constraint {
+		t.Errorf("Expected VBR constraint to be enabled by default")
+	}
+	for _, c := range []bool{false, true} {
+		if err := enc.SetVBRConstraint(c); err != nil {
+			t.Fatalf("Error setting vbr constraint to %t: %v", c, err)
+		}
+		got, err := enc.VBRConstraint()
+		if err != nil {
+			t.Fatalf("Error getting vbr constraint (%t): %v", c, err)
+		}
+		if got != c {
+			t.Errorf("Error set vbr constraint: expect %v, got %v", c, got)
+		}
+	}
+}
+
+func TestEncoder_SetGetApplication(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	app, err := enc.Application()
+	if err != nil {
+		t.Fatalf("Error getting application: %v", err)
+	}
+	if app != AppVoIP {
+		t.Errorf("Expected application %v, got %v", AppVoIP, app)
+	}
+	if err := enc.SetApplication(AppAudio); err != nil {
+		t.Fatalf("Error setting application: %v", err)
+	}
+	app, err = enc.Application()
+	if err != nil {
+		t.Fatalf("Error getting application: %v", err)
+	}
+	if app != AppAudio {
+		t.Errorf("Expected application %v, got %v", AppAudio, app)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, 1000)
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	// libopus refuses the change once a frame has been encoded
+	if err := enc.SetApplication(AppVoIP); err == nil {
+		t.Errorf("Expected error setting application after encoding")
+	}
+}
+
+func TestEncoder_SetGetLSBDepth(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	depth, err := enc.LSBDepth()
+	if err != nil {
+		t.Fatalf("Error getting lsb depth: %v", err)
+	}
+	if depth != 24 {
+		t.Errorf("Expected default lsb depth 24, got %d", depth)
+	}
+	for _, d := range []int{8, 16, 24} {
+		if err := enc.SetLSBDepth(d); err != nil {
+			t.Fatalf("Error setting lsb depth to %d: %v", d, err)
+		}
+		got, err := enc.LSBDepth()
+		if err != nil {
+			t.Fatalf("Error getting lsb depth (%d): %v", d, err)
+		}
+		if got != d {
+			t.Errorf("Error set lsb depth: expect %d, got %d", d, got)
+		}
+	}
+	for _, d := range []int{7, 25} {
+		if err := enc.SetLSBDepth(d); err == nil {
+			t.Errorf("Expected error setting lsb depth to %d", d)
+		}
+	}
+}
+
+func TestEncoder_SetGetPredictionDisabled(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	disabled, err := enc.PredictionDisabled()
+	if err != nil {
+		t.Fatalf("Error getting prediction disabled: %v", err)
+	}
+	if disabled {
+		t.Errorf("Expected prediction to be enabled by default")
+	}
+	for _, d := range []bool{true, false} {
+		if err := enc.SetPredictionDisabled(d); err != nil {
+			t.Fatalf("Error setting prediction disabled to %t: %v", d, err)
+		}
+		got, err := enc.PredictionDisabled()
+		if err != nil {
+			t.Fatalf("Error getting prediction disabled (%t): %v", d, err)
+		}
+		if got != d {
+			t.Errorf("Error set prediction disabled: expect %v, got %v", d, got)
+		}
+	}
+}
+
+func TestEncoder_SetGetPhaseInversionDisabled(t *testing.T) {
+	enc, err := NewEncoder(48000, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	disabled, err := enc.PhaseInversionDisabled()
+	if err != nil {
+		t.Fatalf("Error getting phase inversion disabled: %v", err)
+	}
+	if disabled {
+		t.Errorf("Expected phase inversion to be enabled by default")
+	}
+	for _, d := range []bool{true, false} {
+		if err := enc.SetPhaseInversionDisabled(d); err != nil {
+			t.Fatalf("Error setting phase inversion disabled to %t: %v", d, err)
+		}
+		got, err := enc.PhaseInversionDisabled()
+		if err != nil {
+			t.Fatalf("Error getting phase inversion disabled (%t): %v", d, err)
+		}
+		if got != d {
+			t.Errorf("Error set phase inversion disabled: expect %v, got %v", d, got)
+		}
+	}
+}
+
+func TestEncoder_SetGetForceChannels(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const G4 = 391.995
+	enc, err := NewEncoder(SAMPLE_RATE, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	channels, err := enc.ForceChannels()
+	if err != nil {
+		t.Fatalf("Error getting force channels: %v", err)
+	}
+	if channels != 0 {
+		t.Errorf("Expected no forced channels by default, got %d", channels)
+	}
+	if err := enc.SetForceChannels(1); err != nil {
+		t.Fatalf("Error setting force channels: %v", err)
+	}
+	channels, err = enc.ForceChannels()
+	if err != nil {
+		t.Fatalf("Error getting force channels: %v", err)
+	}
+	if channels != 1 {
+		t.Errorf("Expected 1 forced channel, got %d", channels)
+	}
+	pcm := make([]int16, FRAME_SIZE*2)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	info, err := ParsePacket(data[:n])
+	if err != nil {
+		t.Fatalf("Couldn't parse packet: %v", err)
+	}
+	if info.Stereo {
+		t.Errorf("Expected a mono packet with forced mono")
+	}
+	if err := enc.SetForceChannels(0); err != nil {
+		t.Fatalf("Error resetting force channels: %v", err)
+	}
+	channels, err = enc.ForceChannels()
+	if err != nil {
+		t.Fatalf("Error getting force channels: %v", err)
+	}
+	if channels != 0 {
+		t.Errorf("Expected no forced channels after reset, got %d", channels)
+	}
+	if err := enc.SetForceChannels(3); err == nil {
+		t.Errorf("Expected error forcing 3 channels")
+	}
+}
+
+func TestEncoder_SetGetExpertFrameDuration(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const G4 = 391.995
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	fd, err := enc.ExpertFrameDuration()
+	if err != nil {
+		t.Fatalf("Error getting expert frame duration: %v", err)
+	}
+	if fd != FrameDurationArg {
+		t.Errorf("Expected default expert frame duration %d, got %d", FrameDurationArg, fd)
+	}
+	durations := []FrameDuration{
+		FrameDuration2_5ms, FrameDuration5ms, FrameDuration10ms,
+		FrameDuration20ms, FrameDuration40ms, FrameDuration60ms,
+		FrameDuration80ms, FrameDuration100ms, FrameDuration120ms,
+		FrameDurationArg,
+	}
+	for _, d := range durations {
+		if err := enc.SetExpertFrameDuration(d); err != nil {
+			t.Fatalf("Error setting expert frame duration to %d: %v", d, err)
+		}
+		got, err := enc.ExpertFrameDuration()
+		if err != nil {
+			t.Fatalf("Error getting expert frame duration (%d): %v", d, err)
+		}
+		if got != d {
+			t.Errorf("Error set expert frame duration: expect %d, got %d", d, got)
+		}
+	}
+	// Only the first 10 ms of the 20 ms frame are coded
+	if err := enc.SetExpertFrameDuration(FrameDuration10ms); err != nil {
+		t.Fatalf("Error setting expert frame duration: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	info, err := ParsePacket(data[:n])
+	if err != nil {
+		t.Fatalf("Couldn't parse packet: %v", err)
+	}
+	if info.Duration() != FrameDuration10ms.Duration() {
+		t.Errorf("Expected a %v packet, got %v", FrameDuration10ms.Duration(), info.Duration())
+	}
+}
+
+func TestFrameDuration_Duration(t *testing.T) {
+	if d := FrameDuration2_5ms.Duration(); d != 2500*time.Microsecond {
+		t.Errorf("Expected 2.5ms, got %v", d)
+	}
+	if d := FrameDuration120ms.Duration(); d != 120*time.Millisecond {
+		t.Errorf("Expected 120ms, got %v", d)
+	}
+	if d := FrameDurationArg.Duration(); d != 0 {
+		t.Errorf("Expected 0 for FrameDurationArg, got %v", d)
+	}
+}
+
+func TestEncoder_Lookahead(t *testing.T) {
+	tests := []struct {
+		app       Application
+		lookahead int
+	}{
+		// 2.5 ms plus 4 ms of delay compensation
+		{AppVoIP, 312},
+		{AppAudio, 312},
+		// No delay compensation
+		{AppRestrictedLowdelay, 120},
+	}
+	for _, tt := range tests {
+		enc, err := NewEncoder(48000, 1, tt.app)
+		if err != nil || enc == nil {
+			t.Fatalf("Error creating new encoder: %v", err)
+		}
+		lookahead, err := enc.Lookahead()
+		if err != nil {
+			t.Fatalf("Error getting lookahead: %v", err)
+		}
+		if lookahead != tt.lookahead {
+			t.Errorf("Expected lookahead %d for %v, got %d", tt.lookahead, tt.app, lookahead)
+		}
+	}
+}
+
+func TestEncoder_Reset(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const G4 = 391.995
+	pcm := make([]int16, FRAME_SIZE*3)
+	addSine(pcm, SAMPLE_RATE, G4)
+	newEncoder := func() *Encoder {
+		enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+		if err != nil || enc == nil {
+			t.Fatalf("Error creating new encoder: %v", err)
+		}
+		if err := enc.SetBitrate(24000); err != nil {
+			t.Fatalf("Error setting bitrate: %v", err)
+		}
+		return enc
+	}
+	encode := func(enc *Encoder, pcm []int16) []byte {
+		data := make([]byte, 1000)
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		return data[:n]
+	}
+	enc := newEncoder()
+	encode(enc, pcm[FRAME_SIZE:2*FRAME_SIZE])
+	encode(enc, pcm[2*FRAME_SIZE:])
+	if err := enc.Reset(); err != nil {
+		t.Fatalf("Error resetting encoder: %v", err)
+	}
+	bitrate, err := enc.Bitrate()
+	if err != nil {
+		t.Fatalf("Error getting bitrate: %v", err)
+	}
+	if bitrate != 24000 {
+		t.Errorf("Expected bitrate to survive reset, got %d", bitrate)
+	}
+	// After a reset the encoder behaves like a fresh one
+	got := encode(enc, pcm[:FRAME_SIZE])
+	want := encode(newEncoder(), pcm[:FRAME_SIZE])
+	if !bytes.Equal(got, want) {
+		t.Errorf("Reset encoder produced a different packet than a new one")
+	}
+}
+
+func TestEncoder_Close(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, 1000)
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	mem := enc.mem
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Error closing encoder: %v", err)
+	}
+	for i, b := range mem {
+		if b != 0 {
+			t.Fatalf("Encoder state not zeroed by Close at byte %d", i)
+		}
+	}
+	if _, err := enc.Encode(pcm, data); err != ErrClosed {
+		t.Errorf("Expected \"closed\" error from Encode: %v", err)
+	}
+	if err := enc.SetBitrate(32000); err != ErrClosed {
+		t.Errorf("Expected \"closed\" error from SetBitrate: %v", err)
+	}
+	if _, err := enc.Bitrate(); err != ErrClosed {
+		t.Errorf("Expected \"closed\" error from Bitrate: %v", err)
+	}
+	// A closed encoder can be initialized again
+	if err := enc.Init(SAMPLE_RATE, 1, AppAudio); err != nil {
+		t.Fatalf("Error reinitializing closed encoder: %v", err)
+	}
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Fatalf("Couldn't encode data after reinitializing: %v", err)
+	}
+}
+
+func TestEncoder_SwitchApplication(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	// Before the first frame libopus accepts the change directly
+	if err := enc.SwitchApplication(AppAudio); err != nil {
+		t.Fatalf("Error switching application before encoding: %v", err)
+	}
+	if err := enc.SetComplexity(3); err != nil {
+		t.Fatalf("Error setting complexity: %v", err)
+	}
+	if err := enc.SetInBandFEC(true); err != nil {
+		t.Fatalf("Error setting fec: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, 1000)
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	// After the first frame a reset is needed under the hood
+	if err := enc.SwitchApplication(AppVoIP); err != nil {
+		t.Fatalf("Error switching application mid-stream: %v", err)
+	}
+	cpx, err := enc.Complexity()
+	if err != nil {
+		t.Fatalf("Error getting complexity: %v", err)
+	}
+	if cpx != 3 {
+		t.Errorf("Complexity not preserved across switch. Got %d, expected 3", cpx)
+	}
+	fec, err := enc.InBandFEC()
+	if err != nil {
+		t.Fatalf("Error getting fec: %v", err)
+	}
+	if !fec {
+		t.Errorf("FEC setting not preserved across switch")
+	}
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Fatalf("Couldn't encode data after switch: %v", err)
+	}
+}
+
+func TestEncoder_Clone(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const G4 = 391.995
+	pcm := make([]int16, FRAME_SIZE*4)
+	addSine(pcm, SAMPLE_RATE, G4)
+	frames := [][]int16{
+		pcm[:FRAME_SIZE],
+		pcm[FRAME_SIZE : 2*FRAME_SIZE],
+		pcm[2*FRAME_SIZE : 3*FRAME_SIZE],
+		pcm[3*FRAME_SIZE:],
+	}
+	encodeFrame := func(enc *Encoder, frame []int16) []byte {
+		data := make([]byte, 1000)
+		n, err := enc.Encode(frame, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		return data[:n]
+	}
+
+	// Two identical encoders fed identical input; one of them gets cloned
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	ref, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil || ref == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	encodeFrame(enc, frames[0])
+	encodeFrame(ref, frames[0])
+
+	clone, err := enc.Clone()
+	if err != nil {
+		t.Fatalf("Error cloning encoder: %v", err)
+	}
+	// Same state, same input: same output
+	a := encodeFrame(clone, frames[1])
+	b := encodeFrame(ref, frames[1])
+	if string(a) != string(b) {
+		t.Errorf("Clone produced different output than an identical encoder")
+	}
+	// Perturb the clone. The original must not notice.
+	if err := clone.SetBitrate(6000); err != nil {
+		t.Fatalf("Error setting bitrate on clone: %v", err)
+	}
+	encodeFrame(clone, frames[2])
+	encodeFrame(clone, frames[3])
+	a = encodeFrame(enc, frames[1])
+	if string(a) != string(b) {
+		t.Errorf("Encoding with the clone perturbed the original encoder")
+	}
+	br, err := enc.Bitrate()
+	if err != nil {
+		t.Fatalf("Error getting bitrate: %v", err)
+	}
+	if br == 6000 {
+		t.Errorf("Setting bitrate on clone changed the original encoder")
+	}
+}
+
+func TestEncoder_CloneUninitialized(t *testing.T) {
+	var enc Encoder
+	if _, err := enc.Clone(); err != errEncUninitialized {
+		t.Errorf("Expected \"unitialized encoder\" error: %v", err)
+	}
+}
+
+func TestEncoderCHeap(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoderCHeap(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if _, err := NewEncoderCHeap(12345, 1, AppVoIP); err == nil {
+		t.Errorf("Expected error for illegal samplerate 12345")
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, 1000)
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	clone, err := enc.Clone()
+	if err != nil {
+		t.Fatalf("Error cloning encoder: %v", err)
+	}
+	if _, err := clone.Encode(pcm, data); err != nil {
+		t.Fatalf("Couldn't encode data with clone: %v", err)
+	}
+	if err := clone.Close(); err != nil {
+		t.Fatalf("Error closing clone: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Error closing encoder: %v", err)
+	}
+	if _, err := enc.Encode(pcm, data); err != ErrClosed {
+		t.Errorf("Expected \"closed\" error: %v", err)
+	}
+	if err := enc.Close(); err != ErrClosed {
+		t.Errorf("Expected \"closed\" error on double close: %v", err)
+	}
+}
@@ -30,6 +30,35 @@ const (
 	ErrAllocFail      = Error(C.OPUS_ALLOC_FAIL)
 )
 
+// ErrClosed is returned when using an encoder or decoder after Close.
+var ErrClosed = fmt.Errorf("opus: use of closed codec")
+
+// ErrWorkerClosed is returned when using an EncoderWorker or DecoderWorker
+// after Close.
+var ErrWorkerClosed = fmt.Errorf("opus: worker closed")
+
+// Boxed libopus errors, indexed by negated error code. Converting a negative
+// Error to the error interface allocates; returning these doesn't.
+var opusErrors = [...]error{
+	ErrOK,
+	ErrBadArg,
+	ErrBufferTooSmall,
+	ErrInternalError,
+	ErrInvalidPacket,
+	ErrUnimplemented,
+	ErrInvalidState,
+	ErrAllocFail,
+}
+
+// opusError converts a libopus error code to an error, without allocating
+// for the known codes.
+func opusError(code int) error {
+	if code <= 0 && -code < len(opusErrors) {
+		return opusErrors[-code]
+	}
+	return Error(code)
+}
+
 // Error string (in human readable format) for libopus errors.
 func (e Error) Error() string {
 	return fmt.Sprintf("opus: %s", C.GoString(C.opus_strerror(C.int(e))))
@@ -0,0 +1,168 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExactStream is audio of any length encoded such that decoding it with
+// Decoder.DecodeExact gives back exactly as many samples as went in, aligned
+// with the input: the encoder lookahead is removed from the start, and the
+// padding of the last frame from the end. This is what Ogg Opus does with the
+// pre-skip and end trimming, for raw packets.
+//
+// Stream already applies both for Ogg Opus input, through libopusfile.
+type ExactStream struct {
+	SampleRate int
+	Channels   int
+	// Number of input samples per channel
+	Samples int
+	// Number of decoded samples per channel to discard at the start: the
+	// encoder lookahead
+	PreSkip int
+	Packets [][]byte
+}
+
+// exactFrames returns the number of frames of the given size needed to get
+// samples out of the decoder after discarding preSkip.
+func exactFrames(samples, preSkip, frameSize int) int {
+	return (samples + preSkip + frameSize - 1) / frameSize
+}
+
+// EncodeExact encodes pcm, of any length, into frames of the given duration,
+// padding the end with silence. Use a freshly initialized encoder.
+func (enc *Encoder) EncodeExact(pcm []int16, frame time.Duration) (*ExactStream, error) {
+	s, frameSize, err := enc.newExactStream(len(pcm), frame)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]int16, frameSize*enc.channels)
+	data := make([]byte, maxEncodedFrameSize)
+	for i := 0; i < exactFrames(s.Samples, s.PreSkip, frameSize); i++ {
+		n := 0
+		if start := i * len(buf); start < len(pcm) {
+			n = copy(buf, pcm[start:])
+		}
+		for j := n; j < len(buf); j++ {
+			buf[j] = 0
+		}
+		m, err := enc.Encode(buf, data)
+		if err != nil {
+			return nil, err
+		}
+		s.Packets = append(s.Packets, append([]byte(nil), data[:m]...))
+	}
+	return s, nil
+}
+
+// EncodeExactFloat32 is the same as EncodeExact, but for float32 audio.
+func (enc *Encoder) EncodeExactFloat32(pcm []float32, frame time.Duration) (*ExactStream, error) {
+	s, frameSize, err := enc.newExactStream(len(pcm), frame)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]float32, frameSize*enc.channels)
+	data := make([]byte, maxEncodedFrameSize)
+	for i := 0; i < exactFrames(s.Samples, s.PreSkip, frameSize); i++ {
+		n := 0
+		if start := i * len(buf); start < len(pcm) {
+			n = copy(buf, pcm[start:])
+		}
+		for j := n; j < len(buf); j++ {
+			buf[j] = 0
+		}
+		m, err := enc.EncodeFloat32(buf, data)
+		if err != nil {
+			return nil, err
+		}
+		s.Packets = append(s.Packets, append([]byte(nil), data[:m]...))
+	}
+	return s, nil
+}
+
+func (enc *Encoder) newExactStream(length int, frame time.Duration) (*ExactStream, int, error) {
+	if enc.p == nil {
+		return nil, 0, enc.errUninitialized()
+	}
+	if length%enc.channels != 0 {
+		return nil, 0, fmt.Errorf("opus: input buffer length must be multiple of channels")
+	}
+	frameSize := FrameSamples(enc.sample_rate, frame)
+	if err := validateFrameSize(enc.sample_rate, enc.channels, frameSize); err != nil {
+		return nil, 0, err
+	}
+	preSkip, err := enc.Lookahead()
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ExactStream{
+		SampleRate: enc.sample_rate,
+		Channels:   enc.channels,
+		Samples:    length / enc.channels,
+		PreSkip:    preSkip,
+	}, frameSize, nil
+}
+
+// checkExactStream verifies the decoder matches the stream, and returns the
+// number of output channels, which SetChannelMap, SetMonoOutput and
+// SetStereoOutput may make differ from the stream's, and the interleaved
+// length of the decoded audio before trimming.
+func (dec *Decoder) checkExactStream(s *ExactStream) (int, int, error) {
+	if dec.p == nil {
+		return 0, 0, dec.errUninitialized()
+	}
+	if s.SampleRate != dec.sample_rate || s.Channels != dec.channels {
+		return 0, 0, fmt.Errorf("opus: stream is %d Hz with %d channels, decoder %d Hz with %d channels",
+			s.SampleRate, s.Channels, dec.sample_rate, dec.channels)
+	}
+	ch := dec.outChannels()
+	return ch, (s.PreSkip + s.Samples) * ch, nil
+}
+
+// DecodeExact decodes an ExactStream, returning exactly the number of samples
+// that were encoded, with as many channels as the decoder outputs. Use a
+// freshly initialized (or reset) decoder.
+func (dec *Decoder) DecodeExact(s *ExactStream) ([]int16, error) {
+	ch, length, err := dec.checkExactStream(s)
+	if err != nil {
+		return nil, err
+	}
+	pcm := make([]int16, 0, length)
+	buf := make([]int16, FrameSamples(dec.sample_rate, 120*time.Millisecond)*dec.bufChannels())
+	for _, p := range s.Packets {
+		n, err := dec.Decode(p, buf)
+		if err != nil {
+			return nil, err
+		}
+		pcm = append(pcm, buf[:n*ch]...)
+	}
+	if len(pcm) < length {
+		return nil, fmt.Errorf("opus: stream decodes to %d samples, expected at least %d", len(pcm)/ch, length/ch)
+	}
+	return pcm[s.PreSkip*ch : length], nil
+}
+
+// DecodeExactFloat32 is the same as DecodeExact, but for float32 audio.
+func (dec *Decoder) DecodeExactFloat32(s *ExactStream) ([]float32, error) {
+	ch, length, err := dec.checkExactStream(s)
+	if err != nil {
+		return nil, err
+	}
+	pcm := make([]float32, 0, length)
+	buf := make([]float32, FrameSamples(dec.sample_rate, 120*time.Millisecond)*dec.bufChannels())
+	for _, p := range s.Packets {
+		n, err := dec.DecodeFloat32(p, buf)
+		if err != nil {
+			return nil, err
+		}
+		pcm = append(pcm, buf[:n*ch]...)
+	}
+	if len(pcm) < length {
+		return nil, fmt.Errorf("opus: stream decodes to %d samples, expected at least %d", len(pcm)/ch, length/ch)
+	}
+	return pcm[s.PreSkip*ch : length], nil
+}
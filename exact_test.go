@@ -0,0 +1,132 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestExactRoundTripLength(t *testing.T) {
+	for _, tc := range []struct {
+		sampleRate int
+		channels   int
+		frame      time.Duration
+	}{
+		{48000, 1, 20 * time.Millisecond},
+		{48000, 2, 10 * time.Millisecond},
+		{16000, 1, 60 * time.Millisecond},
+		{8000, 2, 2500 * time.Microsecond},
+	} {
+		roundTrip := func(length uint16) bool {
+			samples := int(length) % 10000
+			enc, err := NewEncoder(tc.sampleRate, tc.channels, AppAudio)
+			if err != nil {
+				t.Fatalf("Error creating new encoder: %v", err)
+			}
+			dec, err := NewDecoder(tc.sampleRate, tc.channels)
+			if err != nil {
+				t.Fatalf("Error creating new decoder: %v", err)
+			}
+			pcm := make([]int16, samples*tc.channels)
+			addSine(pcm, tc.sampleRate, 440)
+			s, err := enc.EncodeExact(pcm, tc.frame)
+			if err != nil {
+				t.Errorf("Couldn't encode %d samples: %v", samples, err)
+				return false
+			}
+			out, err := dec.DecodeExact(s)
+			if err != nil {
+				t.Errorf("Couldn't decode %d samples: %v", samples, err)
+				return false
+			}
+			return len(out) == len(pcm)
+		}
+		if err := quick.Check(roundTrip, &quick.Config{MaxCount: 20}); err != nil {
+			t.Errorf("%d Hz, %d channels, %v frames: %v", tc.sampleRate, tc.channels, tc.frame, err)
+		}
+	}
+}
+
+func TestExactRoundTripAlignment(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const SAMPLES = 12345
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppAudio)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	pcm := make([]float32, SAMPLES)
+	addSineFloat32(pcm, SAMPLE_RATE, 440)
+	s, err := enc.EncodeExactFloat32(pcm, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Couldn't encode: %v", err)
+	}
+	if s.PreSkip == 0 {
+		t.Errorf("Expected a pre-skip")
+	}
+	out, err := dec.DecodeExactFloat32(s)
+	if err != nil {
+		t.Fatalf("Couldn't decode: %v", err)
+	}
+	if len(out) != SAMPLES {
+		t.Fatalf("Expected %d samples, got %d", SAMPLES, len(out))
+	}
+	// Aligned output correlates with the input; output delayed by the
+	// lookahead wouldn't, at 440 Hz.
+	var dot, ein, eout float64
+	for i := 1000; i < SAMPLES-1000; i++ {
+		dot += float64(pcm[i] * out[i])
+		ein += float64(pcm[i] * pcm[i])
+		eout += float64(out[i] * out[i])
+	}
+	if c := dot / math.Sqrt(ein*eout); c < 0.9 {
+		t.Errorf("Output not aligned with input: correlation %.2f", c)
+	}
+}
+
+func TestExactOutputChannels(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const SAMPLES = 5000
+	for _, tc := range []struct {
+		channels int
+		setup    func(dec *Decoder) error
+		out      int
+	}{
+		{2, func(dec *Decoder) error { return dec.SetMonoOutput(true) }, 1},
+		{2, func(dec *Decoder) error { return dec.SetChannelMap([]int{1}) }, 1},
+		{1, func(dec *Decoder) error { return dec.SetStereoOutput(true) }, 2},
+	} {
+		enc, err := NewEncoder(SAMPLE_RATE, tc.channels, AppAudio)
+		if err != nil {
+			t.Fatalf("Error creating new encoder: %v", err)
+		}
+		pcm := make([]int16, SAMPLES*tc.channels)
+		addSine(pcm, SAMPLE_RATE, 440)
+		s, err := enc.EncodeExact(pcm, 20*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		dec, err := NewDecoder(SAMPLE_RATE, tc.channels)
+		if err != nil {
+			t.Fatalf("Error creating new decoder: %v", err)
+		}
+		if err := tc.setup(dec); err != nil {
+			t.Fatalf("Error setting output mode: %v", err)
+		}
+		out, err := dec.DecodeExact(s)
+		if err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		if len(out) != SAMPLES*tc.out {
+			t.Errorf("Expected %d samples for %d output channels, got %d", SAMPLES*tc.out, tc.out, len(out))
+		}
+	}
+}
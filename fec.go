@@ -0,0 +1,177 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultLostFrame is the duration concealed for a lost packet when the
+// decoder has no idea of the packet duration yet.
+const defaultLostFrame = 20 * time.Millisecond
+
+// FECReceiver decodes a sequence of packets with one packet of delay, so that
+// in-band FEC data in packet N+1 can be used to repair a lost packet N. This
+// is the ordering dance DecodeFEC requires, done once so applications don't
+// have to.
+//
+// Every call to Decode passes in the next packet (or nil if it was lost) and
+// produces the audio for the packet before it. Lost packets are recovered with
+// DecodeFEC if the following packet arrived, and with PLC otherwise. Call
+// Flush at the end of the stream to get the audio of the last packet.
+//
+// The encoder must have in-band FEC enabled for this to be of any use, see
+// Encoder.SetInBandFEC. The extra latency is reported by Latency.
+type FECReceiver struct {
+	dec *Decoder
+	// The held back packet, nil if it was lost
+	held []byte
+	// Whether there is a held back packet (or lost packet) at all
+	holding bool
+}
+
+// NewFECReceiver creates a FECReceiver around the decoder. The decoder must
+// not be used directly while the receiver is in use.
+func NewFECReceiver(dec *Decoder) *FECReceiver {
+	return &FECReceiver{dec: dec}
+}
+
+// Latency returns the delay added by holding back a packet: the duration of
+// the last packet decoded or concealed.
+func (r *FECReceiver) Latency() (time.Duration, error) {
+	n, err := r.frameSize()
+	if err != nil {
+		return 0, err
+	}
+	return SamplesDuration(r.dec.sample_rate, n), nil
+}
+
+// frameSize returns the number of samples per channel to recover for a lost
+// packet.
+func (r *FECReceiver) frameSize() (int, error) {
+	n, err := r.dec.LastPacketDuration()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		n = FrameSamples(r.dec.sample_rate, defaultLostFrame)
+	}
+	return n, nil
+}
+
+// hold stores the next packet, copying it because the caller may reuse the
+// buffer.
+func (r *FECReceiver) hold(data []byte) {
+	r.held = append(r.held[:0], data...)
+	if len(data) == 0 {
+		r.held = nil
+	}
+	r.holding = true
+}
+
+// Decode passes in the next packet, or nil if it was lost, and decodes the
+// previous one into pcm. Returns the number of samples per channel written,
+// which is 0 for the very first packet.
+func (r *FECReceiver) Decode(data []byte, pcm []int16) (int, error) {
+	if !r.holding {
+		r.hold(data)
+		return 0, nil
+	}
+	n, err := r.decode(r.held, data, pcm)
+	if err != nil {
+		return 0, err
+	}
+	r.hold(data)
+	return n, nil
+}
+
+// Flush decodes the held back packet, if any, at the end of the stream.
+func (r *FECReceiver) Flush(pcm []int16) (int, error) {
+	if !r.holding {
+		return 0, nil
+	}
+	n, err := r.decode(r.held, nil, pcm)
+	if err != nil {
+		return 0, err
+	}
+	r.held = r.held[:0]
+	r.holding = false
+	return n, nil
+}
+
+func (r *FECReceiver) decode(held, next []byte, pcm []int16) (int, error) {
+	if held != nil {
+		return r.dec.Decode(held, pcm)
+	}
+	n, err := r.frameSize()
+	if err != nil {
+		return 0, err
+	}
+	if cap(pcm) < n*r.dec.bufChannels() {
+		return 0, fmt.Errorf("opus: target buffer too small: lost packet needs %d samples per channel", n)
+	}
+	frame := pcm[: n*r.dec.bufChannels() : n*r.dec.bufChannels()]
+	if len(next) > 0 {
+		err = r.dec.DecodeFEC(next, frame)
+	} else {
+		err = r.dec.DecodePLC(frame)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DecodeFloat32 is the same as Decode, but for float32 audio.
+func (r *FECReceiver) DecodeFloat32(data []byte, pcm []float32) (int, error) {
+	if !r.holding {
+		r.hold(data)
+		return 0, nil
+	}
+	n, err := r.decodeFloat32(r.held, data, pcm)
+	if err != nil {
+		return 0, err
+	}
+	r.hold(data)
+	return n, nil
+}
+
+// FlushFloat32 is the same as Flush, but for float32 audio.
+func (r *FECReceiver) FlushFloat32(pcm []float32) (int, error) {
+	if !r.holding {
+		return 0, nil
+	}
+	n, err := r.decodeFloat32(r.held, nil, pcm)
+	if err != nil {
+		return 0, err
+	}
+	r.held = r.held[:0]
+	r.holding = false
+	return n, nil
+}
+
+func (r *FECReceiver) decodeFloat32(held, next []byte, pcm []float32) (int, error) {
+	if held != nil {
+		return r.dec.DecodeFloat32(held, pcm)
+	}
+	n, err := r.frameSize()
+	if err != nil {
+		return 0, err
+	}
+	if cap(pcm) < n*r.dec.bufChannels() {
+		return 0, fmt.Errorf("opus: target buffer too small: lost packet needs %d samples per channel", n)
+	}
+	frame := pcm[: n*r.dec.bufChannels() : n*r.dec.bufChannels()]
+	if len(next) > 0 {
+		err = r.dec.DecodeFECFloat32(next, frame)
+	} else {
+		err = r.dec.DecodePLCFloat32(frame)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
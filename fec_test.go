@@ -0,0 +1,136 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFECReceiver(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const NUMBER_OF_FRAMES = 10
+	const LOST = 4
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.SetInBandFEC(true); err != nil {
+		t.Fatalf("Error enabling FEC: %v", err)
+	}
+	if err := enc.SetPacketLossPerc(30); err != nil {
+		t.Fatalf("Error setting packet loss: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	addSine(pcm, SAMPLE_RATE, G4)
+	var packets [][]byte
+	for i := 0; i < NUMBER_OF_FRAMES; i++ {
+		data := make([]byte, 1000)
+		n, err := enc.Encode(pcm[i*FRAME_SIZE:(i+1)*FRAME_SIZE], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		packets = append(packets, data[:n])
+	}
+	packets[LOST] = nil
+
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	r := NewFECReceiver(dec)
+	out := make([]int16, FRAME_SIZE)
+	total := 0
+	for i, p := range packets {
+		n, err := r.Decode(p, out)
+		if err != nil {
+			t.Fatalf("Couldn't decode packet %d: %v", i, err)
+		}
+		if i == 0 && n != 0 {
+			t.Errorf("Expected no output for first packet, got %d samples", n)
+		}
+		total += n
+	}
+	n, err := r.Flush(out)
+	if err != nil {
+		t.Fatalf("Couldn't flush: %v", err)
+	}
+	total += n
+	if total != FRAME_SIZE*NUMBER_OF_FRAMES {
+		t.Errorf("Expected %d samples, got %d", FRAME_SIZE*NUMBER_OF_FRAMES, total)
+	}
+	if stats := dec.ConcealStats(); stats.FECFrames != 1 {
+		t.Errorf("Expected 1 FEC frame, got %d", stats.FECFrames)
+	}
+	latency, err := r.Latency()
+	if err != nil {
+		t.Fatalf("Couldn't get latency: %v", err)
+	}
+	if latency != 20*time.Millisecond {
+		t.Errorf("Expected 20ms latency, got %v", latency)
+	}
+}
+
+func TestFECReceiverStereoOutput(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const NUMBER_OF_FRAMES = 6
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.SetInBandFEC(true); err != nil {
+		t.Fatalf("Error enabling FEC: %v", err)
+	}
+	if err := enc.SetPacketLossPerc(30); err != nil {
+		t.Fatalf("Error setting packet loss: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	addSine(pcm, SAMPLE_RATE, 440)
+	var packets [][]byte
+	for i := 0; i < NUMBER_OF_FRAMES; i++ {
+		data := make([]byte, 1000)
+		n, err := enc.Encode(pcm[i*FRAME_SIZE:(i+1)*FRAME_SIZE], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		packets = append(packets, data[:n])
+	}
+	// One packet recovered with FEC, the last one concealed on Flush
+	packets[2] = nil
+	packets[NUMBER_OF_FRAMES-1] = nil
+
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetStereoOutput(true); err != nil {
+		t.Fatalf("Error enabling stereo output: %v", err)
+	}
+	r := NewFECReceiver(dec)
+	out := make([]int16, 2*FRAME_SIZE)
+	total := 0
+	for i, p := range packets {
+		n, err := r.Decode(p, out)
+		if err != nil {
+			t.Fatalf("Couldn't decode packet %d: %v", i, err)
+		}
+		total += n
+	}
+	n, err := r.Flush(out)
+	if err != nil {
+		t.Fatalf("Couldn't flush: %v", err)
+	}
+	total += n
+	if total != FRAME_SIZE*NUMBER_OF_FRAMES {
+		t.Errorf("Expected %d samples, got %d", FRAME_SIZE*NUMBER_OF_FRAMES, total)
+	}
+	stats := dec.ConcealStats()
+	if stats.FECSamples != FRAME_SIZE || stats.ConcealedSamples != FRAME_SIZE {
+		t.Errorf("Expected one full frame each of FEC and PLC, got %+v", stats)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// SampleFormat is the in-memory representation of a single PCM sample.
+type SampleFormat int
+
+const (
+	// Signed 16 bit integer samples, as used by Encode and Decode
+	SampleFormatInt16 SampleFormat = iota
+	// 32 bit floating point samples, as used by EncodeFloat32 and
+	// DecodeFloat32
+	SampleFormatFloat32
+)
+
+// Size returns the size of a single sample in bytes, or an error for an
+// unknown format.
+func (f SampleFormat) Size() (int, error) {
+	switch f {
+	case SampleFormatInt16:
+		return 2, nil
+	case SampleFormatFloat32:
+		return 4, nil
+	default:
+		return 0, fmt.Errorf("opus: unknown sample format %d", int(f))
+	}
+}
+
+// FrameSamples returns the number of samples per channel in a frame of the
+// given duration, e.g. 960 for 20 ms at 48 kHz.
+func FrameSamples(sample_rate int, d time.Duration) int {
+	return int(int64(sample_rate) * int64(d) / int64(time.Second))
+}
+
+// FrameBytes returns the size in bytes of a frame of interleaved PCM data of
+// the given duration.
+func FrameBytes(sample_rate int, channels int, d time.Duration, format SampleFormat) (int, error) {
+	size, err := format.Size()
+	if err != nil {
+		return 0, err
+	}
+	return FrameSamples(sample_rate, d) * channels * size, nil
+}
+
+// SamplesDuration returns the duration of the given number of samples per
+// channel, the inverse of FrameSamples.
+func SamplesDuration(sample_rate int, samples int) time.Duration {
+	return time.Duration(int64(samples) * int64(time.Second) / int64(sample_rate))
+}
@@ -0,0 +1,42 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrameSamples(t *testing.T) {
+	cases := []struct {
+		rate    int
+		d       time.Duration
+		samples int
+	}{
+		{48000, 20 * time.Millisecond, 960},
+		{48000, 2500 * time.Microsecond, 120},
+		{8000, 60 * time.Millisecond, 480},
+		{16000, 120 * time.Millisecond, 1920},
+	}
+	for _, c := range cases {
+		if n := FrameSamples(c.rate, c.d); n != c.samples {
+			t.Errorf("FrameSamples(%d, %v) = %d, expected %d", c.rate, c.d, n, c.samples)
+		}
+	}
+}
+
+func TestFrameBytes(t *testing.T) {
+	n, err := FrameBytes(48000, 2, 20*time.Millisecond, SampleFormatInt16)
+	if err != nil || n != 3840 {
+		t.Errorf("Unexpected int16 frame size: %d (%v)", n, err)
+	}
+	n, err = FrameBytes(8000, 1, 10*time.Millisecond, SampleFormatFloat32)
+	if err != nil || n != 320 {
+		t.Errorf("Unexpected float32 frame size: %d (%v)", n, err)
+	}
+	if _, err := FrameBytes(48000, 1, 20*time.Millisecond, SampleFormat(42)); err == nil {
+		t.Errorf("Expected error for unknown sample format")
+	}
+}
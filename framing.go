@@ -0,0 +1,77 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// A simple framing for storing bare Opus packets in files or sending them over
+// pipes, without a container like Ogg: every packet is preceded by its length
+// as an unsigned varint (encoding/binary). There is no header, so the reader
+// must know the sample rate and channel count by other means. An empty packet
+// can mark a lost one.
+
+// MaxFramedPacketSize is the largest packet PacketReader accepts. Real Opus
+// packets are much smaller; anything larger is taken to be corrupt input.
+const MaxFramedPacketSize = 1 << 16
+
+// PacketWriter writes length prefixed packets.
+type PacketWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewPacketWriter creates a PacketWriter writing to w.
+func NewPacketWriter(w io.Writer) *PacketWriter {
+	return &PacketWriter{w: w}
+}
+
+// WritePacket writes one packet, with a single call to the underlying writer.
+func (pw *PacketWriter) WritePacket(packet []byte) error {
+	if len(packet) > MaxFramedPacketSize {
+		return fmt.Errorf("opus: packet too large to frame: %d bytes", len(packet))
+	}
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(packet)))
+	pw.buf = append(append(pw.buf[:0], prefix[:n]...), packet...)
+	_, err := pw.w.Write(pw.buf)
+	return err
+}
+
+// PacketReader reads length prefixed packets, as written by PacketWriter.
+type PacketReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewPacketReader creates a PacketReader reading from r.
+func NewPacketReader(r io.Reader) *PacketReader {
+	return &PacketReader{r: bufio.NewReader(r)}
+}
+
+// ReadPacket reads the next packet. The packet is only valid until the next
+// call. Returns io.EOF at the end of the input, and io.ErrUnexpectedEOF if it
+// ends in the middle of a packet.
+func (pr *PacketReader) ReadPacket() ([]byte, error) {
+	size, err := binary.ReadUvarint(pr.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxFramedPacketSize {
+		return nil, fmt.Errorf("opus: framed packet too large: %d bytes", size)
+	}
+	if cap(pr.buf) < int(size) {
+		pr.buf = make([]byte, size)
+	}
+	pr.buf = pr.buf[:size]
+	if _, err := io.ReadFull(pr.r, pr.buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return pr.buf, nil
+}
@@ -0,0 +1,79 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPacketFraming(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE_MS = 20
+	const FRAME_SIZE = SAMPLE_RATE * FRAME_SIZE_MS / 1000
+	const NUMBER_OF_FRAMES = 10
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	addSine(pcm, SAMPLE_RATE, G4)
+	var buf bytes.Buffer
+	w := NewPacketWriter(&buf)
+	var packets [][]byte
+	for i := 0; i < NUMBER_OF_FRAMES; i++ {
+		data := make([]byte, 1000)
+		n, err := enc.Encode(pcm[i*FRAME_SIZE:(i+1)*FRAME_SIZE], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		packets = append(packets, data[:n])
+		if err := w.WritePacket(data[:n]); err != nil {
+			t.Fatalf("Error writing packet: %v", err)
+		}
+	}
+	// A lost packet
+	if err := w.WritePacket(nil); err != nil {
+		t.Fatalf("Error writing empty packet: %v", err)
+	}
+
+	r := NewPacketReader(bytes.NewReader(buf.Bytes()))
+	for i, expected := range packets {
+		packet, err := r.ReadPacket()
+		if err != nil {
+			t.Fatalf("Error reading packet %d: %v", i, err)
+		}
+		if !bytes.Equal(packet, expected) {
+			t.Errorf("Packet %d differs after framing", i)
+		}
+		info, err := ParsePacket(packet)
+		if err != nil {
+			t.Fatalf("Error parsing packet %d: %v", i, err)
+		}
+		if info.Duration() != FRAME_SIZE_MS*time.Millisecond {
+			t.Errorf("Expected packet %d to last %d ms, got %v", i, FRAME_SIZE_MS, info.Duration())
+		}
+	}
+	if packet, err := r.ReadPacket(); err != nil || len(packet) != 0 {
+		t.Errorf("Expected empty packet, got %d bytes, error %v", len(packet), err)
+	}
+	if _, err := r.ReadPacket(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+
+	// Truncated in the middle of a packet
+	r = NewPacketReader(bytes.NewReader(buf.Bytes()[:len(packets[0])]))
+	if _, err := r.ReadPacket(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected unexpected EOF, got %v", err)
+	}
+	// Absurd length
+	r = NewPacketReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0x7f}))
+	if _, err := r.ReadPacket(); err == nil {
+		t.Errorf("Expected error for oversized packet")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// Package g711 implements the ITU-T G.711 µ-law (PCMU) and A-law (PCMA)
+// codecs, as used on the PSTN. Both encode one 16 bit sample into one byte,
+// at 8 kHz mono.
+package g711
+
+import (
+	"fmt"
+)
+
+// SampleRate is the sample rate of G.711 audio.
+const SampleRate = 8000
+
+// Law selects the G.711 companding law.
+type Law int
+
+const (
+	// µ-law, RTP payload type PCMU, used in North America and Japan
+	MuLaw Law = iota
+	// A-law, RTP payload type PCMA, used in the rest of the world
+	ALaw
+)
+
+func (l Law) String() string {
+	switch l {
+	case MuLaw:
+		return "PCMU"
+	case ALaw:
+		return "PCMA"
+	default:
+		return fmt.Sprintf("Law(%d)", int(l))
+	}
+}
+
+const (
+	ulawBias = 0x84
+	ulawClip = 32635
+)
+
+// EncodeMuLaw compresses a single sample with µ-law.
+func EncodeMuLaw(s int16) byte {
+	x := int(s)
+	sign := 0
+	if x < 0 {
+		x = -x
+		sign = 0x80
+	}
+	if x > ulawClip {
+		x = ulawClip
+	}
+	x += ulawBias
+	exp := 7
+	for mask := 0x4000; x&mask == 0 && exp > 0; mask >>= 1 {
+		exp--
+	}
+	mantissa := (x >> (exp + 3)) & 0x0f
+	return ^byte(sign | exp<<4 | mantissa)
+}
+
+// DecodeMuLaw expands a single µ-law byte.
+func DecodeMuLaw(b byte) int16 {
+	b = ^b
+	exp := int(b>>4) & 0x07
+	mantissa := int(b & 0x0f)
+	x := (mantissa<<3 + ulawBias) << exp
+	x -= ulawBias
+	if b&0x80 != 0 {
+		return int16(-x)
+	}
+	return int16(x)
+}
+
+// EncodeALaw compresses a single sample with A-law.
+func EncodeALaw(s int16) byte {
+	x := int(s) >> 3
+	sign := 0x80
+	if x < 0 {
+		x = -x - 1
+		sign = 0
+	}
+	var b int
+	if x < 32 {
+		b = x >> 1
+	} else {
+		exp := 1
+		for x >= 64<<(exp-1) && exp < 7 {
+			exp++
+		}
+		b = exp<<4 | (x>>exp)&0x0f
+	}
+	return byte(sign|b) ^ 0x55
+}
+
+// DecodeALaw expands a single A-law byte.
+func DecodeALaw(b byte) int16 {
+	b ^= 0x55
+	exp := int(b>>4) & 0x07
+	mantissa := int(b & 0x0f)
+	var x int
+	if exp == 0 {
+		x = mantissa<<4 + 8
+	} else {
+		x = (mantissa<<4 + 0x108) << (exp - 1)
+	}
+	if b&0x80 == 0 {
+		return int16(-x)
+	}
+	return int16(x)
+}
+
+// Encode compresses PCM samples into data, which must be at least as long as
+// pcm. Returns the number of bytes written.
+func Encode(law Law, pcm []int16, data []byte) (int, error) {
+	if len(data) < len(pcm) {
+		return 0, fmt.Errorf("g711: target buffer too small: %d bytes for %d samples", len(data), len(pcm))
+	}
+	switch law {
+	case MuLaw:
+		for i, s := range pcm {
+			data[i] = EncodeMuLaw(s)
+		}
+	case ALaw:
+		for i, s := range pcm {
+			data[i] = EncodeALaw(s)
+		}
+	default:
+		return 0, fmt.Errorf("g711: unknown law: %v", law)
+	}
+	return len(pcm), nil
+}
+
+// Decode expands G.711 data into pcm, which must be at least as long as data.
+// Returns the number of samples written.
+func Decode(law Law, data []byte, pcm []int16) (int, error) {
+	if len(pcm) < len(data) {
+		return 0, fmt.Errorf("g711: target buffer too small: %d samples for %d bytes", len(pcm), len(data))
+	}
+	switch law {
+	case MuLaw:
+		for i, b := range data {
+			pcm[i] = DecodeMuLaw(b)
+		}
+	case ALaw:
+		for i, b := range data {
+			pcm[i] = DecodeALaw(b)
+		}
+	default:
+		return 0, fmt.Errorf("g711: unknown law: %v", law)
+	}
+	return len(data), nil
+}
@@ -0,0 +1,68 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package g711
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKnownValues(t *testing.T) {
+	// Silence, and full scale, from the reference implementation
+	cases := []struct {
+		s          int16
+		ulaw, alaw byte
+	}{
+		{0, 0xff, 0xd5},
+		{-1, 0x7f, 0x55},
+		{32767, 0x80, 0xaa},
+		{-32768, 0x00, 0x2a},
+	}
+	for _, c := range cases {
+		if b := EncodeMuLaw(c.s); b != c.ulaw {
+			t.Errorf("µ-law of %d: expected %#x, got %#x", c.s, c.ulaw, b)
+		}
+		if b := EncodeALaw(c.s); b != c.alaw {
+			t.Errorf("A-law of %d: expected %#x, got %#x", c.s, c.alaw, b)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, law := range []Law{MuLaw, ALaw} {
+		for s := math.MinInt16; s <= math.MaxInt16; s += 7 {
+			pcm := []int16{int16(s)}
+			data := make([]byte, 1)
+			if _, err := Encode(law, pcm, data); err != nil {
+				t.Fatalf("Error encoding: %v", err)
+			}
+			if _, err := Decode(law, data, pcm); err != nil {
+				t.Fatalf("Error decoding: %v", err)
+			}
+			// Quantization error grows with the magnitude: about 1/16 of it,
+			// plus a constant near zero
+			diff := math.Abs(float64(int(pcm[0]) - s))
+			if max := math.Abs(float64(s))/16 + 16; diff > max {
+				t.Fatalf("%v: %d decoded as %d", law, s, pcm[0])
+			}
+		}
+	}
+}
+
+func TestDecodeAllCodes(t *testing.T) {
+	// Every code survives a decode/encode round trip
+	for _, law := range []Law{MuLaw, ALaw} {
+		for b := 0; b < 256; b++ {
+			pcm := make([]int16, 1)
+			data := []byte{byte(b)}
+			Decode(law, data, pcm)
+			Encode(law, pcm, data)
+			// µ-law has two codes for zero
+			if data[0] != byte(b) && !(law == MuLaw && b == 0x7f) {
+				t.Errorf("%v: code %#x decoded to %d, encoded as %#x", law, b, pcm[0], data[0])
+			}
+		}
+	}
+}
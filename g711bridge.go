@@ -0,0 +1,83 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"time"
+
+	"github.com/hraban/opus/v2/g711"
+)
+
+// G711Bridge transcodes between Opus and G.711, e.g. in a gateway between SIP
+// and the PSTN. Opus audio is decoded and encoded at 8 kHz mono, the G.711
+// format; libopus takes care of the resampling and downmixing from whatever
+// the other side sends.
+type G711Bridge struct {
+	law g711.Law
+	enc *Encoder
+	dec *Decoder
+	pcm []int16
+}
+
+// NewG711Bridge creates a bridge using the given G.711 law.
+func NewG711Bridge(law g711.Law) (*G711Bridge, error) {
+	// Encoding nothing only checks the law
+	if _, err := g711.Encode(law, nil, nil); err != nil {
+		return nil, err
+	}
+	enc, err := NewEncoder(g711.SampleRate, 1, AppVoIP)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := NewDecoder(g711.SampleRate, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &G711Bridge{
+		law: law,
+		enc: enc,
+		dec: dec,
+		pcm: make([]int16, FrameSamples(g711.SampleRate, 120*time.Millisecond)),
+	}, nil
+}
+
+// Encoder returns the Opus encoder used for the G.711 to Opus direction, to
+// change its settings, e.g. the bitrate.
+func (b *G711Bridge) Encoder() *Encoder {
+	return b.enc
+}
+
+// ToG711 transcodes an Opus packet to G.711. An empty packet is treated as
+// lost and concealed with PLC. Returns the number of bytes written to data.
+func (b *G711Bridge) ToG711(packet []byte, data []byte) (int, error) {
+	var n int
+	var err error
+	if len(packet) == 0 {
+		if n, err = b.dec.LastPacketDuration(); err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			n = FrameSamples(g711.SampleRate, defaultLostFrame)
+		}
+		err = b.dec.DecodePLC(b.pcm[:n:n])
+	} else {
+		n, err = b.dec.Decode(packet, b.pcm)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return g711.Encode(b.law, b.pcm[:n], data)
+}
+
+// FromG711 transcodes G.711 data to an Opus packet. The G.711 data must be a
+// valid Opus frame duration, e.g. 160 bytes for 20 ms. Returns the number of
+// bytes written to packet.
+func (b *G711Bridge) FromG711(data []byte, packet []byte) (int, error) {
+	n, err := g711.Decode(b.law, data, b.pcm)
+	if err != nil {
+		return 0, err
+	}
+	return b.enc.Encode(b.pcm[:n], packet)
+}
@@ -0,0 +1,57 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+
+	"github.com/hraban/opus/v2/g711"
+)
+
+func TestG711Bridge(t *testing.T) {
+	const G4 = 391.995
+	const FRAME_SIZE = g711.SampleRate * 20 / 1000
+	for _, law := range []g711.Law{g711.MuLaw, g711.ALaw} {
+		b, err := NewG711Bridge(law)
+		if err != nil {
+			t.Fatalf("Error creating bridge: %v", err)
+		}
+		pcm := make([]int16, FRAME_SIZE)
+		addSine(pcm, g711.SampleRate, G4)
+		data := make([]byte, FRAME_SIZE)
+		if _, err := g711.Encode(law, pcm, data); err != nil {
+			t.Fatalf("Error encoding G.711: %v", err)
+		}
+		packet := make([]byte, 1000)
+		n, err := b.FromG711(data, packet)
+		if err != nil {
+			t.Fatalf("Error transcoding to Opus: %v", err)
+		}
+		out := make([]byte, FRAME_SIZE)
+		n, err = b.ToG711(packet[:n], out)
+		if err != nil {
+			t.Fatalf("Error transcoding to G.711: %v", err)
+		}
+		if n != FRAME_SIZE {
+			t.Errorf("Expected %d bytes of G.711, got %d", FRAME_SIZE, n)
+		}
+		n, err = b.ToG711(nil, out)
+		if err != nil {
+			t.Fatalf("Error concealing lost packet: %v", err)
+		}
+		if n != FRAME_SIZE {
+			t.Errorf("Expected %d bytes of concealed G.711, got %d", FRAME_SIZE, n)
+		}
+		if _, err := b.FromG711(data[:100], packet); err == nil {
+			t.Errorf("Expected error for invalid frame size")
+		}
+	}
+}
+
+func TestG711BridgeInvalidLaw(t *testing.T) {
+	if _, err := NewG711Bridge(42); err == nil {
+		t.Errorf("Expected error for invalid law")
+	}
+}
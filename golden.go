@@ -0,0 +1,128 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+// Golden is a recorded encoding of a PCM fixture with pinned settings. It is
+// meant for regression tests in applications embedding this package: record
+// a Golden once, store it (it encodes to JSON), and Verify it in a test. Any
+// change in encoder behaviour, e.g. after upgrading libopus or this package,
+// will make verification fail.
+type Golden struct {
+	SampleRate int `json:"sample_rate"`
+	Channels   int `json:"channels"`
+	// Number of samples per channel in each frame
+	FrameSize int            `json:"frame_size"`
+	Config    EncoderConfig  `json:"config"`
+	Packets   []GoldenPacket `json:"packets"`
+}
+
+// GoldenPacket identifies a single encoded packet.
+type GoldenPacket struct {
+	// Final state of the encoder's range coder
+	FinalRange uint32 `json:"final_range"`
+	// Hex encoded SHA-256 of the packet
+	Hash string `json:"hash"`
+}
+
+// RecordGolden encodes the PCM data frame by frame with a fresh encoder using
+// the given settings, and records the result. Trailing samples which don't
+// fill a complete frame are ignored.
+func RecordGolden(sampleRate, channels, frameSize int, cfg EncoderConfig, pcm []int16) (*Golden, error) {
+	g := &Golden{
+		SampleRate: sampleRate,
+		Channels:   channels,
+		FrameSize:  frameSize,
+		Config:     cfg,
+	}
+	packets, err := g.encode(pcm)
+	if err != nil {
+		return nil, err
+	}
+	g.Packets = packets
+	return g, nil
+}
+
+// Verify encodes the PCM data again using the recorded settings and compares
+// the result to the recording. It returns an error describing the first
+// mismatch, if any.
+func (g *Golden) Verify(pcm []int16) error {
+	packets, err := g.encode(pcm)
+	if err != nil {
+		return err
+	}
+	if len(packets) != len(g.Packets) {
+		return fmt.Errorf("opus: golden mismatch: %d packets, expected %d", len(packets), len(g.Packets))
+	}
+	for i, p := range packets {
+		exp := g.Packets[i]
+		if p.FinalRange != exp.FinalRange {
+			return fmt.Errorf("opus: golden mismatch in packet %d: final range %08x, expected %08x", i, p.FinalRange, exp.FinalRange)
+		}
+		if p.Hash != exp.Hash {
+			return fmt.Errorf("opus: golden mismatch in packet %d: hash %s, expected %s", i, p.Hash, exp.Hash)
+		}
+	}
+	return nil
+}
+
+func (g *Golden) encode(pcm []int16) ([]GoldenPacket, error) {
+	if g.FrameSize <= 0 {
+		return nil, fmt.Errorf("opus: invalid frame size: %d", g.FrameSize)
+	}
+	enc, err := NewEncoder(g.SampleRate, g.Channels, g.Config.Application)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Config.ApplyTo(enc); err != nil {
+		return nil, err
+	}
+	step := g.FrameSize * g.Channels
+	data := make([]byte, RecommendedBufferSize)
+	var packets []GoldenPacket
+	for i := 0; i+step <= len(pcm); i += step {
+		n, err := enc.Encode(pcm[i:i+step], data)
+		if err != nil {
+			return nil, err
+		}
+		rng, err := enc.FinalRange()
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data[:n])
+		packets = append(packets, GoldenPacket{
+			FinalRange: rng,
+			Hash:       hex.EncodeToString(sum[:]),
+		})
+	}
+	return packets, nil
+}
+
+// GoldenFixture generates a deterministic PCM test signal of the given number
+// of samples per channel: a frequency sweep mixed with pseudo random noise,
+// which exercises both the tonal and noisy code paths of the encoder.
+func GoldenFixture(sampleRate, channels, samples int) []int16 {
+	pcm := make([]int16, samples*channels)
+	// Simple LCG, so the fixture doesn't depend on math/rand internals
+	var seed uint32 = 12345
+	for i := 0; i < samples; i++ {
+		t := float64(i) / float64(sampleRate)
+		freq := 100 + 4000*float64(i)/float64(samples)
+		tone := math.Sin(2 * math.Pi * freq * t)
+		for c := 0; c < channels; c++ {
+			seed = seed*1664525 + 1013904223
+			noise := float64(int32(seed>>16)-0x8000) / 0x8000
+			v := 0.6*tone + 0.1*noise
+			pcm[i*channels+c] = int16(v * math.MaxInt16)
+		}
+	}
+	return pcm
+}
@@ -0,0 +1,46 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGolden(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	pcm := GoldenFixture(SAMPLE_RATE, 2, FRAME_SIZE*10)
+	cfg := EncoderConfig{
+		Application:  AppAudio,
+		Bitrate:      64000,
+		Complexity:   10,
+		MaxBandwidth: Fullband,
+	}
+	g, err := RecordGolden(SAMPLE_RATE, 2, FRAME_SIZE, cfg, pcm)
+	if err != nil {
+		t.Fatalf("Error recording golden: %v", err)
+	}
+	if len(g.Packets) != 10 {
+		t.Fatalf("Unexpected number of golden packets: %d", len(g.Packets))
+	}
+	// Survives a round trip through JSON storage
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("Error marshalling golden: %v", err)
+	}
+	var stored Golden
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("Error unmarshalling golden: %v", err)
+	}
+	if err := stored.Verify(pcm); err != nil {
+		t.Errorf("Golden verification failed: %v", err)
+	}
+	// Different input must be detected
+	pcm[FRAME_SIZE*2*5] ^= 0x4000
+	if err := stored.Verify(pcm); err == nil {
+		t.Errorf("Expected golden mismatch for modified input")
+	}
+}
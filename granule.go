@@ -0,0 +1,67 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"time"
+)
+
+// In Ogg Opus, the granule position of a page is the number of 48 kHz samples
+// (per channel) decoded up to and including that page, counted from the
+// start of the stream including the pre-skip: the samples at the start which
+// the decoder must discard. See RFC 7845, section 4.
+
+// GranuleSampleRate is the rate at which Ogg Opus granule positions count,
+// regardless of the sample rate of the input or output.
+const GranuleSampleRate = 48000
+
+// GranuleToSamples converts a granule position to the number of playable 48
+// kHz samples (per channel) it corresponds to, i.e. with the pre-skip
+// removed. Positions inside the pre-skip map to 0.
+func GranuleToSamples(granule int64, preSkip int) int64 {
+	samples := granule - int64(preSkip)
+	if samples < 0 {
+		return 0
+	}
+	return samples
+}
+
+// SamplesToGranule converts a number of playable 48 kHz samples (per channel)
+// to the granule position marking their end.
+func SamplesToGranule(samples int64, preSkip int) int64 {
+	return samples + int64(preSkip)
+}
+
+// GranuleToDuration converts a granule position to the playback time it
+// corresponds to, with the pre-skip removed.
+func GranuleToDuration(granule int64, preSkip int) time.Duration {
+	return samples48kToDuration(GranuleToSamples(granule, preSkip))
+}
+
+// DurationToGranule converts a playback time to a granule position, rounding
+// down to a whole 48 kHz sample.
+func DurationToGranule(d time.Duration, preSkip int) int64 {
+	return SamplesToGranule(durationToSamples48k(d), preSkip)
+}
+
+// GranuleSamplesAt converts a number of 48 kHz samples, e.g. from
+// GranuleToSamples, to the equivalent number of samples at another sample
+// rate.
+func GranuleSamplesAt(samples int64, sample_rate int) int64 {
+	return samples * int64(sample_rate) / GranuleSampleRate
+}
+
+func samples48kToDuration(samples int64) time.Duration {
+	// Split to avoid overflowing int64 for very long streams
+	secs := samples / GranuleSampleRate
+	rem := samples % GranuleSampleRate
+	return time.Duration(secs)*time.Second + time.Duration(rem*int64(time.Second)/GranuleSampleRate)
+}
+
+func durationToSamples48k(d time.Duration) int64 {
+	secs := int64(d / time.Second)
+	rem := int64(d % time.Second)
+	return secs*GranuleSampleRate + rem*GranuleSampleRate/int64(time.Second)
+}
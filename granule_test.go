@@ -0,0 +1,37 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGranule(t *testing.T) {
+	const preSkip = 312
+	if s := GranuleToSamples(preSkip+48000, preSkip); s != 48000 {
+		t.Errorf("Unexpected sample count: %d", s)
+	}
+	if s := GranuleToSamples(100, preSkip); s != 0 {
+		t.Errorf("Granule inside pre-skip must map to 0, got %d", s)
+	}
+	if g := SamplesToGranule(960, preSkip); g != 960+preSkip {
+		t.Errorf("Unexpected granule position: %d", g)
+	}
+	if d := GranuleToDuration(preSkip+96000+480, preSkip); d != 2*time.Second+10*time.Millisecond {
+		t.Errorf("Unexpected duration: %v", d)
+	}
+	if g := DurationToGranule(20*time.Millisecond, preSkip); g != 960+preSkip {
+		t.Errorf("Unexpected granule position: %d", g)
+	}
+	if s := GranuleSamplesAt(48000, 16000); s != 16000 {
+		t.Errorf("Unexpected sample count at 16 kHz: %d", s)
+	}
+	// A week of audio doesn't overflow
+	week := 7 * 24 * time.Hour
+	if d := GranuleToDuration(DurationToGranule(week, preSkip), preSkip); d != week {
+		t.Errorf("Round trip mismatch: %v != %v", d, week)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrConcurrentUse is returned by encode and decode calls which overlap with
+// another call on the same instance, when the concurrency check is enabled.
+var ErrConcurrentUse = fmt.Errorf("opus: same encoder or decoder used from multiple goroutines at once")
+
+var concurrencyCheck int32
+
+// EnableConcurrencyCheck turns a cheap check for concurrent use of encoders
+// and decoders on or off (off by default). Neither is safe for concurrent
+// use; without the check, doing so anyway silently corrupts the codec state.
+// With the check, the offending call fails with ErrConcurrentUse instead.
+//
+// Unlike the race detector this costs only an atomic operation per call, so it
+// can be left on in production builds.
+func EnableConcurrencyCheck(on bool) {
+	var i int32
+	if on {
+		i = 1
+	}
+	atomic.StoreInt32(&concurrencyCheck, i)
+}
+
+// guard detects overlapping calls on the same codec.
+type guard struct {
+	busy int32
+}
+
+// enter reports whether the caller may proceed. Every successful enter must be
+// followed by a leave.
+func (g *guard) enter() bool {
+	if atomic.LoadInt32(&concurrencyCheck) == 0 {
+		return true
+	}
+	return atomic.CompareAndSwapInt32(&g.busy, 0, 1)
+}
+
+func (g *guard) leave() {
+	atomic.StoreInt32(&g.busy, 0)
+}
@@ -0,0 +1,52 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	var g guard
+	EnableConcurrencyCheck(false)
+	if !g.enter() || !g.enter() {
+		t.Errorf("Guard must not interfere when the check is disabled")
+	}
+	g.leave()
+
+	EnableConcurrencyCheck(true)
+	defer EnableConcurrencyCheck(false)
+	if !g.enter() {
+		t.Fatalf("Guard refused first entry")
+	}
+	if g.enter() {
+		t.Errorf("Guard allowed overlapping entry")
+	}
+	g.leave()
+	if !g.enter() {
+		t.Errorf("Guard refused entry after leave")
+	}
+	g.leave()
+}
+
+func TestEncoderConcurrencyCheck(t *testing.T) {
+	EnableConcurrencyCheck(true)
+	defer EnableConcurrencyCheck(false)
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, 960)
+	data := make([]byte, 1000)
+	// Simulate another goroutine being inside Encode right now
+	enc.guard.enter()
+	if _, err := enc.Encode(pcm, data); err != ErrConcurrentUse {
+		t.Errorf("Expected concurrent use error, got: %v", err)
+	}
+	enc.guard.leave()
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Errorf("Couldn't encode data: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"math"
+)
+
+// LevelEvent reports the audio levels of a stretch of decoded output.
+type LevelEvent struct {
+	// Levels per channel relative to full scale: 0 is silence and 1 is a full
+	// scale square wave. A full scale sine has an RMS of 0.707.
+	RMS  []float64
+	Peak []float64
+	// Number of samples per channel covered
+	Samples int
+}
+
+// LevelFunc receives level events, e.g. to drive a VU meter. It is called
+// from the goroutine reading the decoded output.
+type LevelFunc func(LevelEvent)
+
+// levelMeter accumulates the levels of interleaved PCM and emits an event
+// every period samples per channel. A nil meter does nothing.
+type levelMeter struct {
+	f      LevelFunc
+	period int
+	// Sum of squares and peak per channel since the last event
+	sums  []float64
+	peaks []float64
+	n     int
+}
+
+func newLevelMeter(channels, period int, f LevelFunc) *levelMeter {
+	return &levelMeter{
+		f:      f,
+		period: period,
+		sums:   make([]float64, channels),
+		peaks:  make([]float64, channels),
+	}
+}
+
+func (m *levelMeter) add(pcm []int16, samples int) {
+	if m == nil {
+		return
+	}
+	channels := len(m.sums)
+	pcm = pcm[:samples*channels]
+	for len(pcm) > 0 {
+		for c, s := range pcm[:channels] {
+			m.sample(c, float64(s)/32768)
+		}
+		pcm = pcm[channels:]
+		m.next()
+	}
+}
+
+func (m *levelMeter) addFloat32(pcm []float32, samples int) {
+	if m == nil {
+		return
+	}
+	channels := len(m.sums)
+	pcm = pcm[:samples*channels]
+	for len(pcm) > 0 {
+		for c, s := range pcm[:channels] {
+			m.sample(c, float64(s))
+		}
+		pcm = pcm[channels:]
+		m.next()
+	}
+}
+
+func (m *levelMeter) sample(c int, v float64) {
+	m.sums[c] += v * v
+	if v = math.Abs(v); v > m.peaks[c] {
+		m.peaks[c] = v
+	}
+}
+
+// next counts one sample per channel, emitting an event at the end of a
+// period.
+func (m *levelMeter) next() {
+	m.n++
+	if m.n < m.period {
+		return
+	}
+	e := LevelEvent{
+		RMS:     make([]float64, len(m.sums)),
+		Peak:    append([]float64(nil), m.peaks...),
+		Samples: m.n,
+	}
+	for c, sum := range m.sums {
+		e.RMS[c] = math.Sqrt(sum / float64(m.n))
+		m.sums[c] = 0
+		m.peaks[c] = 0
+	}
+	m.n = 0
+	m.f(e)
+}
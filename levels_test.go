@@ -0,0 +1,52 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLevelMeter(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const PERIOD = SAMPLE_RATE / 10
+	// Sine on the left channel, silence on the right
+	mono := make([]int16, SAMPLE_RATE)
+	addSine(mono, SAMPLE_RATE, G4)
+	pcm := make([]int16, 2*len(mono))
+	for i, s := range mono {
+		pcm[2*i] = s
+	}
+	var events []LevelEvent
+	m := newLevelMeter(2, PERIOD, func(e LevelEvent) {
+		events = append(events, e)
+	})
+	// In uneven chunks, to cross period boundaries mid-call
+	for i := 0; i < len(mono); i += 333 {
+		end := i + 333
+		if end > len(mono) {
+			end = len(mono)
+		}
+		m.add(pcm[2*i:2*end], end-i)
+	}
+	if len(events) != 10 {
+		t.Fatalf("Expected 10 events, got %d", len(events))
+	}
+	for i, e := range events {
+		if e.Samples != PERIOD {
+			t.Errorf("Event %d covers %d samples, expected %d", i, e.Samples, PERIOD)
+		}
+		if math.Abs(e.RMS[0]-math.Sqrt2/2) > 0.01 || e.Peak[0] < 0.99 {
+			t.Errorf("Event %d: unexpected sine level RMS %f, peak %f", i, e.RMS[0], e.Peak[0])
+		}
+		if e.RMS[1] != 0 || e.Peak[1] != 0 {
+			t.Errorf("Event %d: expected silence, got RMS %f, peak %f", i, e.RMS[1], e.Peak[1])
+		}
+	}
+	// A nil meter is a no-op
+	var none *levelMeter
+	none.add(pcm, len(mono))
+}
@@ -0,0 +1,180 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CodecRecord describes an encoder or decoder tracked by the lifecycle debug
+// mode.
+type CodecRecord struct {
+	// "encoder" or "decoder"
+	Kind string
+	// Stack trace of the goroutine which created the codec
+	Stack    []byte
+	Created  time.Time
+	LastUsed time.Time
+}
+
+type lifecycleRecord struct {
+	kind    string
+	stack   []byte
+	created time.Time
+	// Unix nanoseconds, accessed atomically
+	lastUsed int64
+	closed   int32
+}
+
+var lifecycle struct {
+	sync.Mutex
+	leak    func(CodecRecord)
+	records map[*lifecycleRecord]struct{}
+}
+
+// EnableLifecycleDebug turns on tracking of all encoders and decoders created
+// through their New* constructors from now on. The leak callback is invoked
+// for every tracked codec which gets garbage collected without having been
+// closed, with the stack trace of its creation, to help find codec leaks in
+// long running servers. It is called from the finalizer goroutine and should
+// return quickly.
+//
+// Tracking costs a stack trace per codec creation and a timestamp per
+// encode/decode call. Pass nil to turn it off again; codecs which are already
+// tracked stay tracked.
+func EnableLifecycleDebug(leak func(CodecRecord)) {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+	lifecycle.leak = leak
+	if leak == nil {
+		lifecycle.records = nil
+	} else if lifecycle.records == nil {
+		lifecycle.records = map[*lifecycleRecord]struct{}{}
+	}
+}
+
+// IdleCodecs returns all live tracked codecs which have not been used for at
+// least the given duration.
+func IdleCodecs(idle time.Duration) []CodecRecord {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+	cutoff := time.Now().Add(-idle).UnixNano()
+	var idles []CodecRecord
+	for r := range lifecycle.records {
+		if atomic.LoadInt32(&r.closed) == 0 && atomic.LoadInt64(&r.lastUsed) <= cutoff {
+			idles = append(idles, r.record())
+		}
+	}
+	return idles
+}
+
+// trackCodec returns a new record if lifecycle debugging is on, nil
+// otherwise.
+func trackCodec(kind string) *lifecycleRecord {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+	if lifecycle.leak == nil {
+		return nil
+	}
+	now := time.Now()
+	r := &lifecycleRecord{
+		kind:     kind,
+		stack:    debug.Stack(),
+		created:  now,
+		lastUsed: now.UnixNano(),
+	}
+	lifecycle.records[r] = struct{}{}
+	return r
+}
+
+func (r *lifecycleRecord) record() CodecRecord {
+	return CodecRecord{
+		Kind:     r.kind,
+		Stack:    r.stack,
+		Created:  r.created,
+		LastUsed: time.Unix(0, atomic.LoadInt64(&r.lastUsed)),
+	}
+}
+
+// touch marks the codec as used. Safe to call on a nil record.
+func (r *lifecycleRecord) touch() {
+	if r == nil {
+		return
+	}
+	atomic.StoreInt64(&r.lastUsed, time.Now().UnixNano())
+}
+
+// close marks the codec as properly closed. Safe to call on a nil record.
+func (r *lifecycleRecord) close() {
+	if r == nil {
+		return
+	}
+	atomic.StoreInt32(&r.closed, 1)
+}
+
+// reopen undoes close, for codecs handed out again by a pool. Safe to call on
+// a nil record.
+func (r *lifecycleRecord) reopen() {
+	if r == nil {
+		return
+	}
+	atomic.StoreInt32(&r.closed, 0)
+	r.touch()
+}
+
+func (r *lifecycleRecord) finalize() {
+	lifecycle.Lock()
+	delete(lifecycle.records, r)
+	leak := lifecycle.leak
+	lifecycle.Unlock()
+	if leak != nil && atomic.LoadInt32(&r.closed) == 0 {
+		leak(r.record())
+	}
+}
+
+// track starts lifecycle tracking for the encoder if debugging is on, and
+// sets up freeing it if it lives on the C heap and is never closed. Either
+// needs a finalizer, so the encoder must have been allocated on its own, not
+// as part of a larger struct.
+//
+// Methods which pass only enc.p to C must keep the encoder alive with
+// runtime.KeepAlive until the call returns: the finalizer could otherwise free
+// the state while libopus is using it.
+func (enc *Encoder) track() {
+	enc.debug = trackCodec("encoder")
+	if enc.debug != nil || enc.mem == nil {
+		runtime.SetFinalizer(enc, (*Encoder).finalize)
+	}
+}
+
+func (enc *Encoder) finalize() {
+	if enc.debug != nil {
+		enc.debug.finalize()
+	}
+	if enc.p != nil && enc.mem == nil {
+		enc.release()
+	}
+}
+
+// track is the decoder counterpart of Encoder.track.
+func (dec *Decoder) track() {
+	dec.debug = trackCodec("decoder")
+	if dec.debug != nil || dec.mem == nil {
+		runtime.SetFinalizer(dec, (*Decoder).finalize)
+	}
+}
+
+func (dec *Decoder) finalize() {
+	if dec.debug != nil {
+		dec.debug.finalize()
+	}
+	if dec.p != nil && dec.mem == nil {
+		dec.release()
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func leakEncoder(t *testing.T) {
+	if _, err := NewEncoder(48000, 1, AppVoIP); err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+}
+
+func TestLifecycleDebug(t *testing.T) {
+	leaks := make(chan CodecRecord, 10)
+	EnableLifecycleDebug(func(r CodecRecord) { leaks <- r })
+	defer EnableLifecycleDebug(nil)
+
+	dec, err := NewDecoder(48000, 1)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if idle := IdleCodecs(0); len(idle) != 1 || idle[0].Kind != "decoder" {
+		t.Errorf("Expected one idle decoder, got %v", idle)
+	}
+	if idle := IdleCodecs(time.Hour); len(idle) != 0 {
+		t.Errorf("Expected no codecs idle for an hour, got %v", idle)
+	}
+	if err := dec.Close(); err != nil {
+		t.Fatalf("Error closing decoder: %v", err)
+	}
+
+	leakEncoder(t)
+	deadline := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case r := <-leaks:
+			if r.Kind != "encoder" {
+				t.Errorf("Unexpected leak report for %s", r.Kind)
+			}
+			if !strings.Contains(string(r.Stack), "leakEncoder") {
+				t.Errorf("Leak report doesn't contain creation stack:\n%s", r.Stack)
+			}
+			return
+		case <-deadline:
+			t.Fatal("Leaked encoder was never reported")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func leakCHeapCodecs(t *testing.T) {
+	if _, err := NewEncoderCHeap(48000, 1, AppVoIP); err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if _, err := NewDecoderCHeap(48000, 1); err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+}
+
+func TestLifecycleCHeapFinalizer(t *testing.T) {
+	leaks := make(chan CodecRecord, 10)
+	EnableLifecycleDebug(func(r CodecRecord) { leaks <- r })
+	defer EnableLifecycleDebug(nil)
+
+	// The finalizer reports the leaks, and frees the codecs
+	leakCHeapCodecs(t)
+	deadline := time.After(5 * time.Second)
+	kinds := map[string]bool{}
+	for len(kinds) < 2 {
+		runtime.GC()
+		select {
+		case r := <-leaks:
+			kinds[r.Kind] = true
+		case <-deadline:
+			t.Fatalf("Leaked codecs were not all finalized, got %v", kinds)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// LowLatencyEncoder is an encoder configured for the lowest possible delay,
+// e.g. for game voice chat or live monitoring: restricted low-delay mode
+// (CELT only, no lookahead for mode switching) with 2.5 or 5 ms frames. It
+// only accepts frames of exactly the configured duration, so a pipeline that
+// hands it bigger chunks fails loudly instead of quietly adding latency.
+type LowLatencyEncoder struct {
+	enc   *Encoder
+	frame time.Duration
+}
+
+// NewLowLatencyEncoder creates an encoder in restricted low-delay mode for
+// frames of the given duration, which must be 2.5 or 5 ms.
+func NewLowLatencyEncoder(sample_rate int, channels int, frame time.Duration) (*LowLatencyEncoder, error) {
+	if frame != 2500*time.Microsecond && frame != 5*time.Millisecond {
+		return nil, fmt.Errorf("opus: low latency frames must be 2.5ms or 5ms, not %v", frame)
+	}
+	enc, err := NewEncoder(sample_rate, channels, AppRestrictedLowdelay)
+	if err != nil {
+		return nil, err
+	}
+	// FEC and DTX are SILK features, which restricted low-delay mode doesn't
+	// use; make that explicit.
+	if err := enc.SetInBandFEC(false); err != nil {
+		return nil, err
+	}
+	if err := enc.SetDTX(false); err != nil {
+		return nil, err
+	}
+	return &LowLatencyEncoder{enc: enc, frame: frame}, nil
+}
+
+// Encoder returns the underlying encoder, to change settings like the
+// bitrate. Don't use it to encode directly.
+func (e *LowLatencyEncoder) Encoder() *Encoder {
+	return e.enc
+}
+
+// FrameDuration returns the configured frame duration.
+func (e *LowLatencyEncoder) FrameDuration() time.Duration {
+	return e.frame
+}
+
+// Delay returns the algorithmic delay of the encoder and decoder combined:
+// the frame duration plus the encoder lookahead. Buffering in the capture,
+// network and playback path comes on top of this.
+func (e *LowLatencyEncoder) Delay() (time.Duration, error) {
+	lookahead, err := e.enc.Lookahead()
+	if err != nil {
+		return 0, err
+	}
+	return e.frame + SamplesDuration(e.enc.sample_rate, lookahead), nil
+}
+
+// Encode encodes a single frame of exactly the configured duration.
+func (e *LowLatencyEncoder) Encode(pcm []int16, data []byte) (int, error) {
+	return e.enc.EncodeDuration(pcm, e.frame, data)
+}
+
+// EncodeFloat32 is the same as Encode, but for float32 audio.
+func (e *LowLatencyEncoder) EncodeFloat32(pcm []float32, data []byte) (int, error) {
+	return e.enc.EncodeFloat32Duration(pcm, e.frame, data)
+}
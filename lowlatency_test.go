@@ -0,0 +1,51 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLowLatencyEncoder(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const G4 = 391.995
+	enc, err := NewLowLatencyEncoder(SAMPLE_RATE, 1, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	delay, err := enc.Delay()
+	if err != nil {
+		t.Fatalf("Error getting delay: %v", err)
+	}
+	// 5 ms frame, 2.5 ms lookahead
+	if delay != 7500*time.Microsecond {
+		t.Errorf("Expected 7.5ms delay, got %v", delay)
+	}
+	pcm := make([]int16, SAMPLE_RATE*5/1000)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	info, err := ParsePacket(data[:n])
+	if err != nil {
+		t.Fatalf("Couldn't parse packet: %v", err)
+	}
+	if info.Mode != ModeCELT {
+		t.Errorf("Expected CELT mode, got %v", info.Mode)
+	}
+	// 10 ms is a valid Opus frame, but not for this encoder
+	if _, err := enc.Encode(make([]int16, 2*len(pcm)), data); err == nil {
+		t.Errorf("Expected error for 10ms frame")
+	}
+}
+
+func TestLowLatencyEncoderInvalidFrame(t *testing.T) {
+	if _, err := NewLowLatencyEncoder(48000, 1, 20*time.Millisecond); err == nil {
+		t.Errorf("Expected error for 20ms frames")
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"math"
+)
+
+// Number of filter taps per unit of decimation factor. Long enough for a
+// steep cutoff, short enough to be cheap at 6×.
+const decimatorTapsPerFactor = 16
+
+// decimator downsamples interleaved audio by an integer factor, with a
+// windowed sinc low-pass filter to prevent aliasing. State is kept between
+// calls, so consecutive buffers are filtered as one continuous signal.
+type decimator struct {
+	factor   int
+	channels int
+	taps     []float64
+	// Last len(taps)-1 input frames of the previous call, followed by the
+	// current input
+	buf []float64
+}
+
+func newDecimator(factor, channels int) *decimator {
+	d := &decimator{factor: factor, channels: channels}
+	if factor == 1 {
+		return d
+	}
+	n := decimatorTapsPerFactor*factor + 1
+	d.taps = make([]float64, n)
+	// Cut off a bit below the new Nyquist frequency
+	cutoff := 0.9 / float64(factor) / 2
+	sum := 0.0
+	for i := range d.taps {
+		x := float64(i - (n-1)/2)
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		// Blackman window
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1)) +
+			0.08*math.Cos(4*math.Pi*float64(i)/float64(n-1))
+		d.taps[i] = sinc * w
+		sum += d.taps[i]
+	}
+	for i := range d.taps {
+		d.taps[i] /= sum
+	}
+	d.buf = make([]float64, (n-1)*channels)
+	return d
+}
+
+// process decimates in (interleaved, a whole number of factor frames) into
+// out, which must have room for len(in)/factor samples.
+func (d *decimator) process(in []float64, out []float64) {
+	ch := d.channels
+	if d.factor == 1 {
+		copy(out, in)
+		return
+	}
+	hist := len(d.taps) - 1
+	d.buf = append(d.buf[:hist*ch], in...)
+	frames := len(in) / ch / d.factor
+	for j := 0; j < frames; j++ {
+		// Newest input frame for this output frame
+		newest := hist + j*d.factor
+		for c := 0; c < ch; c++ {
+			acc := 0.0
+			for k, h := range d.taps {
+				acc += h * d.buf[(newest-k)*ch+c]
+			}
+			out[j*ch+c] = acc
+		}
+	}
+	copy(d.buf, d.buf[len(d.buf)-hist*ch:])
+}
+
+// MultiRateDecoder decodes a stream once and delivers it at several sample
+// rates at the same time, e.g. 48 kHz for playback and 16 kHz for speech
+// recognition, instead of running a decoder per rate. Audio is decoded at
+// 48 kHz and downsampled internally. The low-pass filter delays the
+// downsampled outputs slightly compared to the 48 kHz output, by at most 1 ms
+// (for 8 kHz).
+type MultiRateDecoder struct {
+	dec        *Decoder
+	channels   int
+	rates      []int
+	decimators []*decimator
+	pcm        []float32
+	in         []float64
+	out        []float64
+}
+
+// NewMultiRateDecoder creates a decoder delivering audio at each of the given
+// sample rates, which must be valid Opus sample rates.
+func NewMultiRateDecoder(channels int, sample_rates ...int) (*MultiRateDecoder, error) {
+	if len(sample_rates) == 0 {
+		return nil, fmt.Errorf("opus: no sample rates supplied")
+	}
+	dec, err := NewDecoder(GranuleSampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	d := &MultiRateDecoder{
+		dec:      dec,
+		channels: channels,
+		rates:    append([]int(nil), sample_rates...),
+		pcm:      make([]float32, maxPacketSamples48k*channels),
+	}
+	for _, rate := range sample_rates {
+		if err := validateSampleRate(rate); err != nil {
+			return nil, err
+		}
+		d.decimators = append(d.decimators, newDecimator(GranuleSampleRate/rate, channels))
+	}
+	return d, nil
+}
+
+// SampleRates returns the output sample rates, in the order of the output
+// buffers.
+func (d *MultiRateDecoder) SampleRates() []int {
+	return append([]int(nil), d.rates...)
+}
+
+// decode decodes a packet, or conceals a lost one if data is empty, at
+// 48 kHz. Returns the number of samples per channel.
+func (d *MultiRateDecoder) decode(data []byte) (int, error) {
+	if len(data) == 0 {
+		n, err := d.dec.LastPacketDuration()
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			n = FrameSamples(GranuleSampleRate, defaultLostFrame)
+		}
+		frame := d.pcm[: n*d.channels : n*d.channels]
+		if err := d.dec.DecodePLCFloat32(frame); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	return d.dec.DecodeFloat32(data, d.pcm)
+}
+
+// check verifies that each output buffer has room for the packet.
+func (d *MultiRateDecoder) check(data []byte, caps []int) error {
+	if len(caps) != len(d.rates) {
+		return fmt.Errorf("opus: expected %d output buffers, got %d", len(d.rates), len(caps))
+	}
+	n := FrameSamples(GranuleSampleRate, defaultLostFrame)
+	if len(data) > 0 {
+		var err error
+		if n, err = packetSamples48k(data); err != nil {
+			return err
+		}
+	} else if last, err := d.dec.LastPacketDuration(); err == nil && last > 0 {
+		n = last
+	}
+	for i, c := range caps {
+		if need := n / d.decimators[i].factor * d.channels; c < need {
+			return fmt.Errorf("opus: target buffer for %d Hz too small: need %d samples, have room for %d", d.rates[i], need, c)
+		}
+	}
+	return nil
+}
+
+// run decodes a packet and downsamples it for every rate, calling emit with
+// the output for each.
+func (d *MultiRateDecoder) run(data []byte, counts []int, emit func(i int, out []float64)) error {
+	n, err := d.decode(data)
+	if err != nil {
+		return err
+	}
+	d.in = d.in[:0]
+	for _, s := range d.pcm[:n*d.channels] {
+		d.in = append(d.in, float64(s))
+	}
+	for i, dm := range d.decimators {
+		m := n / dm.factor * d.channels
+		if cap(d.out) < m {
+			d.out = make([]float64, m)
+		}
+		out := d.out[:m]
+		dm.process(d.in, out)
+		emit(i, out)
+		counts[i] = m / d.channels
+	}
+	return nil
+}
+
+// Decode decodes a packet into one buffer per output sample rate, in the
+// order given to NewMultiRateDecoder. An empty packet is treated as lost and
+// concealed with PLC. Returns the number of samples per channel written to
+// each buffer.
+func (d *MultiRateDecoder) Decode(data []byte, pcm [][]int16) ([]int, error) {
+	caps := make([]int, len(pcm))
+	for i, p := range pcm {
+		caps[i] = cap(p)
+	}
+	if err := d.check(data, caps); err != nil {
+		return nil, err
+	}
+	counts := make([]int, len(pcm))
+	err := d.run(data, counts, func(i int, out []float64) {
+		p := pcm[i][:len(out)]
+		for j, s := range out {
+			s = math.Round(s * 32768)
+			if s > math.MaxInt16 {
+				s = math.MaxInt16
+			} else if s < math.MinInt16 {
+				s = math.MinInt16
+			}
+			p[j] = int16(s)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// DecodeFloat32 is the same as Decode, but for float32 audio.
+func (d *MultiRateDecoder) DecodeFloat32(data []byte, pcm [][]float32) ([]int, error) {
+	caps := make([]int, len(pcm))
+	for i, p := range pcm {
+		caps[i] = cap(p)
+	}
+	if err := d.check(data, caps); err != nil {
+		return nil, err
+	}
+	counts := make([]int, len(pcm))
+	err := d.run(data, counts, func(i int, out []float64) {
+		p := pcm[i][:len(out)]
+		for j, s := range out {
+			p[j] = float32(s)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
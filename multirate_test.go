@@ -0,0 +1,87 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecimator(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	for _, factor := range []int{2, 3, 6} {
+		d := newDecimator(factor, 1)
+		// A tone well below the new Nyquist frequency passes, one above it is
+		// filtered out.
+		for _, tc := range []struct {
+			freq float64
+			pass bool
+		}{{440, true}, {float64(SAMPLE_RATE/factor) * 0.75, false}} {
+			in := make([]float64, SAMPLE_RATE/10)
+			for i := range in {
+				in[i] = math.Sin(2 * math.Pi * tc.freq * float64(i) / SAMPLE_RATE)
+			}
+			out := make([]float64, len(in)/factor)
+			d.process(in, out)
+			peak := 0.0
+			// Skip the filter's warm up
+			for _, s := range out[len(out)/2:] {
+				peak = math.Max(peak, math.Abs(s))
+			}
+			if tc.pass && peak < 0.95 {
+				t.Errorf("Factor %d: %v Hz attenuated to %v", factor, tc.freq, peak)
+			}
+			if !tc.pass && peak > 0.01 {
+				t.Errorf("Factor %d: %v Hz not filtered: %v", factor, tc.freq, peak)
+			}
+		}
+	}
+}
+
+func TestMultiRateDecoder(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	data = data[:n]
+
+	dec, err := NewMultiRateDecoder(1, 48000, 16000, 8000)
+	if err != nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	out := [][]int16{
+		make([]int16, FRAME_SIZE),
+		make([]int16, FRAME_SIZE/3),
+		make([]int16, FRAME_SIZE/6),
+	}
+	for _, packet := range [][]byte{data, nil} {
+		counts, err := dec.Decode(packet, out)
+		if err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		for i, rate := range dec.SampleRates() {
+			if want := rate * 20 / 1000; counts[i] != want {
+				t.Errorf("Expected %d samples at %d Hz, got %d", want, rate, counts[i])
+			}
+		}
+	}
+	if _, err := dec.Decode(data, [][]int16{out[0]}); err == nil {
+		t.Errorf("Expected error for missing output buffer")
+	}
+	small := [][]int16{out[0], out[1], out[2][:0:10]}
+	if _, err := dec.Decode(data, small); err == nil {
+		t.Errorf("Expected error for small output buffer")
+	}
+}
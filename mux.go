@@ -0,0 +1,163 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"sync"
+)
+
+var errMuxClosed = fmt.Errorf("opus: encoder mux closed")
+
+type muxConn struct {
+	enc  *Encoder
+	jobs []func()
+	// Whether the connection is in the ready queue or being worked on
+	scheduled bool
+}
+
+// EncoderMux runs the encoders of many connections on a fixed pool of worker
+// goroutines, instead of a goroutine (and thread, while in cgo) per call.
+// Connections are served round robin, one frame at a time, so a connection
+// with a backlog can't starve the others. Calls for the same connection are
+// run in order, never concurrently.
+//
+// EncoderMux is safe for concurrent use.
+type EncoderMux struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	conns map[string]*muxConn
+	// Connections with pending jobs, in the order they get a worker
+	ready  []*muxConn
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewEncoderMux starts an encoder mux with the given number of workers.
+func NewEncoderMux(workers int) (*EncoderMux, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("opus: invalid number of workers: %d", workers)
+	}
+	m := &EncoderMux{conns: map[string]*muxConn{}}
+	m.cond = sync.NewCond(&m.mu)
+	m.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go m.work()
+	}
+	return m, nil
+}
+
+func (m *EncoderMux) work() {
+	defer m.wg.Done()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		for len(m.ready) == 0 && !m.closed {
+			m.cond.Wait()
+		}
+		if len(m.ready) == 0 {
+			return
+		}
+		c := m.ready[0]
+		m.ready = m.ready[1:]
+		job := c.jobs[0]
+		c.jobs = c.jobs[1:]
+		m.mu.Unlock()
+		job()
+		m.mu.Lock()
+		if len(c.jobs) > 0 {
+			// Back of the line
+			m.ready = append(m.ready, c)
+			m.cond.Signal()
+		} else {
+			c.scheduled = false
+		}
+	}
+}
+
+// AddConn creates an encoder for a new connection.
+func (m *EncoderMux) AddConn(connID string, sample_rate int, channels int, application Application) error {
+	enc, err := NewEncoder(sample_rate, channels, application)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errMuxClosed
+	}
+	if _, ok := m.conns[connID]; ok {
+		return fmt.Errorf("opus: connection %q already exists", connID)
+	}
+	m.conns[connID] = &muxConn{enc: enc}
+	return nil
+}
+
+// RemoveConn forgets a connection. Calls already queued for it still
+// complete.
+func (m *EncoderMux) RemoveConn(connID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.conns, connID)
+}
+
+// run queues f for the connection and waits for a worker to run it.
+func (m *EncoderMux) run(connID string, f func(enc *Encoder)) error {
+	done := make(chan struct{})
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return errMuxClosed
+	}
+	c, ok := m.conns[connID]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("opus: unknown connection %q", connID)
+	}
+	c.jobs = append(c.jobs, func() {
+		f(c.enc)
+		close(done)
+	})
+	if !c.scheduled {
+		c.scheduled = true
+		m.ready = append(m.ready, c)
+		m.cond.Signal()
+	}
+	m.mu.Unlock()
+	<-done
+	return nil
+}
+
+// EncodeFor encodes a frame with the encoder of the connection, see
+// Encoder.Encode. Blocks until a worker has done so.
+func (m *EncoderMux) EncodeFor(connID string, pcm []int16, data []byte) (n int, err error) {
+	if rerr := m.run(connID, func(enc *Encoder) { n, err = enc.Encode(pcm, data) }); rerr != nil {
+		return 0, rerr
+	}
+	return
+}
+
+// EncodeFloat32For is the same as EncodeFor, but for float32 audio.
+func (m *EncoderMux) EncodeFloat32For(connID string, pcm []float32, data []byte) (n int, err error) {
+	if rerr := m.run(connID, func(enc *Encoder) { n, err = enc.EncodeFloat32(pcm, data) }); rerr != nil {
+		return 0, rerr
+	}
+	return
+}
+
+// Do runs a function with the encoder of the connection on a worker, in order
+// with its encode calls, e.g. to change its bitrate.
+func (m *EncoderMux) Do(connID string, f func(enc *Encoder)) error {
+	return m.run(connID, f)
+}
+
+// Close stops the workers once all queued calls are done. Later calls fail.
+func (m *EncoderMux) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.cond.Broadcast()
+	m.mu.Unlock()
+	m.wg.Wait()
+}
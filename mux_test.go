@@ -0,0 +1,82 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEncoderMux(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const CONNS = 50
+	const NUMBER_OF_FRAMES = 5
+	m, err := NewEncoderMux(4)
+	if err != nil {
+		t.Fatalf("Error creating encoder mux: %v", err)
+	}
+	defer m.Close()
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	var wg sync.WaitGroup
+	errs := make(chan error, CONNS)
+	for i := 0; i < CONNS; i++ {
+		id := fmt.Sprintf("conn%d", i)
+		if err := m.AddConn(id, SAMPLE_RATE, 1, AppVoIP); err != nil {
+			t.Fatalf("Error adding connection: %v", err)
+		}
+		if err := m.Do(id, func(enc *Encoder) { enc.SetBitrate(24000) }); err != nil {
+			t.Fatalf("Error configuring connection: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := make([]byte, 1000)
+			for j := 0; j < NUMBER_OF_FRAMES; j++ {
+				n, err := m.EncodeFor(id, pcm, data)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if n == 0 {
+					errs <- fmt.Errorf("%s: empty packet", id)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Error encoding: %v", err)
+	}
+	if _, err := m.EncodeFor("unknown", pcm, make([]byte, 1000)); err == nil {
+		t.Errorf("Expected error for unknown connection")
+	}
+	if err := m.AddConn("conn0", SAMPLE_RATE, 1, AppVoIP); err == nil {
+		t.Errorf("Expected error adding duplicate connection")
+	}
+	m.RemoveConn("conn0")
+	if _, err := m.EncodeFor("conn0", pcm, make([]byte, 1000)); err == nil {
+		t.Errorf("Expected error for removed connection")
+	}
+}
+
+func TestEncoderMuxClosed(t *testing.T) {
+	m, err := NewEncoderMux(1)
+	if err != nil {
+		t.Fatalf("Error creating encoder mux: %v", err)
+	}
+	if err := m.AddConn("a", 48000, 1, AppVoIP); err != nil {
+		t.Fatalf("Error adding connection: %v", err)
+	}
+	m.Close()
+	if err := m.Do("a", func(*Encoder) {}); err != errMuxClosed {
+		t.Errorf("Expected errMuxClosed, got %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// OggStreamInfo describes a logical stream in an Ogg container.
+type OggStreamInfo struct {
+	Serial uint32
+	// Codec of the stream as identified from its first packet, e.g. "opus",
+	// "vorbis" or "skeleton". "unknown" if not recognized.
+	Codec string
+}
+
+var oggCodecs = []struct {
+	magic string
+	codec string
+}{
+	{"OpusHead", "opus"},
+	{"\x01vorbis", "vorbis"},
+	{"\x7fFLAC", "flac"},
+	{"Speex   ", "speex"},
+	{"\x80theora", "theora"},
+	{"fishead\x00", "skeleton"},
+	{"\x80kate\x00\x00\x00", "kate"},
+}
+
+func oggCodec(packet []byte) string {
+	for _, c := range oggCodecs {
+		if bytes.HasPrefix(packet, []byte(c.magic)) {
+			return c.codec
+		}
+	}
+	return "unknown"
+}
+
+// oggPage is a raw Ogg page with its header fields of interest.
+type oggPage struct {
+	raw    []byte
+	serial uint32
+	flags  byte
+	// Offset of the body in raw
+	body int
+}
+
+// oggMaxPageSize is the size of the largest possible Ogg page: a full header
+// with 255 segments of 255 bytes.
+const oggMaxPageSize = oggHeaderSize + 255 + 255*255
+
+// readOggPage reads the next page, skipping anything before it that isn't a
+// page. The page checksum is not verified. r must have room to buffer a whole
+// page, see oggMaxPageSize.
+func readOggPage(r *bufio.Reader) (*oggPage, error) {
+	p, err := peekOggPage(r)
+	if err != nil {
+		return nil, err
+	}
+	p.raw = append([]byte(nil), p.raw...)
+	r.Discard(len(p.raw))
+	return p, nil
+}
+
+// peekOggPage is like readOggPage, but leaves the page in r. Its raw bytes
+// are only valid until the next read from r.
+func peekOggPage(r *bufio.Reader) (*oggPage, error) {
+	for {
+		buf, err := r.Peek(4)
+		if err != nil {
+			if err == io.EOF && len(buf) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if string(buf) == "OggS" {
+			break
+		}
+		r.Discard(1)
+	}
+	header, err := r.Peek(oggHeaderSize)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	body := oggHeaderSize + int(header[26])
+	lacing, err := r.Peek(body)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	size := body
+	for _, l := range lacing[oggHeaderSize:] {
+		size += int(l)
+	}
+	// Peek before consuming anything, so a failed read (e.g. a timeout) can
+	// be retried without losing part of the page.
+	raw, err := r.Peek(size)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return &oggPage{
+		raw:    raw,
+		serial: binary.LittleEndian.Uint32(raw[14:]),
+		flags:  raw[5],
+		body:   body,
+	}, nil
+}
+
+// valid checks the version and checksum of the page.
+func (p *oggPage) valid() bool {
+	if p.raw[4] != 0 {
+		return false
+	}
+	var header [oggHeaderSize]byte
+	copy(header[:], p.raw)
+	crc := binary.LittleEndian.Uint32(header[22:])
+	for i := 22; i < 26; i++ {
+		header[i] = 0
+	}
+	return oggCRC(oggCRC(0, header[:]), p.raw[oggHeaderSize:]) == crc
+}
+
+// granule returns the granule position of the page.
+func (p *oggPage) granule() int64 {
+	return int64(binary.LittleEndian.Uint64(p.raw[6:]))
+}
+
+// packets splits the page into packets and calls fn with each one completed
+// on it. partial is the incomplete packet left over from the previous page
+// of the stream; the one left at the end of this page is returned. The
+// packets passed to fn are only valid during the call.
+func (p *oggPage) packets(partial []byte, fn func(packet []byte) error) ([]byte, error) {
+	if p.flags&oggFlagContinued == 0 {
+		// Anything left over was never finished
+		partial = partial[:0]
+	}
+	pos := p.body
+	for _, l := range p.raw[oggHeaderSize:p.body] {
+		partial = append(partial, p.raw[pos:pos+int(l)]...)
+		pos += int(l)
+		if l == 255 {
+			continue
+		}
+		if err := fn(partial); err != nil {
+			return partial[:0], err
+		}
+		partial = partial[:0]
+	}
+	return partial, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ListOggStreams lists the logical streams multiplexed in an Ogg container,
+// e.g. Opus with Ogg Skeleton, or several audio tracks. Only the beginning of
+// stream pages at the start of r are read; to decode one of the streams
+// afterwards, seek back to the start (or reopen the input) and pass its
+// serial number to NewStreamSerial.
+func ListOggStreams(r io.Reader) ([]OggStreamInfo, error) {
+	br := bufio.NewReaderSize(r, oggMaxPageSize)
+	var infos []OggStreamInfo
+	for {
+		p, err := readOggPage(br)
+		if err == io.EOF && len(infos) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.flags&oggFlagBOS == 0 {
+			break
+		}
+		infos = append(infos, OggStreamInfo{Serial: p.serial, Codec: oggCodec(p.raw[p.body:])})
+	}
+	return infos, nil
+}
+
+// OggPacketFunc receives a packet of a logical stream which isn't being
+// decoded, e.g. Ogg Skeleton or subtitles. The packet is only valid during
+// the call.
+type OggPacketFunc func(serial uint32, packet []byte)
+
+// oggFilter passes through only the pages of one logical stream, so
+// libopusfile sees an unmultiplexed stream. Packets of the other streams can
+// be handed to a callback.
+type oggFilter struct {
+	src    io.Reader
+	r      *bufio.Reader
+	serial uint32
+	// Select the first Opus stream instead of a given serial number
+	auto bool
+	// Unread remainder of the current page
+	buf  []byte
+	seen bool
+	aux  OggPacketFunc
+	// Incomplete packets of the other streams, by serial number
+	partial map[uint32][]byte
+}
+
+func newOggFilter(r io.Reader, serial uint32) *oggFilter {
+	return &oggFilter{src: r, r: bufio.NewReaderSize(r, oggMaxPageSize), serial: serial}
+}
+
+func (f *oggFilter) selected(p *oggPage) bool {
+	if f.auto && !f.seen && p.flags&oggFlagBOS != 0 && oggCodec(p.raw[p.body:]) == "opus" {
+		f.serial = p.serial
+		f.seen = true
+	}
+	return p.serial == f.serial && (f.seen || !f.auto)
+}
+
+func (f *oggFilter) Read(b []byte) (int, error) {
+	for len(f.buf) == 0 {
+		p, err := readOggPage(f.r)
+		if err == io.EOF && !f.seen {
+			if f.auto {
+				return 0, fmt.Errorf("opus: no Opus logical stream")
+			}
+			return 0, fmt.Errorf("opus: no logical stream with serial number %d", f.serial)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if f.selected(p) {
+			f.seen = true
+			f.buf = p.raw
+		} else if f.aux != nil {
+			f.packets(p)
+		}
+	}
+	n := copy(b, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+// packets hands the packets completed on a page of another stream to the
+// callback.
+func (f *oggFilter) packets(p *oggPage) {
+	if f.partial == nil {
+		f.partial = map[uint32][]byte{}
+	}
+	f.partial[p.serial], _ = p.packets(f.partial[p.serial], func(packet []byte) error {
+		f.aux(p.serial, packet)
+		return nil
+	})
+}
+
+// Close closes the underlying reader, if it is an io.Closer.
+func (f *oggFilter) Close() error {
+	if closer, ok := f.src.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// SetReadDeadline sets the read deadline of the underlying reader, see
+// Stream.SetReadDeadline.
+func (f *oggFilter) SetReadDeadline(t time.Time) error {
+	d, ok := f.src.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return fmt.Errorf("opus: stream reader doesn't support deadlines")
+	}
+	return d.SetReadDeadline(t)
+}
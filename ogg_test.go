@@ -0,0 +1,129 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+// skeletonPage makes the beginning of stream page of an Ogg Skeleton stream.
+func skeletonPage(serial uint32) []byte {
+	body := append([]byte("fishead\x00"), make([]byte, 56)...)
+	page := make([]byte, oggHeaderSize+1, oggHeaderSize+1+len(body))
+	copy(page, "OggS")
+	page[5] = oggFlagBOS
+	binary.LittleEndian.PutUint32(page[14:], serial)
+	page[26] = 1
+	page[27] = byte(len(body))
+	page = append(page, body...)
+	fixCRC(page)
+	return page
+}
+
+// muxSpeech multiplexes the speech test file with a copy of itself under
+// another serial number and a Skeleton stream. Returns the multiplexed data
+// and the serial numbers of the original and the copy.
+func muxSpeech(t *testing.T) ([]byte, uint32, uint32) {
+	pages := oggPages(t, readSpeech(t))
+	serial := binary.LittleEndian.Uint32(pages[0][14:])
+	other := serial + 1
+	var copies [][]byte
+	for _, p := range pages {
+		c := append([]byte(nil), p...)
+		binary.LittleEndian.PutUint32(c[14:], other)
+		fixCRC(c)
+		copies = append(copies, c)
+	}
+	// Beginning of stream pages first, then the rest interleaved
+	var buf bytes.Buffer
+	buf.Write(skeletonPage(serial + 2))
+	buf.Write(pages[0])
+	buf.Write(copies[0])
+	for i := 1; i < len(pages); i++ {
+		buf.Write(copies[i])
+		buf.Write(pages[i])
+	}
+	return buf.Bytes(), serial, other
+}
+
+func TestListOggStreams(t *testing.T) {
+	data, serial, other := muxSpeech(t)
+	infos, err := ListOggStreams(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error listing streams: %v", err)
+	}
+	expected := []OggStreamInfo{
+		{Serial: serial + 2, Codec: "skeleton"},
+		{Serial: serial, Codec: "opus"},
+		{Serial: other, Codec: "opus"},
+	}
+	if len(infos) != len(expected) {
+		t.Fatalf("Expected %d streams, got %+v", len(expected), infos)
+	}
+	for i := range expected {
+		if infos[i] != expected[i] {
+			t.Errorf("Stream %d: expected %+v, got %+v", i, expected[i], infos[i])
+		}
+	}
+	if _, err := ListOggStreams(bytes.NewReader([]byte("not ogg"))); err == nil {
+		t.Errorf("Expected error for non-Ogg input")
+	}
+}
+
+func TestOggFilter(t *testing.T) {
+	data, serial, _ := muxSpeech(t)
+	filtered, err := ioutil.ReadAll(newOggFilter(bytes.NewReader(data), serial))
+	if err != nil {
+		t.Fatalf("Error filtering stream: %v", err)
+	}
+	if !bytes.Equal(filtered, readSpeech(t)) {
+		t.Errorf("Filtered stream differs from original")
+	}
+	if _, err := ioutil.ReadAll(newOggFilter(bytes.NewReader(data), serial+3)); err == nil {
+		t.Errorf("Expected error for unknown serial number")
+	}
+}
+
+func TestOggFilterAux(t *testing.T) {
+	data, serial, other := muxSpeech(t)
+	// Number of packets in the original stream
+	expected := 0
+	for _, p := range oggPages(t, readSpeech(t)) {
+		for _, l := range p[oggHeaderSize : oggHeaderSize+int(p[26])] {
+			if l < 255 {
+				expected++
+			}
+		}
+	}
+	packets := map[uint32]int{}
+	var skeleton []byte
+	f := newOggFilter(bytes.NewReader(data), 0)
+	f.auto = true
+	f.aux = func(s uint32, packet []byte) {
+		packets[s]++
+		if s == serial+2 {
+			skeleton = append([]byte(nil), packet...)
+		}
+	}
+	filtered, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Error filtering stream: %v", err)
+	}
+	if !bytes.Equal(filtered, readSpeech(t)) {
+		t.Errorf("Filtered stream differs from first Opus stream")
+	}
+	if packets[serial] != 0 {
+		t.Errorf("Expected no packets of the decoded stream, got %d", packets[serial])
+	}
+	if packets[other] != expected {
+		t.Errorf("Expected %d packets of the other Opus stream, got %d", expected, packets[other])
+	}
+	if packets[serial+2] != 1 || !bytes.HasPrefix(skeleton, []byte("fishead\x00")) {
+		t.Errorf("Expected the Skeleton header packet, got %d packets", packets[serial+2])
+	}
+}
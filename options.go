@@ -0,0 +1,140 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+// EncoderOption configures an encoder as it is created, see
+// NewEncoderWithOptions.
+type EncoderOption func(enc *Encoder) error
+
+// NewEncoderWithOptions is like NewEncoder, but also applies the given
+// options, in order. E.g. for calls:
+//
+//	enc, err := NewEncoderWithOptions(48000, 1, AppVoIP,
+//		WithBitrate(24000), WithInBandFEC(true), WithPacketLossPerc(10))
+//
+// NewEncoder itself keeps its signature, for compatibility with upstream.
+func NewEncoderWithOptions(sample_rate int, channels int, application Application, opts ...EncoderOption) (*Encoder, error) {
+	enc, err := NewEncoder(sample_rate, channels, application)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		if err := opt(enc); err != nil {
+			enc.Close()
+			return nil, err
+		}
+	}
+	return enc, nil
+}
+
+// WithBitrate sets the bitrate, see SetBitrate.
+func WithBitrate(bitrate int) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetBitrate(bitrate)
+	}
+}
+
+// WithComplexity sets the complexity, see SetComplexity.
+func WithComplexity(complexity int) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetComplexity(complexity)
+	}
+}
+
+// WithMaxBandwidth sets the maximum bandpass, see SetMaxBandwidth.
+func WithMaxBandwidth(maxBw Bandwidth) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetMaxBandwidth(maxBw)
+	}
+}
+
+// WithBandwidth forces a bandpass, see SetBandwidth.
+func WithBandwidth(bw Bandwidth) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetBandwidth(bw)
+	}
+}
+
+// WithSignal sets the signal hint, see SetSignal.
+func WithSignal(signal Signal) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetSignal(signal)
+	}
+}
+
+// WithInBandFEC sets the use of inband FEC, see SetInBandFEC.
+func WithInBandFEC(fec bool) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetInBandFEC(fec)
+	}
+}
+
+// WithPacketLossPerc sets the expected packet loss, see SetPacketLossPerc.
+func WithPacketLossPerc(lossPerc int) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetPacketLossPerc(lossPerc)
+	}
+}
+
+// WithDTX sets the use of DTX, see SetDTX.
+func WithDTX(dtx bool) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetDTX(dtx)
+	}
+}
+
+// WithVBR sets the use of a variable bitrate, see SetVBR.
+func WithVBR(vbr bool) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetVBR(vbr)
+	}
+}
+
+// WithVBRConstraint sets whether the variable bitrate is constrained, see
+// SetVBRConstraint.
+func WithVBRConstraint(constraint bool) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetVBRConstraint(constraint)
+	}
+}
+
+// WithLSBDepth sets the depth of the signal, see SetLSBDepth.
+func WithLSBDepth(depth int) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetLSBDepth(depth)
+	}
+}
+
+// WithExpertFrameDuration sets a fixed frame duration, see
+// SetExpertFrameDuration.
+func WithExpertFrameDuration(duration FrameDuration) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetExpertFrameDuration(duration)
+	}
+}
+
+// WithPredictionDisabled sets whether inter-frame prediction is disabled, see
+// SetPredictionDisabled.
+func WithPredictionDisabled(disabled bool) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetPredictionDisabled(disabled)
+	}
+}
+
+// WithPhaseInversionDisabled sets whether phase inversion is disabled, see
+// SetPhaseInversionDisabled.
+func WithPhaseInversionDisabled(disabled bool) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.SetPhaseInversionDisabled(disabled)
+	}
+}
+
+// WithPreset applies all settings of a preset, see ApplyPreset. Options after
+// it override the preset.
+func WithPreset(p Preset) EncoderOption {
+	return func(enc *Encoder) error {
+		return enc.ApplyPreset(p)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import "testing"
+
+func TestNewEncoderWithOptions(t *testing.T) {
+	enc, err := NewEncoderWithOptions(48000, 1, AppVoIP,
+		WithBitrate(24000),
+		WithComplexity(6),
+		WithInBandFEC(true),
+		WithPacketLossPerc(10),
+		WithDTX(true),
+		WithVBR(false),
+		WithSignal(SignalVoice),
+		WithMaxBandwidth(Wideband),
+	)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	snap, err := enc.Snapshot()
+	if err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	expected := EncoderConfig{
+		Application:    AppVoIP,
+		Bitrate:        24000,
+		Complexity:     6,
+		MaxBandwidth:   Wideband,
+		InBandFEC:      true,
+		PacketLossPerc: 10,
+		DTX:            true,
+		CBR:            true,
+		Signal:         SignalVoice,
+	}
+	if snap.EncoderConfig != expected {
+		t.Errorf("Expected config %+v, got %+v", expected, snap.EncoderConfig)
+	}
+	vbr, err := enc.VBR()
+	if err != nil {
+		t.Fatalf("Error getting vbr: %v", err)
+	}
+	if vbr {
+		t.Errorf("Expected VBR to be disabled")
+	}
+	signal, err := enc.Signal()
+	if err != nil {
+		t.Fatalf("Error getting signal: %v", err)
+	}
+	if signal != SignalVoice {
+		t.Errorf("Expected signal %d, got %d", SignalVoice, signal)
+	}
+}
+
+func TestNewEncoderWithOptionsPreset(t *testing.T) {
+	preset, err := LookupPreset("mobile-3g")
+	if err != nil {
+		t.Fatalf("Error looking up preset: %v", err)
+	}
+	// Later options override the preset
+	enc, err := NewEncoderWithOptions(48000, 1, AppVoIP, WithPreset(preset), WithComplexity(2))
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	cpx, err := enc.Complexity()
+	if err != nil {
+		t.Fatalf("Error getting complexity: %v", err)
+	}
+	if cpx != 2 {
+		t.Errorf("Expected complexity 2, got %d", cpx)
+	}
+	fec, err := enc.InBandFEC()
+	if err != nil {
+		t.Fatalf("Error getting fec: %v", err)
+	}
+	if !fec {
+		t.Errorf("Expected FEC from the preset")
+	}
+}
+
+func TestNewEncoderWithOptionsInvalid(t *testing.T) {
+	enc, err := NewEncoderWithOptions(48000, 1, AppVoIP, WithComplexity(11))
+	if err == nil || enc != nil {
+		t.Errorf("Expected error for an invalid option")
+	}
+}
+
+func TestNewEncoderWithOptionsInvalidCloses(t *testing.T) {
+	EnableLifecycleDebug(func(r CodecRecord) {})
+	defer EnableLifecycleDebug(nil)
+	if _, err := NewEncoderWithOptions(48000, 1, AppVoIP, WithComplexity(11)); err == nil {
+		t.Fatalf("Expected error for an invalid option")
+	}
+	// The encoder created before the failing option is closed, not leaked
+	if live := IdleCodecs(0); len(live) != 0 {
+		t.Errorf("Expected no live codecs, got %v", live)
+	}
+}
@@ -11,6 +11,10 @@ package opus
 */
 import "C"
 
+import (
+	"time"
+)
+
 type Application int
 
 const (
@@ -31,6 +35,37 @@ const (
 	maxEncodedFrameSize = 10000
 )
 
+const (
+	// RFC 6716: no single Opus frame is larger than 1275 bytes, and a frame is
+	// at most 20 ms long.
+	maxFrameBytes    = 1275
+	maxFrameDuration = 20 * time.Millisecond
+	maxPacketFrames  = 6
+
+	// RecommendedBufferSize is large enough to hold any packet libopus can
+	// produce, up to 120 ms, at any bitrate and channel count. Use
+	// MaxPacketSize to size buffers more tightly.
+	RecommendedBufferSize = 2 + 2*(maxPacketFrames-1) + maxPacketFrames*maxFrameBytes
+)
+
+// MaxPacketSize returns the size of the largest packet the encoder can
+// produce for frames of the given duration, i.e. the data buffer size at
+// which Encode will never fail for lack of space. The limit is the same for
+// mono and stereo: Opus codes all channels within the same frame budget.
+//
+// Frames longer than 20 ms are coded as several frames in one packet, each
+// with its own length prefix.
+func MaxPacketSize(channels int, frameDuration time.Duration) int {
+	frames := int((frameDuration + maxFrameDuration - 1) / maxFrameDuration)
+	if frames <= 1 {
+		// TOC byte + frame
+		return 1 + maxFrameBytes
+	}
+	// TOC byte, frame count byte, up to 2 length bytes for all but the last
+	// frame
+	return 2 + 2*(frames-1) + frames*maxFrameBytes
+}
+
 func Version() string {
 	return C.GoString(C.opus_get_version_string())
 }
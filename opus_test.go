@@ -7,6 +7,7 @@ package opus
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestVersion(t *testing.T) {
@@ -15,6 +16,44 @@ func TestVersion(t *testing.T) {
 	}
 }
 
+func TestMaxPacketSize(t *testing.T) {
+	cases := []struct {
+		d    time.Duration
+		size int
+	}{
+		{2500 * time.Microsecond, 1276},
+		{20 * time.Millisecond, 1276},
+		{40 * time.Millisecond, 2 + 2 + 2*1275},
+		{60 * time.Millisecond, 2 + 4 + 3*1275},
+		{120 * time.Millisecond, RecommendedBufferSize},
+	}
+	for _, c := range cases {
+		for _, channels := range []int{1, 2} {
+			if size := MaxPacketSize(channels, c.d); size != c.size {
+				t.Errorf("MaxPacketSize(%d, %v) = %d, expected %d", channels, c.d, size, c.size)
+			}
+		}
+	}
+}
+
+func TestMaxPacketSizeEncode(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 60 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.SetBitrateToMax(); err != nil {
+		t.Fatalf("Error setting max bitrate: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*2)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, MaxPacketSize(2, 60*time.Millisecond))
+	if _, err := enc.Encode(pcm, data); err != nil {
+		t.Errorf("Couldn't encode data into buffer of MaxPacketSize: %v", err)
+	}
+}
+
 func TestOpusErrstr(t *testing.T) {
 	// I scooped this -1 up from opus_defines.h, it's OPUS_BAD_ARG. Not pretty,
 	// but it's better than not testing at all. Again, accessing #defines from
@@ -91,6 +130,45 @@ func TestCodecFloat32(t *testing.T) {
 	}
 }
 
+func TestCodecFinalRange(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE_MS = 20
+	const FRAME_SIZE = SAMPLE_RATE * FRAME_SIZE_MS / 1000
+	pcm := make([]int16, FRAME_SIZE*2*5)
+	addSine(pcm, SAMPLE_RATE, G4)
+	enc, err := NewEncoder(SAMPLE_RATE, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	data := make([]byte, 1000)
+	out := make([]int16, FRAME_SIZE*2)
+	for i := 0; i < 5; i++ {
+		n, err := enc.Encode(pcm[i*FRAME_SIZE*2:(i+1)*FRAME_SIZE*2], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		if _, err := dec.Decode(data[:n], out); err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		encRange, err := enc.FinalRange()
+		if err != nil {
+			t.Fatalf("Error getting encoder final range: %v", err)
+		}
+		decRange, err := dec.FinalRange()
+		if err != nil {
+			t.Fatalf("Error getting decoder final range: %v", err)
+		}
+		if encRange != decRange {
+			t.Errorf("Final range mismatch for packet %d: encoder %#x, decoder %#x", i, encRange, decRange)
+		}
+	}
+}
+
 func TestCodecFEC(t *testing.T) {
 	// Create bogus input sound
 	const G4 = 391.995
@@ -0,0 +1,154 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+// Parsing of the table-of-contents (TOC) header of Opus packets, as described
+// in RFC 6716, section 3.1. Done in Go because it's trivial, and so code
+// which only inspects packets doesn't need a decoder.
+
+var errInvalidPacket = fmt.Errorf("opus: invalid packet")
+
+// No packet is longer than 120 ms
+const maxPacketSamples48k = 5760
+
+// tocFrameSize48k returns the duration of each frame in the packet, in
+// samples at 48 kHz.
+func tocFrameSize48k(toc byte) int {
+	config := toc >> 3
+	switch {
+	case config < 12:
+		// SILK-only: 10, 20, 40, 60 ms
+		return [...]int{480, 960, 1920, 2880}[config%4]
+	case config < 16:
+		// Hybrid: 10, 20 ms
+		return [...]int{480, 960}[config%2]
+	default:
+		// CELT-only: 2.5, 5, 10, 20 ms
+		return [...]int{120, 240, 480, 960}[config%4]
+	}
+}
+
+// packetFrames returns the number of frames in the packet.
+func packetFrames(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, errInvalidPacket
+	}
+	switch data[0] & 0x3 {
+	case 0:
+		return 1, nil
+	case 1, 2:
+		return 2, nil
+	default:
+		if len(data) < 2 {
+			return 0, errInvalidPacket
+		}
+		n := int(data[1] & 0x3f)
+		if n == 0 {
+			return 0, errInvalidPacket
+		}
+		return n, nil
+	}
+}
+
+// packetSamples48k returns the duration of the packet in samples (per
+// channel) at 48 kHz.
+func packetSamples48k(data []byte) (int, error) {
+	frames, err := packetFrames(data)
+	if err != nil {
+		return 0, err
+	}
+	samples := frames * tocFrameSize48k(data[0])
+	if samples > maxPacketSamples48k {
+		return 0, errInvalidPacket
+	}
+	return samples, nil
+}
+
+// Mode is the coding mode of an Opus frame.
+type Mode int
+
+const (
+	// Linear prediction, for speech at low bitrates
+	ModeSILK Mode = iota
+	// SILK for the low band, CELT for the high band
+	ModeHybrid
+	// MDCT, for music and low delay
+	ModeCELT
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeSILK:
+		return "SILK"
+	case ModeHybrid:
+		return "hybrid"
+	case ModeCELT:
+		return "CELT"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// MarshalText encodes the mode by name, e.g. "SILK".
+func (m Mode) MarshalText() ([]byte, error) {
+	if m < ModeSILK || m > ModeCELT {
+		return nil, fmt.Errorf("opus: unknown mode %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+// PacketInfo describes an Opus packet, as read from its TOC byte. All frames
+// in a packet share the same mode, bandwidth and frame duration.
+type PacketInfo struct {
+	Mode      Mode
+	Bandwidth Bandwidth
+	Stereo    bool
+	// Number of frames in the packet
+	Frames int
+	// Duration of each frame
+	FrameDuration time.Duration
+}
+
+// Duration returns the duration of the whole packet.
+func (p PacketInfo) Duration() time.Duration {
+	return time.Duration(p.Frames) * p.FrameDuration
+}
+
+// ParsePacket reads the mode, bandwidth and duration of an Opus packet without
+// decoding it. Use it on the output of Encode to see which mode the encoder
+// picked, e.g. to spot it flipping modes under bitrate pressure, or on
+// received packets.
+func ParsePacket(data []byte) (PacketInfo, error) {
+	frames, err := packetFrames(data)
+	if err != nil {
+		return PacketInfo{}, err
+	}
+	config := data[0] >> 3
+	info := PacketInfo{
+		Stereo:        data[0]&0x4 != 0,
+		Frames:        frames,
+		FrameDuration: samples48kToDuration(int64(tocFrameSize48k(data[0]))),
+	}
+	switch {
+	case config < 12:
+		info.Mode = ModeSILK
+		info.Bandwidth = [...]Bandwidth{Narrowband, Mediumband, Wideband}[config/4]
+	case config < 16:
+		info.Mode = ModeHybrid
+		info.Bandwidth = [...]Bandwidth{SuperWideband, Fullband}[(config-12)/2]
+	default:
+		info.Mode = ModeCELT
+		info.Bandwidth = [...]Bandwidth{Narrowband, Wideband, SuperWideband, Fullband}[(config-16)/4]
+	}
+	if info.Duration() > 120*time.Millisecond {
+		return PacketInfo{}, errInvalidPacket
+	}
+	return info, nil
+}
@@ -0,0 +1,82 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePacketTOC(t *testing.T) {
+	cases := []struct {
+		data []byte
+		want PacketInfo
+	}{
+		// Config 1: SILK NB 20 ms, code 0
+		{[]byte{1 << 3}, PacketInfo{ModeSILK, Narrowband, false, 1, 20 * time.Millisecond}},
+		// Config 11: SILK WB 60 ms, stereo, code 1
+		{[]byte{11<<3 | 0x4 | 1}, PacketInfo{ModeSILK, Wideband, true, 2, 60 * time.Millisecond}},
+		// Config 15: hybrid FB 20 ms, code 2
+		{[]byte{15<<3 | 2}, PacketInfo{ModeHybrid, Fullband, false, 2, 20 * time.Millisecond}},
+		// Config 16: CELT NB 2.5 ms, code 3 with 4 frames
+		{[]byte{16<<3 | 3, 4}, PacketInfo{ModeCELT, Narrowband, false, 4, 2500 * time.Microsecond}},
+		// Config 31: CELT FB 20 ms
+		{[]byte{31 << 3}, PacketInfo{ModeCELT, Fullband, false, 1, 20 * time.Millisecond}},
+	}
+	for _, c := range cases {
+		info, err := ParsePacket(c.data)
+		if err != nil {
+			t.Errorf("Error parsing %x: %v", c.data, err)
+			continue
+		}
+		if info != c.want {
+			t.Errorf("Parsing %x: expected %+v, got %+v", c.data, c.want, info)
+		}
+	}
+}
+
+func TestParsePacketInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		// Code 3 without frame count
+		{3},
+		// Code 3 with 0 frames
+		{3, 0},
+		// 3 frames of 60 ms
+		{11<<3 | 3, 3},
+	} {
+		if _, err := ParsePacket(data); err == nil {
+			t.Errorf("Expected error parsing %x", data)
+		}
+	}
+}
+
+func TestParsePacketEncoded(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 10 / 1000
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	// Restricted low delay never uses SILK
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppRestrictedLowdelay)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	info, err := ParsePacket(data[:n])
+	if err != nil {
+		t.Fatalf("Couldn't parse packet: %v", err)
+	}
+	if info.Mode != ModeCELT {
+		t.Errorf("Expected CELT mode, got %v", info.Mode)
+	}
+	if info.Duration() != 10*time.Millisecond {
+		t.Errorf("Expected 10ms packet, got %v", info.Duration())
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// Package packetfec adds an outer layer of forward error correction on top of
+// Opus packets, for one-way links (satellite, multicast) where lost packets
+// can't be retransmitted. It works like flexfec: for every group of k
+// consecutive packets the sender emits one repair packet holding the XOR of
+// the group, and the receiver uses it to rebuild any single lost packet of
+// the group. Smaller groups repair more losses at the cost of more overhead
+// (1/k).
+//
+// This complements Opus in-band FEC (LBRR), which only carries a low quality
+// copy of the previous frame. Packets are identified by 16 bit sequence
+// numbers, e.g. those of the RTP packets carrying them; transport is up to
+// the application.
+package packetfec
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Repair packet header: base sequence number, group size, XOR of the packet
+// lengths.
+const headerSize = 5
+
+// MaxGroupSize is the largest number of packets protected by one repair
+// packet.
+const MaxGroupSize = 48
+
+// Encoder generates repair packets for a stream of packets.
+type Encoder struct {
+	k     int
+	base  uint16
+	n     int
+	size  uint16
+	block []byte
+}
+
+// NewEncoder creates an encoder emitting one repair packet for every k
+// packets.
+func NewEncoder(k int) (*Encoder, error) {
+	if k < 1 || k > MaxGroupSize {
+		return nil, fmt.Errorf("packetfec: invalid group size: %d", k)
+	}
+	return &Encoder{k: k}, nil
+}
+
+// Add adds the next packet, which must have sequence number one higher than
+// the previous one. Returns a repair packet once a group is complete, nil
+// otherwise.
+func (e *Encoder) Add(seq uint16, packet []byte) ([]byte, error) {
+	if len(packet) > 0xffff {
+		return nil, fmt.Errorf("packetfec: packet too large: %d bytes", len(packet))
+	}
+	if e.n > 0 && seq != e.base+uint16(e.n) {
+		return nil, fmt.Errorf("packetfec: sequence number %d, expected %d", seq, e.base+uint16(e.n))
+	}
+	if e.n == 0 {
+		e.base = seq
+		e.size = 0
+		e.block = e.block[:0]
+	}
+	e.size ^= uint16(len(packet))
+	xor(&e.block, packet)
+	e.n++
+	if e.n < e.k {
+		return nil, nil
+	}
+	e.n = 0
+	repair := make([]byte, headerSize+len(e.block))
+	binary.BigEndian.PutUint16(repair[0:], e.base)
+	repair[2] = byte(e.k)
+	binary.BigEndian.PutUint16(repair[3:], e.size)
+	copy(repair[headerSize:], e.block)
+	return repair, nil
+}
+
+// xor XORs src into dst, growing dst with zeroes as needed.
+func xor(dst *[]byte, src []byte) {
+	for len(*dst) < len(src) {
+		*dst = append(*dst, 0)
+	}
+	d := *dst
+	for i, b := range src {
+		d[i] ^= b
+	}
+}
+
+// Recovered is a packet rebuilt from a repair packet.
+type Recovered struct {
+	Seq    uint16
+	Packet []byte
+}
+
+type repair struct {
+	base uint16
+	k    int
+	size uint16
+	data []byte
+}
+
+// Decoder rebuilds lost packets from repair packets. It remembers the most
+// recent packets and repair packets within a window of sequence numbers.
+type Decoder struct {
+	window  int
+	latest  uint16
+	started bool
+	packets map[uint16][]byte
+	repairs map[uint16]repair
+}
+
+// NewDecoder creates a decoder. Packets older than window sequence numbers
+// behind the newest one are forgotten; the window must cover at least a
+// group plus the expected reordering.
+func NewDecoder(window int) (*Decoder, error) {
+	if window < 1 || window > 0x7fff {
+		return nil, fmt.Errorf("packetfec: invalid window: %d", window)
+	}
+	return &Decoder{
+		window:  window,
+		packets: map[uint16][]byte{},
+		repairs: map[uint16]repair{},
+	}, nil
+}
+
+// Packet records a received packet. Returns any packets which could be
+// recovered thanks to it.
+func (d *Decoder) Packet(seq uint16, packet []byte) []Recovered {
+	if !d.see(seq) {
+		return nil
+	}
+	if _, ok := d.packets[seq]; ok {
+		return nil
+	}
+	d.packets[seq] = append([]byte(nil), packet...)
+	var res []Recovered
+	for base, r := range d.repairs {
+		if seq-base < uint16(r.k) {
+			res = append(res, d.try(r)...)
+		}
+	}
+	return res
+}
+
+// Repair records a received repair packet. Returns the lost packet of its
+// group, if it was the only one lost.
+func (d *Decoder) Repair(data []byte) ([]Recovered, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("packetfec: repair packet too short: %d bytes", len(data))
+	}
+	r := repair{
+		base: binary.BigEndian.Uint16(data[0:]),
+		k:    int(data[2]),
+		size: binary.BigEndian.Uint16(data[3:]),
+		data: append([]byte(nil), data[headerSize:]...),
+	}
+	if r.k < 1 || r.k > MaxGroupSize {
+		return nil, fmt.Errorf("packetfec: invalid group size in repair packet: %d", r.k)
+	}
+	if !d.see(r.base + uint16(r.k) - 1) {
+		return nil, nil
+	}
+	d.repairs[r.base] = r
+	return d.try(r), nil
+}
+
+// see updates the window with a sequence number, and reports whether it's
+// still within the window.
+func (d *Decoder) see(seq uint16) bool {
+	if !d.started {
+		d.latest = seq
+		d.started = true
+	}
+	if diff := int16(seq - d.latest); diff > 0 {
+		d.latest = seq
+		d.prune()
+	}
+	return !d.old(seq)
+}
+
+func (d *Decoder) old(seq uint16) bool {
+	return int(d.latest-seq) >= d.window
+}
+
+func (d *Decoder) prune() {
+	for seq := range d.packets {
+		if d.old(seq) {
+			delete(d.packets, seq)
+		}
+	}
+	for base, r := range d.repairs {
+		if d.old(base + uint16(r.k) - 1) {
+			delete(d.repairs, base)
+		}
+	}
+}
+
+// try recovers the packet missing from the group of r, if exactly one is.
+func (d *Decoder) try(r repair) []Recovered {
+	missing := -1
+	for i := 0; i < r.k; i++ {
+		if _, ok := d.packets[r.base+uint16(i)]; !ok {
+			if missing >= 0 {
+				return nil
+			}
+			missing = i
+		}
+	}
+	// All present, the repair packet isn't needed anymore
+	delete(d.repairs, r.base)
+	if missing < 0 {
+		return nil
+	}
+	size := r.size
+	block := append([]byte(nil), r.data...)
+	for i := 0; i < r.k; i++ {
+		if i != missing {
+			p := d.packets[r.base+uint16(i)]
+			size ^= uint16(len(p))
+			xor(&block, p)
+		}
+	}
+	if int(size) > len(block) {
+		// Corrupt repair packet
+		return nil
+	}
+	seq := r.base + uint16(missing)
+	d.packets[seq] = block[:size]
+	return []Recovered{{Seq: seq, Packet: block[:size]}}
+}
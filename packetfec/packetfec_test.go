@@ -0,0 +1,115 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package packetfec
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func randomPackets(n int, start uint16) map[uint16][]byte {
+	rng := rand.New(rand.NewSource(1))
+	packets := map[uint16][]byte{}
+	for i := 0; i < n; i++ {
+		p := make([]byte, 20+rng.Intn(200))
+		rng.Read(p)
+		packets[start+uint16(i)] = p
+	}
+	return packets
+}
+
+func TestRecoverSingleLoss(t *testing.T) {
+	const K = 4
+	const N = 40
+	// Start close to the wrap around of sequence numbers
+	const START = 0xfff0
+	packets := randomPackets(N, START)
+	enc, err := NewEncoder(K)
+	if err != nil {
+		t.Fatalf("Error creating encoder: %v", err)
+	}
+	dec, err := NewDecoder(64)
+	if err != nil {
+		t.Fatalf("Error creating decoder: %v", err)
+	}
+	recovered := map[uint16][]byte{}
+	for i := 0; i < N; i++ {
+		seq := START + uint16(i)
+		repair, err := enc.Add(seq, packets[seq])
+		if err != nil {
+			t.Fatalf("Error adding packet %d: %v", seq, err)
+		}
+		// Lose the third packet of every group
+		if i%K != 2 {
+			for _, r := range dec.Packet(seq, packets[seq]) {
+				recovered[r.Seq] = r.Packet
+			}
+		}
+		if repair != nil {
+			rs, err := dec.Repair(repair)
+			if err != nil {
+				t.Fatalf("Error adding repair packet: %v", err)
+			}
+			for _, r := range rs {
+				recovered[r.Seq] = r.Packet
+			}
+		}
+	}
+	if len(recovered) != N/K {
+		t.Errorf("Expected %d recovered packets, got %d", N/K, len(recovered))
+	}
+	for seq, p := range recovered {
+		if !bytes.Equal(p, packets[seq]) {
+			t.Errorf("Packet %d recovered wrongly", seq)
+		}
+	}
+}
+
+func TestRepairBeforePacket(t *testing.T) {
+	packets := randomPackets(3, 10)
+	enc, _ := NewEncoder(3)
+	dec, _ := NewDecoder(64)
+	var repair []byte
+	for seq := uint16(10); seq < 13; seq++ {
+		repair, _ = enc.Add(seq, packets[seq])
+	}
+	// Repair packet overtakes the group, packet 11 is lost
+	if rs, err := dec.Repair(repair); err != nil || len(rs) != 0 {
+		t.Fatalf("Expected nothing recovered yet, got %v (%v)", rs, err)
+	}
+	dec.Packet(10, packets[10])
+	rs := dec.Packet(12, packets[12])
+	if len(rs) != 1 || rs[0].Seq != 11 || !bytes.Equal(rs[0].Packet, packets[11]) {
+		t.Errorf("Expected packet 11 recovered, got %v", rs)
+	}
+}
+
+func TestDoubleLoss(t *testing.T) {
+	packets := randomPackets(4, 0)
+	enc, _ := NewEncoder(4)
+	dec, _ := NewDecoder(64)
+	var repair []byte
+	for seq := uint16(0); seq < 4; seq++ {
+		repair, _ = enc.Add(seq, packets[seq])
+		if seq < 2 {
+			dec.Packet(seq, packets[seq])
+		}
+	}
+	if rs, _ := dec.Repair(repair); len(rs) != 0 {
+		t.Errorf("Expected no recovery with two losses, got %v", rs)
+	}
+}
+
+func TestEncoderSequence(t *testing.T) {
+	enc, _ := NewEncoder(4)
+	enc.Add(1, []byte{1})
+	if _, err := enc.Add(3, []byte{3}); err == nil {
+		t.Errorf("Expected error for sequence gap")
+	}
+	if _, err := NewEncoder(0); err == nil {
+		t.Errorf("Expected error for group size 0")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Parallel encoding splits the input into segments of at least this long, so
+// the warm-up per segment stays a small overhead.
+const minParallelSegment = 10 * time.Second
+
+// Every segment encoder first encodes this much of the audio before its
+// segment, discarding the packets, so its state matches that of a single
+// encoder at the boundary.
+const parallelWarmup = 500 * time.Millisecond
+
+// frameEncoder encodes frame i of the input, padded with silence at the end.
+type frameEncoder func(i int, data []byte) (int, error)
+
+// EncodeParallel encodes a long recording into frames of the given duration
+// on multiple cores, for offline encoding. The input is split into segments
+// encoded concurrently by separate encoders; the packets are one continuous
+// stream which decodes like the output of a single encoder. The last frame is
+// padded with silence.
+//
+// To avoid artifacts at the segment boundaries, each encoder warms up on the
+// audio before its segment, and encodes the first frame of its segment with
+// prediction disabled, so it doesn't depend on decoder state the previous
+// segment's encoder may have diverged on.
+func EncodeParallel(pcm []int16, sample_rate int, channels int, cfg EncoderConfig, frame time.Duration, workers int) ([][]byte, error) {
+	frameSize := FrameSamples(sample_rate, frame)
+	return encodeParallel(len(pcm), sample_rate, channels, cfg, frame, workers, func(enc *Encoder) frameEncoder {
+		buf := make([]int16, frameSize*channels)
+		return func(i int, data []byte) (int, error) {
+			n := 0
+			if start := i * len(buf); start < len(pcm) {
+				n = copy(buf, pcm[start:])
+			}
+			for j := n; j < len(buf); j++ {
+				buf[j] = 0
+			}
+			return enc.Encode(buf, data)
+		}
+	})
+}
+
+// EncodeParallelFloat32 is the same as EncodeParallel, but for float32 audio.
+func EncodeParallelFloat32(pcm []float32, sample_rate int, channels int, cfg EncoderConfig, frame time.Duration, workers int) ([][]byte, error) {
+	frameSize := FrameSamples(sample_rate, frame)
+	return encodeParallel(len(pcm), sample_rate, channels, cfg, frame, workers, func(enc *Encoder) frameEncoder {
+		buf := make([]float32, frameSize*channels)
+		return func(i int, data []byte) (int, error) {
+			n := 0
+			if start := i * len(buf); start < len(pcm) {
+				n = copy(buf, pcm[start:])
+			}
+			for j := n; j < len(buf); j++ {
+				buf[j] = 0
+			}
+			return enc.EncodeFloat32(buf, data)
+		}
+	})
+}
+
+func encodeParallel(length int, sample_rate int, channels int, cfg EncoderConfig, frame time.Duration, workers int, newFrameEncoder func(enc *Encoder) frameEncoder) ([][]byte, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("opus: invalid number of workers: %d", workers)
+	}
+	if err := validateChannels(channels); err != nil {
+		return nil, err
+	}
+	if length%channels != 0 {
+		return nil, fmt.Errorf("opus: input buffer length must be multiple of channels")
+	}
+	frameSize := FrameSamples(sample_rate, frame)
+	if err := validateFrameSize(sample_rate, channels, frameSize); err != nil {
+		return nil, err
+	}
+	frames := (length/channels + frameSize - 1) / frameSize
+	segment := (frames + workers - 1) / workers
+	if minFrames := int(minParallelSegment / frame); segment < minFrames {
+		segment = minFrames
+	}
+	warmup := int(parallelWarmup / frame)
+
+	packets := make([][]byte, frames)
+	segments := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range segments {
+				end := start + segment
+				if end > frames {
+					end = frames
+				}
+				err := encodeSegment(packets, start, end, warmup, sample_rate, channels, cfg, newFrameEncoder)
+				if err != nil {
+					once.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	for start := 0; start < frames; start += segment {
+		segments <- start
+	}
+	close(segments)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return packets, nil
+}
+
+// encodeSegment encodes frames [start, end) into packets with a fresh
+// encoder.
+func encodeSegment(packets [][]byte, start, end, warmup int, sample_rate int, channels int, cfg EncoderConfig, newFrameEncoder func(enc *Encoder) frameEncoder) error {
+	enc, err := NewEncoder(sample_rate, channels, cfg.Application)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ApplyTo(enc); err != nil {
+		return err
+	}
+	encode := newFrameEncoder(enc)
+	data := make([]byte, maxEncodedFrameSize)
+	first := start - warmup
+	if first < 0 {
+		first = 0
+	}
+	for i := first; i < end; i++ {
+		boundary := i == start && start > 0
+		if boundary {
+			if err := enc.SetPredictionDisabled(true); err != nil {
+				return err
+			}
+		}
+		n, err := encode(i, data)
+		if err != nil {
+			return err
+		}
+		if boundary {
+			if err := enc.SetPredictionDisabled(false); err != nil {
+				return err
+			}
+		}
+		if i >= start {
+			packets[i] = append([]byte(nil), data[:n]...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeParallel(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	// Long enough for several segments, plus half a frame
+	const SAMPLES = 35*SAMPLE_RATE + FRAME_SIZE/2
+	pcm := make([]int16, SAMPLES)
+	addSine(pcm, SAMPLE_RATE, G4)
+	cfg := EncoderConfig{Application: AppAudio, Bitrate: 32000, Complexity: 5, MaxBandwidth: Fullband}
+	packets, err := EncodeParallel(pcm, SAMPLE_RATE, 1, cfg, 20*time.Millisecond, 4)
+	if err != nil {
+		t.Fatalf("Couldn't encode in parallel: %v", err)
+	}
+	if len(packets) != SAMPLES/FRAME_SIZE+1 {
+		t.Fatalf("Expected %d packets, got %d", SAMPLES/FRAME_SIZE+1, len(packets))
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	out := make([]int16, FRAME_SIZE)
+	for i, p := range packets {
+		if len(p) == 0 {
+			t.Fatalf("Packet %d is missing", i)
+		}
+		n, err := dec.Decode(p, out)
+		if err != nil {
+			t.Fatalf("Couldn't decode packet %d: %v", i, err)
+		}
+		if n != FRAME_SIZE {
+			t.Errorf("Expected %d samples from packet %d, got %d", FRAME_SIZE, i, n)
+		}
+	}
+	if _, err := EncodeParallel(pcm, SAMPLE_RATE, 1, cfg, 20*time.Millisecond, 0); err == nil {
+		t.Errorf("Expected error for 0 workers")
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"encoding/binary"
+)
+
+// DecodeBytes decodes a packet into pcm as signed 16 bit samples of two bytes
+// each, in the given byte order: binary.LittleEndian for s16le, or
+// binary.BigEndian for the s16be many network audio protocols and older
+// telephony equipment use. Returns the number of samples per channel written.
+func (dec *Decoder) DecodeBytes(data []byte, pcm []byte, order binary.ByteOrder) (int, error) {
+	if dec.p == nil {
+		return 0, dec.errUninitialized()
+	}
+	samples := len(pcm) / 2
+	samples -= samples % dec.bufChannels()
+	if cap(dec.bytesBuf) < samples {
+		dec.bytesBuf = make([]int16, samples)
+	}
+	buf := dec.bytesBuf[:samples:samples]
+	n, err := dec.Decode(data, buf)
+	if err != nil {
+		return 0, err
+	}
+	putSamples(pcm, buf[:n*dec.outChannels()], order)
+	return n, nil
+}
+
+// putSamples writes 16 bit samples to b in the given byte order.
+func putSamples(b []byte, pcm []int16, order binary.ByteOrder) {
+	for i, s := range pcm {
+		order.PutUint16(b[2*i:], uint16(s))
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeBytes(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const CHANNELS = 2
+	enc, err := NewEncoder(SAMPLE_RATE, CHANNELS, AppAudio)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*CHANNELS)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	data = data[:n]
+
+	orders := []binary.ByteOrder{binary.LittleEndian, binary.BigEndian}
+	for _, order := range orders {
+		dec, err := NewDecoder(SAMPLE_RATE, CHANNELS)
+		if err != nil {
+			t.Fatalf("Error creating new decoder: %v", err)
+		}
+		ref, err := NewDecoder(SAMPLE_RATE, CHANNELS)
+		if err != nil {
+			t.Fatalf("Error creating new decoder: %v", err)
+		}
+		expected := make([]int16, FRAME_SIZE*CHANNELS)
+		if _, err := ref.Decode(data, expected); err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		out := make([]byte, 2*FRAME_SIZE*CHANNELS)
+		n, err := dec.DecodeBytes(data, out, order)
+		if err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		if n != FRAME_SIZE {
+			t.Fatalf("Expected %d samples, got %d", FRAME_SIZE, n)
+		}
+		for i, s := range expected {
+			if got := int16(order.Uint16(out[2*i:])); got != s {
+				t.Fatalf("%v: sample %d is %d, expected %d", order, i, got, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"sync"
+)
+
+type encoderKey struct {
+	sampleRate  int
+	channels    int
+	application Application
+}
+
+// EncoderPool recycles encoders, saving the allocation and initialization
+// cost for servers handling many short lived calls. Encoders are reset to
+// their initial state, including all settings, when they are returned to the
+// pool. Idle encoders may be dropped by the garbage collector at any time.
+//
+// EncoderPool is safe for concurrent use.
+type EncoderPool struct {
+	mu    sync.Mutex
+	pools map[encoderKey]*sync.Pool
+}
+
+func NewEncoderPool() *EncoderPool {
+	return &EncoderPool{pools: map[encoderKey]*sync.Pool{}}
+}
+
+func (ep *EncoderPool) pool(key encoderKey) *sync.Pool {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	p, ok := ep.pools[key]
+	if !ok {
+		p = &sync.Pool{}
+		ep.pools[key] = p
+	}
+	return p
+}
+
+// Get returns an encoder with the given parameters, either from the pool or
+// newly created.
+func (ep *EncoderPool) Get(sample_rate int, channels int, application Application) (*Encoder, error) {
+	key := encoderKey{sample_rate, channels, application}
+	if enc, ok := ep.pool(key).Get().(*Encoder); ok {
+		enc.debug.reopen()
+		return enc, nil
+	}
+	return NewEncoder(sample_rate, channels, application)
+}
+
+// Put resets the encoder and returns it to the pool. The encoder must not be
+// used by the caller anymore afterwards. Only encoders on the Go heap can be
+// pooled.
+func (ep *EncoderPool) Put(enc *Encoder) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	if enc.mem == nil {
+		return fmt.Errorf("opus: can't pool encoder allocated on the C heap")
+	}
+	sampleRate, err := enc.SampleRate()
+	if err != nil {
+		return err
+	}
+	app, err := enc.Application()
+	if err != nil {
+		return err
+	}
+	if err := enc.reinit(sampleRate, app); err != nil {
+		return err
+	}
+	// Being dropped from the pool by the GC is not a leak
+	enc.debug.close()
+	ep.pool(encoderKey{sampleRate, enc.channels, app}).Put(enc)
+	return nil
+}
+
+type decoderKey struct {
+	sampleRate int
+	channels   int
+}
+
+// DecoderPool is the decoding counterpart of EncoderPool.
+type DecoderPool struct {
+	mu    sync.Mutex
+	pools map[decoderKey]*sync.Pool
+}
+
+func NewDecoderPool() *DecoderPool {
+	return &DecoderPool{pools: map[decoderKey]*sync.Pool{}}
+}
+
+func (dp *DecoderPool) pool(key decoderKey) *sync.Pool {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	p, ok := dp.pools[key]
+	if !ok {
+		p = &sync.Pool{}
+		dp.pools[key] = p
+	}
+	return p
+}
+
+// Get returns a decoder with the given parameters, either from the pool or
+// newly created.
+func (dp *DecoderPool) Get(sample_rate int, channels int) (*Decoder, error) {
+	if dec, ok := dp.pool(decoderKey{sample_rate, channels}).Get().(*Decoder); ok {
+		dec.debug.reopen()
+		return dec, nil
+	}
+	return NewDecoder(sample_rate, channels)
+}
+
+// Put resets the decoder and returns it to the pool. The decoder must not be
+// used by the caller anymore afterwards. Only decoders on the Go heap can be
+// pooled.
+func (dp *DecoderPool) Put(dec *Decoder) error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	if dec.mem == nil {
+		return fmt.Errorf("opus: can't pool decoder allocated on the C heap")
+	}
+	if err := dec.reinit(); err != nil {
+		return err
+	}
+	dec.debug.close()
+	dp.pool(decoderKey{dec.sample_rate, dec.channels}).Put(dec)
+	return nil
+}
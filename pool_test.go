@@ -0,0 +1,177 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+)
+
+func TestEncoderPool(t *testing.T) {
+	pool := NewEncoderPool()
+	enc, err := pool.Get(16000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error getting encoder from pool: %v", err)
+	}
+	if err := enc.SetComplexity(1); err != nil {
+		t.Fatalf("Error setting complexity: %v", err)
+	}
+	if err := pool.Put(enc); err != nil {
+		t.Fatalf("Error returning encoder to pool: %v", err)
+	}
+	// Whether or not we get the same instance back, it must be pristine
+	enc, err = pool.Get(16000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error getting encoder from pool: %v", err)
+	}
+	cpx, err := enc.Complexity()
+	if err != nil {
+		t.Fatalf("Error getting complexity: %v", err)
+	}
+	// default complexity value is 9
+	if cpx != 9 {
+		t.Errorf("Pooled encoder not reset: complexity %d", cpx)
+	}
+	sr, err := enc.SampleRate()
+	if err != nil {
+		t.Fatalf("Error getting sample rate: %v", err)
+	}
+	if sr != 16000 {
+		t.Errorf("Unexpected sample rate of pooled encoder: %d", sr)
+	}
+
+	cheap, err := NewEncoderCHeap(16000, 1, AppVoIP)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	defer cheap.Close()
+	if err := pool.Put(cheap); err == nil {
+		t.Errorf("Expected error pooling C heap encoder")
+	}
+}
+
+func TestDecoderPool(t *testing.T) {
+	pool := NewDecoderPool()
+	dec, err := pool.Get(24000, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error getting decoder from pool: %v", err)
+	}
+	if err := pool.Put(dec); err != nil {
+		t.Fatalf("Error returning decoder to pool: %v", err)
+	}
+	dec, err = pool.Get(24000, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error getting decoder from pool: %v", err)
+	}
+	if dec.sample_rate != 24000 || dec.channels != 2 {
+		t.Errorf("Unexpected pooled decoder parameters: %d Hz, %d channels",
+			dec.sample_rate, dec.channels)
+	}
+}
+
+func TestDecoderPoolOutputLayout(t *testing.T) {
+	pool := NewDecoderPool()
+	dec, err := pool.Get(48000, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error getting decoder from pool: %v", err)
+	}
+	if err := dec.SetMonoOutput(true); err != nil {
+		t.Fatalf("Error setting mono output: %v", err)
+	}
+	if err := pool.Put(dec); err != nil {
+		t.Fatalf("Error returning decoder to pool: %v", err)
+	}
+	dec, err = pool.Get(48000, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error getting decoder from pool: %v", err)
+	}
+	if dec.outChannels() != 2 {
+		t.Errorf("Pooled decoder not reset: %d output channels", dec.outChannels())
+	}
+	// Would conflict with a leftover mono output
+	if err := dec.SetChannelMap([]int{1, 0}); err != nil {
+		t.Fatalf("Error setting channel map: %v", err)
+	}
+	if err := pool.Put(dec); err != nil {
+		t.Fatalf("Error returning decoder to pool: %v", err)
+	}
+	dec, err = pool.Get(48000, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error getting decoder from pool: %v", err)
+	}
+	if m := dec.ChannelMap(); m != nil {
+		t.Errorf("Pooled decoder not reset: channel map %v", m)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	ep := NewEncoderPool()
+	enc, err := ep.Get(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error getting encoder from pool: %v", err)
+	}
+	if err := enc.EnableTiming(); err != nil {
+		t.Fatalf("Error enabling timing: %v", err)
+	}
+	if err := enc.EnableTrace(10); err != nil {
+		t.Fatalf("Error enabling trace: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, 440)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	if err := ep.Put(enc); err != nil {
+		t.Fatalf("Error returning encoder to pool: %v", err)
+	}
+	enc, err = ep.Get(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error getting encoder from pool: %v", err)
+	}
+	if timing := enc.Timing(); timing != (CallTiming{}) {
+		t.Errorf("Pooled encoder has timing of previous user: %+v", timing)
+	}
+	if trace := enc.Trace(); trace != nil {
+		t.Errorf("Pooled encoder has trace of previous user: %v", trace)
+	}
+
+	dp := NewDecoderPool()
+	dec, err := dp.Get(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error getting decoder from pool: %v", err)
+	}
+	if err := dec.EnableTiming(); err != nil {
+		t.Fatalf("Error enabling timing: %v", err)
+	}
+	if err := dec.SetConcealPolicy(ConcealPolicy{Strategy: ConcealFadeOut}); err != nil {
+		t.Fatalf("Error setting policy: %v", err)
+	}
+	out := make([]int16, FRAME_SIZE)
+	if _, err := dec.Decode(data[:n], out); err != nil {
+		t.Fatalf("Couldn't decode data: %v", err)
+	}
+	if err := dec.Conceal(out); err != nil {
+		t.Fatalf("Couldn't conceal frame: %v", err)
+	}
+	if err := dp.Put(dec); err != nil {
+		t.Fatalf("Error returning decoder to pool: %v", err)
+	}
+	dec, err = dp.Get(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error getting decoder from pool: %v", err)
+	}
+	if timing := dec.Timing(); timing != (CallTiming{}) {
+		t.Errorf("Pooled decoder has timing of previous user: %+v", timing)
+	}
+	if stats := dec.ConcealStats(); stats != (ConcealStats{}) {
+		t.Errorf("Pooled decoder has conceal stats of previous user: %+v", stats)
+	}
+	if dec.lastStrategy != ConcealPLC {
+		t.Errorf("Pooled decoder has conceal strategy of previous user: %d", dec.lastStrategy)
+	}
+}
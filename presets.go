@@ -0,0 +1,219 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Preset is a coherent bundle of encoder settings for a particular use case,
+// so users don't need to know how every knob interacts with the others.
+type Preset struct {
+	Name           string
+	Bitrate        int
+	Complexity     int
+	InBandFEC      bool
+	PacketLossPerc int
+	DTX            bool
+	MaxBandwidth   Bandwidth
+	// FrameDuration is the duration of the frames the encoder codes, set
+	// with SetExpertFrameDuration. Encode must be given at least this much
+	// audio per call. Zero leaves it to the length of the PCM buffer.
+	FrameDuration time.Duration
+}
+
+var presets = map[string]Preset{
+	"lan": {
+		Name:          "lan",
+		Bitrate:       64000,
+		Complexity:    10,
+		MaxBandwidth:  Fullband,
+		FrameDuration: 20 * time.Millisecond,
+	},
+	"mobile-3g": {
+		Name:           "mobile-3g",
+		Bitrate:        24000,
+		Complexity:     5,
+		InBandFEC:      true,
+		PacketLossPerc: 10,
+		DTX:            true,
+		MaxBandwidth:   Wideband,
+		FrameDuration:  40 * time.Millisecond,
+	},
+	"satellite": {
+		Name:           "satellite",
+		Bitrate:        16000,
+		Complexity:     10,
+		InBandFEC:      true,
+		PacketLossPerc: 5,
+		DTX:            true,
+		MaxBandwidth:   Wideband,
+		FrameDuration:  60 * time.Millisecond,
+	},
+	// Maximum quality for file encoding. Decode with SetSoftClip(true) to
+	// avoid hard clipping of loud masters.
+	"archival": {
+		Name:          "archival",
+		Bitrate:       510000,
+		Complexity:    10,
+		MaxBandwidth:  Fullband,
+		FrameDuration: 20 * time.Millisecond,
+	},
+	"music-archival": {
+		Name:          "music-archival",
+		Bitrate:       256000,
+		Complexity:    10,
+		MaxBandwidth:  Fullband,
+		FrameDuration: 20 * time.Millisecond,
+	},
+	"voip-lossy": {
+		Name:           "voip-lossy",
+		Bitrate:        32000,
+		Complexity:     8,
+		InBandFEC:      true,
+		PacketLossPerc: 20,
+		MaxBandwidth:   Wideband,
+		FrameDuration:  20 * time.Millisecond,
+	},
+}
+
+// LookupPreset returns the named preset. Known names are "lan", "mobile-3g",
+// "satellite", "archival", "music-archival" and "voip-lossy".
+func LookupPreset(name string) (Preset, error) {
+	p, ok := presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("opus: unknown preset %q", name)
+	}
+	return p, nil
+}
+
+// PresetNames returns the names of all known presets, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyPreset configures the encoder with all settings from the preset,
+// including its frame duration.
+func (enc *Encoder) ApplyPreset(p Preset) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	fd, err := frameDurationOf(p.FrameDuration)
+	if err != nil {
+		return err
+	}
+	if err := enc.SetBitrate(p.Bitrate); err != nil {
+		return err
+	}
+	if err := enc.SetComplexity(p.Complexity); err != nil {
+		return err
+	}
+	if err := enc.SetMaxBandwidth(p.MaxBandwidth); err != nil {
+		return err
+	}
+	if err := enc.SetInBandFEC(p.InBandFEC); err != nil {
+		return err
+	}
+	if err := enc.SetPacketLossPerc(p.PacketLossPerc); err != nil {
+		return err
+	}
+	if err := enc.SetDTX(p.DTX); err != nil {
+		return err
+	}
+	return enc.SetExpertFrameDuration(fd)
+}
+
+// Recommended settings for ApplyVoicePreset: speech doesn't need more than
+// wideband, and calls benefit from FEC and DTX.
+var voiceSettings = encoderSettings{
+	complexity:     10,
+	maxBandwidth:   Wideband,
+	inBandFEC:      true,
+	packetLossPerc: 10,
+	dtx:            true,
+}
+
+// Recommended settings for ApplyMusicPreset: full bandwidth, and no FEC or
+// DTX, which cost music quality.
+var musicSettings = encoderSettings{
+	complexity:   10,
+	maxBandwidth: Fullband,
+}
+
+// ApplyVoicePreset configures the encoder for speech, e.g. calls: AppVoIP, a
+// voice signal hint, wideband, maximum complexity, FEC tuned for 10% packet
+// loss, and DTX. The bitrate is left alone.
+//
+// The application is changed with SwitchApplication, so on an encoder which
+// has already encoded audio with another application, this resets its state,
+// with an audible glitch.
+func (enc *Encoder) ApplyVoicePreset() error {
+	return enc.applyContentPreset(AppVoIP, SignalVoice, voiceSettings)
+}
+
+// ApplyMusicPreset configures the encoder for music: AppAudio, a music signal
+// hint, fullband, maximum complexity, and no FEC or DTX. The bitrate is left
+// alone. Like ApplyVoicePreset, this may reset an encoder which is already
+// in use.
+func (enc *Encoder) ApplyMusicPreset() error {
+	return enc.applyContentPreset(AppAudio, SignalMusic, musicSettings)
+}
+
+func (enc *Encoder) applyContentPreset(app Application, signal Signal, s encoderSettings) error {
+	if err := enc.SwitchApplication(app); err != nil {
+		return err
+	}
+	if err := enc.SetSignal(signal); err != nil {
+		return err
+	}
+	return enc.applySettings(s)
+}
+
+// Bitrates per channel of the preset constructors below.
+const (
+	voipBitrate           = 24000
+	musicStreamingBitrate = 64000
+	lowDelayBitrate       = 64000
+)
+
+// NewVoIPEncoder creates an encoder for calls: the voice preset (see
+// ApplyVoicePreset) at 24 kbit/s per channel. Pass it 20 ms frames.
+func NewVoIPEncoder(sample_rate int, channels int) (*Encoder, error) {
+	return NewEncoderWithOptions(sample_rate, channels, AppVoIP,
+		(*Encoder).ApplyVoicePreset,
+		WithBitrate(voipBitrate*channels))
+}
+
+// NewMusicStreamingEncoder creates an encoder for streaming music: the music
+// preset (see ApplyMusicPreset) at 64 kbit/s per channel, with constrained
+// VBR to keep bitrate spikes within what the stream's buffer absorbs. Pass
+// it 20 ms frames.
+func NewMusicStreamingEncoder(sample_rate int, channels int) (*Encoder, error) {
+	return NewEncoderWithOptions(sample_rate, channels, AppAudio,
+		(*Encoder).ApplyMusicPreset,
+		WithBitrate(musicStreamingBitrate*channels),
+		WithVBRConstraint(true))
+}
+
+// NewLowDelayEncoder creates an encoder for the lowest possible delay, e.g.
+// live monitoring: restricted low-delay mode at 64 kbit/s per channel,
+// without FEC or DTX, and with a moderate complexity to keep the cost of the
+// many small frames down. Pass it 5 ms frames, or 2.5 ms ones at the cost of
+// quality. See LowLatencyEncoder for a wrapper which enforces the frame
+// duration.
+func NewLowDelayEncoder(sample_rate int, channels int) (*Encoder, error) {
+	return NewEncoderWithOptions(sample_rate, channels, AppRestrictedLowdelay,
+		WithBitrate(lowDelayBitrate*channels),
+		WithComplexity(5),
+		WithInBandFEC(false),
+		WithDTX(false))
+}
@@ -0,0 +1,141 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresets(t *testing.T) {
+	for _, name := range PresetNames() {
+		p, err := LookupPreset(name)
+		if err != nil {
+			t.Fatalf("Error looking up preset %q: %v", name, err)
+		}
+		if p.Name != name {
+			t.Errorf("Preset %q has mismatching name %q", name, p.Name)
+		}
+		enc, err := NewEncoder(48000, 2, AppAudio)
+		if err != nil || enc == nil {
+			t.Fatalf("Error creating new encoder: %v", err)
+		}
+		if err := enc.ApplyPreset(p); err != nil {
+			t.Fatalf("Error applying preset %q: %v", name, err)
+		}
+		br, err := enc.Bitrate()
+		if err != nil {
+			t.Fatalf("Error getting bitrate: %v", err)
+		}
+		if br != p.Bitrate {
+			t.Errorf("Preset %q: unexpected bitrate %d, expected %d", name, br, p.Bitrate)
+		}
+		fec, err := enc.InBandFEC()
+		if err != nil {
+			t.Fatalf("Error getting fec: %v", err)
+		}
+		if fec != p.InBandFEC {
+			t.Errorf("Preset %q: unexpected fec %t", name, fec)
+		}
+		dtx, err := enc.DTX()
+		if err != nil {
+			t.Fatalf("Error getting dtx: %v", err)
+		}
+		if dtx != p.DTX {
+			t.Errorf("Preset %q: unexpected dtx %t", name, dtx)
+		}
+		fd, err := enc.ExpertFrameDuration()
+		if err != nil {
+			t.Fatalf("Error getting expert frame duration: %v", err)
+		}
+		if fd.Duration() != p.FrameDuration {
+			t.Errorf("Preset %q: unexpected frame duration %v, expected %v", name, fd, p.FrameDuration)
+		}
+	}
+	// Frame durations libopus doesn't support are refused
+	enc, err := NewEncoder(48000, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.ApplyPreset(Preset{Bitrate: 32000, Complexity: 5, MaxBandwidth: Fullband, FrameDuration: 30 * time.Millisecond}); err == nil {
+		t.Errorf("Expected error for unsupported frame duration")
+	}
+}
+
+func TestLookupPresetUnknown(t *testing.T) {
+	if _, err := LookupPreset("dial-up"); err == nil {
+		t.Errorf("Expected error for unknown preset")
+	}
+}
+
+func TestApplyContentPresets(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if err := enc.ApplyVoicePreset(); err != nil {
+		t.Fatalf("Error applying voice preset: %v", err)
+	}
+	if app, err := enc.Application(); err != nil || app != AppVoIP {
+		t.Errorf("Expected AppVoIP, got %v (%v)", app, err)
+	}
+	if signal, err := enc.Signal(); err != nil || signal != SignalVoice {
+		t.Errorf("Expected voice signal, got %v (%v)", signal, err)
+	}
+	if dtx, err := enc.DTX(); err != nil || !dtx {
+		t.Errorf("Expected DTX on, got %v (%v)", dtx, err)
+	}
+	if err := enc.ApplyMusicPreset(); err != nil {
+		t.Fatalf("Error applying music preset: %v", err)
+	}
+	if app, err := enc.Application(); err != nil || app != AppAudio {
+		t.Errorf("Expected AppAudio, got %v (%v)", app, err)
+	}
+	if signal, err := enc.Signal(); err != nil || signal != SignalMusic {
+		t.Errorf("Expected music signal, got %v (%v)", signal, err)
+	}
+	if bw, err := enc.MaxBandwidth(); err != nil || bw != Fullband {
+		t.Errorf("Expected fullband, got %v (%v)", bw, err)
+	}
+	if fec, err := enc.InBandFEC(); err != nil || fec {
+		t.Errorf("Expected FEC off, got %v (%v)", fec, err)
+	}
+}
+
+func TestPresetEncoders(t *testing.T) {
+	tests := []struct {
+		name    string
+		new     func(int, int) (*Encoder, error)
+		app     Application
+		bitrate int
+		fec     bool
+	}{
+		{"voip", NewVoIPEncoder, AppVoIP, 24000, true},
+		{"music streaming", NewMusicStreamingEncoder, AppAudio, 128000, false},
+		{"low delay", NewLowDelayEncoder, AppRestrictedLowdelay, 128000, false},
+	}
+	for _, tt := range tests {
+		channels := 2
+		if tt.app == AppVoIP {
+			channels = 1
+		}
+		enc, err := tt.new(48000, channels)
+		if err != nil || enc == nil {
+			t.Fatalf("Error creating %s encoder: %v", tt.name, err)
+		}
+		if app, err := enc.Application(); err != nil || app != tt.app {
+			t.Errorf("%s: expected %v, got %v (%v)", tt.name, tt.app, app, err)
+		}
+		if br, err := enc.Bitrate(); err != nil || br != tt.bitrate {
+			t.Errorf("%s: expected bitrate %d, got %d (%v)", tt.name, tt.bitrate, br, err)
+		}
+		if fec, err := enc.InBandFEC(); err != nil || fec != tt.fec {
+			t.Errorf("%s: expected fec %t, got %t (%v)", tt.name, tt.fec, fec, err)
+		}
+	}
+	if _, err := NewVoIPEncoder(44100, 1); err == nil {
+		t.Errorf("Expected error for an unsupported sample rate")
+	}
+}
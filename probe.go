@@ -0,0 +1,93 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+*/
+import "C"
+
+// PadPacket grows the Opus packet in data[:n] to size bytes by adding padding,
+// in place. The padded packet decodes to exactly the same audio. data must
+// have room for size bytes.
+func PadPacket(data []byte, n int, size int) error {
+	if n <= 0 || n > len(data) {
+		return fmt.Errorf("opus: invalid packet length: %d", n)
+	}
+	if size < n {
+		return fmt.Errorf("opus: can't pad %d byte packet to %d bytes", n, size)
+	}
+	if size > len(data) {
+		return fmt.Errorf("opus: target buffer too small: padding needs %d bytes", size)
+	}
+	res := C.opus_packet_pad((*C.uchar)(&data[0]), C.opus_int32(n), C.opus_int32(size))
+	if res != C.OPUS_OK {
+		return Error(res)
+	}
+	return nil
+}
+
+// probeBitrate keeps the encoded silence, before padding, as small as
+// possible.
+const probeBitrate = 6000
+
+// ProbeGenerator makes padded Opus packets of chosen sizes for bandwidth
+// probing. Each packet is a valid frame of silence padded to the requested
+// size, so a congestion controller can probe by sending extra packets on the
+// audio stream itself, indistinguishable on the wire from regular audio,
+// instead of opening a separate probe stream. Receivers decode them to
+// silence, so send them where the audio is silent anyway, e.g. in place of
+// DTX frames.
+type ProbeGenerator struct {
+	enc   *Encoder
+	frame time.Duration
+	pcm   []int16
+}
+
+// NewProbeGenerator creates a generator for packets of the given frame
+// duration, which must be a valid Opus frame duration.
+func NewProbeGenerator(sample_rate int, channels int, frame time.Duration) (*ProbeGenerator, error) {
+	enc, err := NewEncoder(sample_rate, channels, AppAudio)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.SetBitrate(probeBitrate); err != nil {
+		return nil, err
+	}
+	samples := FrameSamples(sample_rate, frame)
+	if err := validateFrameSize(sample_rate, channels, samples); err != nil {
+		return nil, err
+	}
+	return &ProbeGenerator{
+		enc:   enc,
+		frame: frame,
+		pcm:   make([]int16, samples*channels),
+	}, nil
+}
+
+// FrameDuration returns the duration of the generated packets.
+func (g *ProbeGenerator) FrameDuration() time.Duration {
+	return g.frame
+}
+
+// Packet writes a probe packet of exactly size bytes to data. Returns an error
+// if size is too small to hold the encoded silence, which is a handful of
+// bytes.
+func (g *ProbeGenerator) Packet(size int, data []byte) error {
+	if len(data) < size {
+		return fmt.Errorf("opus: target buffer too small: probe needs %d bytes", size)
+	}
+	n, err := g.enc.Encode(g.pcm, data)
+	if err != nil {
+		return err
+	}
+	return PadPacket(data, n, size)
+}
@@ -0,0 +1,77 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPadPacket(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	n, err := enc.Encode(pcm, data)
+	if err != nil {
+		t.Fatalf("Couldn't encode data: %v", err)
+	}
+	if err := PadPacket(data, n, n+200); err != nil {
+		t.Fatalf("Couldn't pad packet: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	out := make([]int16, FRAME_SIZE)
+	m, err := dec.Decode(data[:n+200], out)
+	if err != nil {
+		t.Fatalf("Couldn't decode padded packet: %v", err)
+	}
+	if m != FRAME_SIZE {
+		t.Errorf("Expected %d samples, got %d", FRAME_SIZE, m)
+	}
+	if err := PadPacket(data, n, n-1); err == nil {
+		t.Errorf("Expected error padding to a smaller size")
+	}
+}
+
+func TestProbeGenerator(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	g, err := NewProbeGenerator(SAMPLE_RATE, 2, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error creating probe generator: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 2)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	out := make([]int16, 2*960)
+	for _, size := range []int{50, 300, 1200} {
+		data := make([]byte, size)
+		if err := g.Packet(size, data); err != nil {
+			t.Fatalf("Couldn't make %d byte probe: %v", size, err)
+		}
+		info, err := ParsePacket(data)
+		if err != nil {
+			t.Fatalf("Probe of %d bytes isn't a valid packet: %v", size, err)
+		}
+		if info.Duration() != 20*time.Millisecond {
+			t.Errorf("Expected 20ms probe, got %v", info.Duration())
+		}
+		if _, err := dec.Decode(data, out); err != nil {
+			t.Errorf("Couldn't decode %d byte probe: %v", size, err)
+		}
+	}
+	if _, err := NewProbeGenerator(SAMPLE_RATE, 1, 7*time.Millisecond); err == nil {
+		t.Errorf("Expected error for invalid frame duration")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// PCMFrame is the decoded audio of one Opus packet.
+type PCMFrame struct {
+	// Interleaved 48 kHz samples, with the pre-skip and the end trimming of
+	// the stream already removed. May be empty at the start of the stream.
+	PCM []int16
+	// Number of samples per channel in PCM
+	Samples int
+}
+
+// PushDecoder decodes an Ogg Opus stream which is pushed in as it arrives,
+// instead of pulled from an io.Reader like Stream. This fits event loop
+// servers which get arbitrary chunks of bytes from a socket.
+//
+// Only the first Opus logical stream is decoded; other multiplexed streams
+// are skipped. When it ends, the next Opus stream in a chained file is
+// decoded. Pages with a bad checksum are dropped, and decoding continues at
+// the next page. Only mono and stereo streams (channel mapping family 0) are
+// supported.
+type PushDecoder struct {
+	// Bytes fed in but not yet consumed as a page
+	in          bytes.Buffer
+	r           *bufio.Reader
+	dec         *Decoder
+	sample_rate int
+	serial      uint32
+	state       checkState
+	// Whether the current stream has ended or is skipped, or there is none
+	// yet
+	ended    bool
+	channels int
+	// Samples at the start still to be discarded
+	skip int
+	// Samples decoded in the current stream, including the pre-skip
+	decoded int64
+	// Incomplete packet continued on the next page
+	packet  []byte
+	scratch []int16
+}
+
+// NewPushDecoder creates a PushDecoder. The decoder itself is created once
+// the stream header has been fed in.
+func NewPushDecoder() *PushDecoder {
+	return newPushDecoder(GranuleSampleRate)
+}
+
+// newPushDecoder creates a PushDecoder which decodes at another sample rate
+// than 48 kHz.
+func newPushDecoder(sample_rate int) *PushDecoder {
+	p := &PushDecoder{sample_rate: sample_rate, ended: true}
+	p.r = bufio.NewReaderSize(&p.in, oggMaxPageSize)
+	return p
+}
+
+// Channels returns the number of channels of the stream being decoded, or 0
+// if its header hasn't been fed in yet.
+func (p *PushDecoder) Channels() int {
+	if p.state == expectHead {
+		return 0
+	}
+	return p.channels
+}
+
+// Feed passes in the next chunk of the stream, of any size, and returns the
+// frames of all packets completed by it. data is copied and may be reused by
+// the caller.
+//
+// If a stream's header packets can't be decoded, the error is returned and
+// the rest of that stream is skipped.
+func (p *PushDecoder) Feed(data []byte) ([]PCMFrame, error) {
+	p.in.Write(data)
+	var frames []PCMFrame
+	for {
+		page, err := peekOggPage(p.r)
+		if err != nil {
+			// Only the rest of the stream is missing, wait for it
+			return frames, nil
+		}
+		if !page.valid() {
+			// Not a real page, resync on the next capture pattern
+			p.r.Discard(1)
+			continue
+		}
+		frames, err = p.page(page, frames)
+		p.r.Discard(len(page.raw))
+		if err != nil {
+			return frames, err
+		}
+	}
+}
+
+// page decodes the packets completed on a page, appending their frames.
+func (p *PushDecoder) page(page *oggPage, frames []PCMFrame) ([]PCMFrame, error) {
+	if p.ended {
+		if page.flags&oggFlagBOS == 0 || oggCodec(page.raw[page.body:]) != "opus" {
+			// Not (the start of) an Opus stream
+			return frames, nil
+		}
+		p.serial = page.serial
+		p.state = expectHead
+		p.ended = false
+		p.decoded = 0
+		p.packet = p.packet[:0]
+	}
+	if page.serial != p.serial {
+		return frames, nil
+	}
+	first := len(frames)
+	var err error
+	p.packet, err = page.packets(p.packet, func(packet []byte) error {
+		frames, err = p.packetDone(packet, frames)
+		return err
+	})
+	if err != nil {
+		return frames, err
+	}
+	if page.flags&oggFlagEOS != 0 {
+		if granule := page.granule(); p.state == expectAudio && granule != oggNoGranule {
+			p.trim(frames[first:], p.decoded-GranuleSamplesAt(granule, p.sample_rate))
+		}
+		p.ended = true
+	}
+	return frames, nil
+}
+
+// trim removes excess samples from the end of the frames of the last page.
+func (p *PushDecoder) trim(last []PCMFrame, excess int64) {
+	for i := len(last) - 1; i >= 0 && excess > 0; i-- {
+		n := int64(last[i].Samples)
+		if n > excess {
+			n = excess
+		}
+		last[i].Samples -= int(n)
+		last[i].PCM = last[i].PCM[:last[i].Samples*p.channels]
+		excess -= n
+	}
+}
+
+func (p *PushDecoder) packetDone(packet []byte, frames []PCMFrame) ([]PCMFrame, error) {
+	switch p.state {
+	case expectHead:
+		if err := p.head(packet); err != nil {
+			// Skip the stream instead of failing on each of its pages
+			p.ended = true
+			return frames, err
+		}
+		p.state = expectTags
+	case expectTags:
+		if !bytes.HasPrefix(packet, []byte("OpusTags")) {
+			p.ended = true
+			return frames, fmt.Errorf("opus: expected OpusTags packet")
+		}
+		p.state = expectAudio
+	case expectAudio:
+		n, err := p.dec.Decode(packet, p.scratch)
+		if err != nil {
+			return frames, err
+		}
+		p.decoded += int64(n)
+		pcm := p.scratch[:n*p.channels]
+		if p.skip > 0 {
+			skip := p.skip
+			if skip > n {
+				skip = n
+			}
+			p.skip -= skip
+			pcm = pcm[skip*p.channels:]
+		}
+		frames = append(frames, PCMFrame{
+			PCM:     append([]int16(nil), pcm...),
+			Samples: len(pcm) / p.channels,
+		})
+	}
+	return frames, nil
+}
+
+// head sets up decoding from an OpusHead packet, RFC 7845 section 5.1.
+func (p *PushDecoder) head(packet []byte) error {
+	if len(packet) < 19 {
+		return fmt.Errorf("opus: OpusHead too short: %d bytes", len(packet))
+	}
+	if version := packet[8]; version>>4 != 0 {
+		return fmt.Errorf("opus: unsupported OpusHead version %d", version)
+	}
+	channels := int(packet[9])
+	if packet[18] != 0 || channels < 1 || channels > 2 {
+		return fmt.Errorf("opus: unsupported channel mapping family %d with %d channels", packet[18], channels)
+	}
+	if p.dec == nil || p.channels != channels {
+		dec, err := NewDecoder(p.sample_rate, channels)
+		if err != nil {
+			return err
+		}
+		p.dec = dec
+	} else if err := p.dec.reinit(); err != nil {
+		return err
+	}
+	p.channels = channels
+	p.skip = int(GranuleSamplesAt(int64(binary.LittleEndian.Uint16(packet[10:])), p.sample_rate))
+	p.scratch = make([]int16, int(GranuleSamplesAt(maxPacketSamples48k, p.sample_rate))*channels)
+	return nil
+}
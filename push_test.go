@@ -0,0 +1,108 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func pushDecode(t *testing.T, data []byte, chunk int) []int16 {
+	p := NewPushDecoder()
+	var pcm []int16
+	for i := 0; i < len(data); i += chunk {
+		end := i + chunk
+		if end > len(data) {
+			end = len(data)
+		}
+		frames, err := p.Feed(data[i:end])
+		if err != nil {
+			t.Fatalf("Error feeding data: %v", err)
+		}
+		for _, f := range frames {
+			if len(f.PCM) != f.Samples*p.Channels() {
+				t.Fatalf("Frame of %d samples has %d values", f.Samples, len(f.PCM))
+			}
+			pcm = append(pcm, f.PCM...)
+		}
+	}
+	return pcm
+}
+
+func TestPushDecoder(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/speech_8.opus")
+	if err != nil {
+		t.Fatalf("Error reading test file: %v", err)
+	}
+	whole := pushDecode(t, data, len(data))
+	if len(whole) == 0 {
+		t.Fatalf("No audio decoded")
+	}
+	for _, chunk := range []int{1, 7, 1500} {
+		if pcm := pushDecode(t, data, chunk); !reflect.DeepEqual(pcm, whole) {
+			t.Errorf("Decoding in chunks of %d bytes gave %d samples, expected %d", chunk, len(pcm), len(whole))
+		}
+	}
+	// Garbage before the stream is skipped
+	garbage := append([]byte("not Ogg at all, OggS?"), data...)
+	if pcm := pushDecode(t, garbage, 100); !reflect.DeepEqual(pcm, whole) {
+		t.Errorf("Expected garbage to be skipped, got %d samples instead of %d", len(pcm), len(whole))
+	}
+}
+
+func TestPushDecoderCorruptPage(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/speech_8.opus")
+	if err != nil {
+		t.Fatalf("Error reading test file: %v", err)
+	}
+	whole := pushDecode(t, data, len(data))
+	corrupt := append([]byte(nil), data...)
+	// Flip a byte in the body of some audio page in the middle
+	corrupt[len(corrupt)/2] ^= 0xff
+	pcm := pushDecode(t, corrupt, 512)
+	if len(pcm) == 0 || len(pcm) >= len(whole) {
+		t.Errorf("Expected one page to be dropped, got %d samples of %d", len(pcm), len(whole))
+	}
+}
+
+func TestPushDecoderBadHead(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/speech_8.opus")
+	if err != nil {
+		t.Fatalf("Error reading test file: %v", err)
+	}
+	whole := pushDecode(t, data, len(data))
+	// Chain a stream with an unsupported head in front of the good one
+	var chained []byte
+	for i, page := range oggPages(t, data) {
+		page = append([]byte(nil), page...)
+		binary.LittleEndian.PutUint32(page[14:], 0xbad)
+		if i == 0 {
+			page[oggHeaderSize+int(page[26])+9] = 3
+		}
+		fixCRC(page)
+		chained = append(chained, page...)
+	}
+	pages := oggPages(t, append(chained, data...))
+	p := NewPushDecoder()
+	var pcm []int16
+	errors := 0
+	for _, page := range pages {
+		frames, err := p.Feed(page)
+		if err != nil {
+			errors++
+		}
+		for _, f := range frames {
+			pcm = append(pcm, f.PCM...)
+		}
+	}
+	if errors != 1 {
+		t.Errorf("Expected the bad head to be reported once, got %d errors", errors)
+	}
+	if !reflect.DeepEqual(pcm, whole) {
+		t.Errorf("Expected the chained stream to be decoded, got %d samples instead of %d", len(pcm), len(whole))
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+)
+
+// MOSBand is a coarse rating of call quality, after the user satisfaction
+// categories of ITU-T G.107.
+type MOSBand int
+
+const (
+	// Nearly all users dissatisfied
+	MOSBad MOSBand = iota
+	// Many users dissatisfied
+	MOSPoor
+	// Some users dissatisfied
+	MOSFair
+	// Users satisfied
+	MOSGood
+	// Users very satisfied
+	MOSExcellent
+)
+
+var mosBandNames = map[MOSBand]string{
+	MOSBad:       "bad",
+	MOSPoor:      "poor",
+	MOSFair:      "fair",
+	MOSGood:      "good",
+	MOSExcellent: "excellent",
+}
+
+func (b MOSBand) String() string {
+	if name, ok := mosBandNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("MOSBand(%d)", int(b))
+}
+
+// MarshalText encodes the band by name, e.g. "good".
+func (b MOSBand) MarshalText() ([]byte, error) {
+	name, ok := mosBandNames[b]
+	if !ok {
+		return nil, fmt.Errorf("opus: unknown MOS band %d", int(b))
+	}
+	return []byte(name), nil
+}
+
+// Lower MOS bounds of the bands, from G.107 annex B.
+var mosBandBounds = []struct {
+	mos  float64
+	band MOSBand
+}{
+	{4.34, MOSExcellent},
+	{4.03, MOSGood},
+	{3.60, MOSFair},
+	{3.10, MOSPoor},
+}
+
+// Approximate MOS of Opus speech without loss by bitrate, read off published
+// listening tests (narrowband SILK at the bottom, fullband CELT at the top).
+var mosByBitrate = []struct {
+	bitrate int
+	mos     float64
+}{
+	{6000, 2.8},
+	{8000, 3.2},
+	{12000, 3.6},
+	{16000, 3.9},
+	{24000, 4.2},
+	{32000, 4.4},
+	{64000, 4.5},
+}
+
+// Packet loss robustness (Bpl in G.107 terms) of PLC alone and with in-band
+// FEC: the loss percentage at which half the quality above MOS 1 is lost.
+const (
+	robustnessPLC = 10.0
+	robustnessFEC = 25.0
+)
+
+// QualityEstimate is the result of EstimateQuality.
+type QualityEstimate struct {
+	MOS  float64 `json:"mos"`
+	Band MOSBand `json:"band"`
+}
+
+// EstimateQuality gives a rough estimate of the speech quality of an encoder
+// configuration under packet loss: the bitrate in bits per second, whether
+// in-band FEC is enabled, and the expected loss percentage. It is meant for
+// configuration UIs, to warn about combinations which will sound bad, not as
+// a measurement; for that, use Sweep on real audio.
+//
+// The model takes the MOS for the bitrate from published Opus quality curves
+// and applies the G.107 E-model loss impairment on top. FEC makes the encoder
+// more robust against loss, but spends part of the bitrate on redundancy.
+func EstimateQuality(bitrate int, fec bool, lossPerc int) QualityEstimate {
+	loss := float64(lossPerc)
+	if loss < 0 {
+		loss = 0
+	}
+	if loss > 100 {
+		loss = 100
+	}
+	robustness := robustnessPLC
+	if fec && loss > 0 {
+		robustness = robustnessFEC
+		// libopus lowers the primary bitrate as the expected loss goes up,
+		// to make room for the redundant copy.
+		share := 0.02 * loss
+		if share > 0.3 {
+			share = 0.3
+		}
+		bitrate = int(float64(bitrate) * (1 - share))
+	}
+	base := mosAtBitrate(bitrate)
+	mos := base - (base-1)*loss/(loss+robustness)
+	band := MOSBad
+	for _, b := range mosBandBounds {
+		if mos >= b.mos {
+			band = b.band
+			break
+		}
+	}
+	return QualityEstimate{MOS: mos, Band: band}
+}
+
+// mosAtBitrate interpolates mosByBitrate.
+func mosAtBitrate(bitrate int) float64 {
+	first, last := mosByBitrate[0], mosByBitrate[len(mosByBitrate)-1]
+	if bitrate <= first.bitrate {
+		return first.mos
+	}
+	if bitrate >= last.bitrate {
+		return last.mos
+	}
+	for i := 1; i < len(mosByBitrate); i++ {
+		hi := mosByBitrate[i]
+		if bitrate <= hi.bitrate {
+			lo := mosByBitrate[i-1]
+			f := float64(bitrate-lo.bitrate) / float64(hi.bitrate-lo.bitrate)
+			return lo.mos + f*(hi.mos-lo.mos)
+		}
+	}
+	return last.mos
+}
@@ -0,0 +1,56 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+)
+
+func TestEstimateQuality(t *testing.T) {
+	for _, tc := range []struct {
+		bitrate  int
+		fec      bool
+		lossPerc int
+		band     MOSBand
+	}{
+		{64000, false, 0, MOSExcellent},
+		{24000, false, 0, MOSGood},
+		{12000, false, 0, MOSFair},
+		{6000, false, 0, MOSBad},
+		{24000, false, 10, MOSBad},
+		{32000, true, 2, MOSGood},
+	} {
+		q := EstimateQuality(tc.bitrate, tc.fec, tc.lossPerc)
+		if q.Band != tc.band {
+			t.Errorf("%d bps, FEC %v, %d%% loss: expected %v, got %v (MOS %.2f)",
+				tc.bitrate, tc.fec, tc.lossPerc, tc.band, q.Band, q.MOS)
+		}
+	}
+}
+
+func TestEstimateQualityMonotonic(t *testing.T) {
+	for _, fec := range []bool{false, true} {
+		prev := EstimateQuality(32000, fec, 0).MOS
+		for loss := 1; loss <= 50; loss++ {
+			mos := EstimateQuality(32000, fec, loss).MOS
+			if mos > prev {
+				t.Errorf("FEC %v: MOS goes up from %.2f to %.2f at %d%% loss", fec, prev, mos, loss)
+			}
+			prev = mos
+		}
+	}
+	prev := 0.0
+	for bitrate := 4000; bitrate <= 80000; bitrate += 1000 {
+		mos := EstimateQuality(bitrate, false, 0).MOS
+		if mos < prev {
+			t.Errorf("MOS goes down from %.2f to %.2f at %d bps", prev, mos, bitrate)
+		}
+		prev = mos
+	}
+	// FEC pays off under loss
+	if EstimateQuality(32000, true, 10).MOS <= EstimateQuality(32000, false, 10).MOS {
+		t.Errorf("Expected FEC to improve quality at 10%% loss")
+	}
+}
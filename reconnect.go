@@ -0,0 +1,184 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// +build !nolibopusfile
+
+package opus
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Gap describes an interruption of the audio of a ReconnectingStream.
+type Gap struct {
+	// When the interruption was detected
+	Start time.Time
+	// How long it took until audio resumed. Zero for holes within a single
+	// connection, whose length libopusfile doesn't report.
+	Duration time.Duration
+	// The error which caused the interruption
+	Err error
+	// Number of connection attempts it took to recover
+	Attempts int
+}
+
+// ReconnectingStream decodes a live Ogg Opus stream from the network, e.g. an
+// Icecast radio station, and survives disconnects. When the connection drops
+// it reconnects with exponential backoff, decodes the new connection with a
+// fresh decoder (which synchronizes on the first Ogg page it finds), and
+// reports the gap. It is meant for long running recorders, so any end of the
+// connection, including a clean EOF, counts as a disconnect.
+//
+// Set the exported fields before the first call to Read.
+type ReconnectingStream struct {
+	// Called for every interruption, once audio resumes
+	OnGap func(Gap)
+	// Backoff between connection attempts, doubling from MinBackoff up to
+	// MaxBackoff. Default 500 ms and 30 s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Give up after this many consecutive failed connection attempts, and
+	// return the last error from Read. Zero means never give up.
+	MaxAttempts int
+
+	open   func() (io.ReadCloser, error)
+	stream *Stream
+	// The interruption being recovered from, if any
+	gap *Gap
+	// For tests
+	sleep func(time.Duration)
+}
+
+// NewReconnectingStream connects by calling open, and returns an error if that
+// first connection fails. Subsequent connections are made by calling open
+// again.
+func NewReconnectingStream(open func() (io.ReadCloser, error)) (*ReconnectingStream, error) {
+	s := &ReconnectingStream{
+		open:  open,
+		sleep: time.Sleep,
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ReconnectingStream) connect() error {
+	r, err := s.open()
+	if err != nil {
+		return err
+	}
+	stream, err := NewStream(r)
+	if err != nil {
+		r.Close()
+		return err
+	}
+	s.stream = stream
+	return nil
+}
+
+// reconnect drops the current connection and connects again, with backoff.
+func (s *ReconnectingStream) reconnect(cause error) error {
+	if s.stream != nil {
+		s.stream.Close()
+		s.stream = nil
+	}
+	if s.gap == nil {
+		s.gap = &Gap{Start: time.Now(), Err: cause}
+	}
+	backoff := s.MinBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+	max := s.MaxBackoff
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	for failed := 0; ; failed++ {
+		s.gap.Attempts++
+		err := s.connect()
+		if err == nil {
+			return nil
+		}
+		if s.MaxAttempts > 0 && failed+1 >= s.MaxAttempts {
+			return fmt.Errorf("opus: giving up reconnecting after %d attempts: %v", failed+1, err)
+		}
+		s.sleep(backoff)
+		if backoff *= 2; backoff > max {
+			backoff = max
+		}
+	}
+}
+
+// resumed reports the gap being recovered from, if any.
+func (s *ReconnectingStream) resumed() {
+	if s.gap == nil {
+		return
+	}
+	g := *s.gap
+	s.gap = nil
+	g.Duration = time.Since(g.Start)
+	if s.OnGap != nil {
+		s.OnGap(g)
+	}
+}
+
+func (s *ReconnectingStream) read(read func(*Stream) (int, error)) (int, error) {
+	for {
+		if s.stream == nil {
+			if err := s.reconnect(nil); err != nil {
+				return 0, err
+			}
+		}
+		n, err := read(s.stream)
+		switch {
+		case err == nil:
+			s.resumed()
+			return n, nil
+		case err == ErrStreamHole:
+			// Lost data within the connection: libopusfile carries on
+			// with the next page.
+			if s.OnGap != nil {
+				s.OnGap(Gap{Start: time.Now(), Err: err})
+			}
+		default:
+			if err := s.reconnect(err); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// Read decodes the next chunk of audio, like Stream.Read, reconnecting as
+// needed. It only returns an error once reconnecting fails for good.
+func (s *ReconnectingStream) Read(pcm []int16) (int, error) {
+	if len(pcm) == 0 {
+		return 0, nil
+	}
+	return s.read(func(stream *Stream) (int, error) {
+		return stream.Read(pcm)
+	})
+}
+
+// ReadFloat32 is the same as Read, but decodes to float32 instead of int16.
+func (s *ReconnectingStream) ReadFloat32(pcm []float32) (int, error) {
+	if len(pcm) == 0 {
+		return 0, nil
+	}
+	return s.read(func(stream *Stream) (int, error) {
+		return stream.ReadFloat32(pcm)
+	})
+}
+
+// Close closes the current connection.
+func (s *ReconnectingStream) Close() error {
+	if s.stream == nil {
+		return nil
+	}
+	err := s.stream.Close()
+	s.stream = nil
+	return err
+}
@@ -0,0 +1,67 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// +build !nolibopusfile
+
+package opus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestReconnectingStream(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/speech_8.opus")
+	if err != nil {
+		t.Fatalf("Error reading test file: %v", err)
+	}
+	// First connection drops halfway, then two failed attempts, then the
+	// whole stream.
+	attempt := 0
+	open := func() (io.ReadCloser, error) {
+		attempt++
+		switch attempt {
+		case 1:
+			return ioutil.NopCloser(bytes.NewReader(data[:len(data)/2])), nil
+		case 2, 3:
+			return nil, fmt.Errorf("connection refused")
+		case 4:
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		default:
+			return nil, fmt.Errorf("off the air")
+		}
+	}
+	s, err := NewReconnectingStream(open)
+	if err != nil {
+		t.Fatalf("Error creating stream: %v", err)
+	}
+	var slept []time.Duration
+	s.sleep = func(d time.Duration) { slept = append(slept, d) }
+	s.MaxAttempts = 3
+	var gaps []Gap
+	s.OnGap = func(g Gap) { gaps = append(gaps, g) }
+
+	pcm := make([]int16, 960)
+	var readErr error
+	for readErr == nil {
+		_, readErr = s.Read(pcm)
+	}
+	if attempt != 7 {
+		t.Errorf("Expected 7 connection attempts, got %d", attempt)
+	}
+	if len(gaps) != 1 {
+		t.Fatalf("Expected 1 gap, got %d: %+v", len(gaps), gaps)
+	}
+	if gaps[0].Attempts != 3 {
+		t.Errorf("Expected recovery after 3 attempts, got %d", gaps[0].Attempts)
+	}
+	want := []time.Duration{500 * time.Millisecond, time.Second, 500 * time.Millisecond, time.Second}
+	if fmt.Sprint(slept) != fmt.Sprint(want) {
+		t.Errorf("Unexpected backoff: %v, expected %v", slept, want)
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+)
+
+// DefaultRedundantBitrate is the bitrate of the secondary encoder of a
+// RedundantEncoder, unless changed through Secondary().SetBitrate.
+const DefaultRedundantBitrate = 12000
+
+// RED block header limits, from RFC 2198.
+const (
+	redMaxBlockLength = 1<<10 - 1
+	redMaxOffset      = 1<<14 - 1
+)
+
+// REDBlock is one block of a RED (RFC 2198) payload.
+type REDBlock struct {
+	PayloadType int
+	// Offset of the block's RTP timestamp before the packet's, in samples at
+	// 48 kHz. Zero for the primary block.
+	TimestampOffset int
+	Data            []byte
+}
+
+// ParseRED splits a RED payload into its blocks, redundant blocks first and the
+// primary block last. The data of the blocks points into the payload.
+func ParseRED(payload []byte) ([]REDBlock, error) {
+	var blocks []REDBlock
+	i := 0
+	for {
+		if i >= len(payload) {
+			return nil, fmt.Errorf("opus: RED payload truncated")
+		}
+		if payload[i]&0x80 == 0 {
+			blocks = append(blocks, REDBlock{PayloadType: int(payload[i] & 0x7f)})
+			i++
+			break
+		}
+		if i+4 > len(payload) {
+			return nil, fmt.Errorf("opus: RED payload truncated")
+		}
+		blocks = append(blocks, REDBlock{
+			PayloadType:     int(payload[i] & 0x7f),
+			TimestampOffset: int(payload[i+1])<<6 | int(payload[i+2])>>2,
+			Data:            make([]byte, int(payload[i+2]&0x03)<<8|int(payload[i+3])),
+		})
+		i += 4
+	}
+	for j := range blocks[:len(blocks)-1] {
+		size := len(blocks[j].Data)
+		if i+size > len(payload) {
+			return nil, fmt.Errorf("opus: RED block exceeds payload")
+		}
+		blocks[j].Data = payload[i : i+size]
+		i += size
+	}
+	blocks[len(blocks)-1].Data = payload[i:]
+	return blocks, nil
+}
+
+// RedundantEncoder encodes every frame twice: at full quality with the primary
+// encoder, and at a low bitrate with a secondary encoder. Each output packet
+// is a RED (RFC 2198) payload carrying the current primary frame and the
+// previous frame's low bitrate copy, so any single lost packet can be
+// recovered from the next one. Unlike in-band FEC, the copy doesn't depend on
+// the encoder's loss estimate and covers the whole frame.
+//
+// This is the "red/48000/2" payload format WebRTC uses for Opus. Decode the
+// packets with a RedundantReceiver, or any RED aware receiver.
+type RedundantEncoder struct {
+	primary     *Encoder
+	secondary   *Encoder
+	payloadType int
+	buf         []byte
+	// Previous frame's secondary encoding, and its duration at 48 kHz
+	prev        []byte
+	prevSamples int
+}
+
+// NewRedundantEncoder creates a RedundantEncoder. The payload type is the RTP
+// payload type of the Opus blocks inside the RED payload.
+func NewRedundantEncoder(sample_rate int, channels int, application Application, payload_type int) (*RedundantEncoder, error) {
+	if payload_type < 0 || payload_type > 127 {
+		return nil, fmt.Errorf("opus: invalid RTP payload type: %d", payload_type)
+	}
+	primary, err := NewEncoder(sample_rate, channels, application)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := NewEncoder(sample_rate, channels, application)
+	if err != nil {
+		return nil, err
+	}
+	if err := secondary.SetBitrate(DefaultRedundantBitrate); err != nil {
+		return nil, err
+	}
+	return &RedundantEncoder{
+		primary:     primary,
+		secondary:   secondary,
+		payloadType: payload_type,
+		buf:         make([]byte, 2*maxEncodedFrameSize),
+	}, nil
+}
+
+// Primary returns the full quality encoder, for configuration.
+func (r *RedundantEncoder) Primary() *Encoder {
+	return r.primary
+}
+
+// Secondary returns the low bitrate encoder, for configuration.
+func (r *RedundantEncoder) Secondary() *Encoder {
+	return r.secondary
+}
+
+// Encode encodes a frame and writes the RED payload to data. Returns the
+// number of bytes written.
+func (r *RedundantEncoder) Encode(pcm []int16, data []byte) (int, error) {
+	n1, err := r.primary.Encode(pcm, r.buf[:maxEncodedFrameSize])
+	if err != nil {
+		return 0, err
+	}
+	n2, err := r.secondary.Encode(pcm, r.buf[maxEncodedFrameSize:])
+	if err != nil {
+		return 0, err
+	}
+	return r.pack(len(pcm)/r.primary.channels, n1, n2, data)
+}
+
+// EncodeFloat32 is the same as Encode, but for float32 audio.
+func (r *RedundantEncoder) EncodeFloat32(pcm []float32, data []byte) (int, error) {
+	n1, err := r.primary.EncodeFloat32(pcm, r.buf[:maxEncodedFrameSize])
+	if err != nil {
+		return 0, err
+	}
+	n2, err := r.secondary.EncodeFloat32(pcm, r.buf[maxEncodedFrameSize:])
+	if err != nil {
+		return 0, err
+	}
+	return r.pack(len(pcm)/r.primary.channels, n1, n2, data)
+}
+
+// pack writes the previous redundant frame and the new primary frame (of n1
+// bytes in buf) as a RED payload, and keeps the new redundant frame (of n2
+// bytes) for the next packet.
+func (r *RedundantEncoder) pack(samples, n1, n2 int, data []byte) (int, error) {
+	primary := r.buf[:n1]
+	i := 0
+	// Leave out a redundant block which can't be described by the header,
+	// or which is a DTX frame not worth repeating.
+	withRed := len(r.prev) > 2 && len(r.prev) <= redMaxBlockLength && r.prevSamples <= redMaxOffset
+	size := 1 + len(primary)
+	if withRed {
+		size += 4 + len(r.prev)
+	}
+	if len(data) < size {
+		return 0, fmt.Errorf("opus: target buffer too small: RED payload needs %d bytes", size)
+	}
+	if withRed {
+		data[0] = 0x80 | byte(r.payloadType)
+		data[1] = byte(r.prevSamples >> 6)
+		data[2] = byte(r.prevSamples<<2) | byte(len(r.prev)>>8)
+		data[3] = byte(len(r.prev))
+		i = 4
+	}
+	data[i] = byte(r.payloadType)
+	i++
+	if withRed {
+		i += copy(data[i:], r.prev)
+	}
+	i += copy(data[i:], primary)
+	r.prev = append(r.prev[:0], r.buf[maxEncodedFrameSize:maxEncodedFrameSize+n2]...)
+	r.prevSamples = samples * 48000 / r.primary.sample_rate
+	return i, nil
+}
+
+// RedundantReceiver decodes the RED payloads of a RedundantEncoder, with one
+// packet of delay so a lost packet can be recovered from the redundant block
+// in the next one. It works like FECReceiver: every call to Decode passes in
+// the next payload (or nil if it was lost) and produces the audio for the
+// packet before it. Lost packets without a redundant copy are concealed with
+// PLC.
+type RedundantReceiver struct {
+	dec     *Decoder
+	held    []byte
+	holding bool
+}
+
+// NewRedundantReceiver creates a RedundantReceiver around the decoder. The
+// decoder must not be used directly while the receiver is in use.
+func NewRedundantReceiver(dec *Decoder) *RedundantReceiver {
+	return &RedundantReceiver{dec: dec}
+}
+
+func (r *RedundantReceiver) hold(data []byte) {
+	r.held = append(r.held[:0], data...)
+	if len(data) == 0 {
+		r.held = nil
+	}
+	r.holding = true
+}
+
+// primary returns the primary Opus frame of a held RED payload.
+func (r *RedundantReceiver) primary() ([]byte, error) {
+	blocks, err := ParseRED(r.held)
+	if err != nil {
+		return nil, err
+	}
+	return blocks[len(blocks)-1].Data, nil
+}
+
+// redundant returns the redundant copy of the previous frame in a RED payload,
+// nil if there is none.
+func redundant(payload []byte) []byte {
+	blocks, err := ParseRED(payload)
+	if err != nil || len(blocks) < 2 {
+		return nil
+	}
+	return blocks[len(blocks)-2].Data
+}
+
+// Decode passes in the next payload, or nil if it was lost, and decodes the
+// previous one into pcm. Returns the number of samples per channel written,
+// which is 0 for the very first payload.
+func (r *RedundantReceiver) Decode(data []byte, pcm []int16) (int, error) {
+	if !r.holding {
+		r.hold(data)
+		return 0, nil
+	}
+	n, err := r.decode(data, pcm)
+	if err != nil {
+		return 0, err
+	}
+	r.hold(data)
+	return n, nil
+}
+
+// Flush decodes the held back payload, if any, at the end of the stream.
+func (r *RedundantReceiver) Flush(pcm []int16) (int, error) {
+	if !r.holding {
+		return 0, nil
+	}
+	n, err := r.decode(nil, pcm)
+	if err != nil {
+		return 0, err
+	}
+	r.held = r.held[:0]
+	r.holding = false
+	return n, nil
+}
+
+func (r *RedundantReceiver) decode(next []byte, pcm []int16) (int, error) {
+	if r.held != nil {
+		frame, err := r.primary()
+		if err != nil {
+			return 0, err
+		}
+		return r.dec.Decode(frame, pcm)
+	}
+	if frame := redundant(next); frame != nil {
+		return r.dec.Decode(frame, pcm)
+	}
+	n, err := r.lostFrameSize()
+	if err != nil {
+		return 0, err
+	}
+	if cap(pcm) < n*r.dec.bufChannels() {
+		return 0, fmt.Errorf("opus: target buffer too small: lost packet needs %d samples per channel", n)
+	}
+	if err := r.dec.DecodePLC(pcm[: n*r.dec.bufChannels() : n*r.dec.bufChannels()]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DecodeFloat32 is the same as Decode, but for float32 audio.
+func (r *RedundantReceiver) DecodeFloat32(data []byte, pcm []float32) (int, error) {
+	if !r.holding {
+		r.hold(data)
+		return 0, nil
+	}
+	n, err := r.decodeFloat32(data, pcm)
+	if err != nil {
+		return 0, err
+	}
+	r.hold(data)
+	return n, nil
+}
+
+// FlushFloat32 is the same as Flush, but for float32 audio.
+func (r *RedundantReceiver) FlushFloat32(pcm []float32) (int, error) {
+	if !r.holding {
+		return 0, nil
+	}
+	n, err := r.decodeFloat32(nil, pcm)
+	if err != nil {
+		return 0, err
+	}
+	r.held = r.held[:0]
+	r.holding = false
+	return n, nil
+}
+
+func (r *RedundantReceiver) decodeFloat32(next []byte, pcm []float32) (int, error) {
+	if r.held != nil {
+		frame, err := r.primary()
+		if err != nil {
+			return 0, err
+		}
+		return r.dec.DecodeFloat32(frame, pcm)
+	}
+	if frame := redundant(next); frame != nil {
+		return r.dec.DecodeFloat32(frame, pcm)
+	}
+	n, err := r.lostFrameSize()
+	if err != nil {
+		return 0, err
+	}
+	if cap(pcm) < n*r.dec.bufChannels() {
+		return 0, fmt.Errorf("opus: target buffer too small: lost packet needs %d samples per channel", n)
+	}
+	if err := r.dec.DecodePLCFloat32(pcm[: n*r.dec.bufChannels() : n*r.dec.bufChannels()]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// lostFrameSize returns the number of samples per channel to conceal for a
+// lost packet.
+func (r *RedundantReceiver) lostFrameSize() (int, error) {
+	n, err := r.dec.LastPacketDuration()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		n = FrameSamples(r.dec.sample_rate, defaultLostFrame)
+	}
+	return n, nil
+}
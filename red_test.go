@@ -0,0 +1,146 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseRED(t *testing.T) {
+	payload := []byte{
+		0x80 | 111, 0x0f, 0x00 | 0x00, 3, // 960 samples back, 3 bytes
+		111,
+		1, 2, 3,
+		4, 5, 6, 7,
+	}
+	blocks, err := ParseRED(payload)
+	if err != nil {
+		t.Fatalf("Error parsing RED payload: %v", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+	if blocks[0].PayloadType != 111 || blocks[0].TimestampOffset != 960 || !bytes.Equal(blocks[0].Data, []byte{1, 2, 3}) {
+		t.Errorf("Wrong redundant block: %+v", blocks[0])
+	}
+	if blocks[1].PayloadType != 111 || blocks[1].TimestampOffset != 0 || !bytes.Equal(blocks[1].Data, []byte{4, 5, 6, 7}) {
+		t.Errorf("Wrong primary block: %+v", blocks[1])
+	}
+	if _, err := ParseRED(payload[:6]); err == nil {
+		t.Errorf("Expected error for truncated payload")
+	}
+}
+
+func TestRedundantEncoder(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const NUMBER_OF_FRAMES = 10
+	const LOST = 4
+	enc, err := NewRedundantEncoder(SAMPLE_RATE, 1, AppVoIP, 111)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	addSine(pcm, SAMPLE_RATE, G4)
+	var packets [][]byte
+	for i := 0; i < NUMBER_OF_FRAMES; i++ {
+		data := make([]byte, 2000)
+		n, err := enc.Encode(pcm[i*FRAME_SIZE:(i+1)*FRAME_SIZE], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		blocks, err := ParseRED(data[:n])
+		if err != nil {
+			t.Fatalf("Couldn't parse RED payload %d: %v", i, err)
+		}
+		if i > 0 && (len(blocks) != 2 || blocks[0].TimestampOffset != FRAME_SIZE) {
+			t.Errorf("Expected redundant block in payload %d, got %+v", i, blocks)
+		}
+		packets = append(packets, data[:n])
+	}
+	packets[LOST] = nil
+
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	r := NewRedundantReceiver(dec)
+	out := make([]int16, FRAME_SIZE)
+	total := 0
+	for i, p := range packets {
+		n, err := r.Decode(p, out)
+		if err != nil {
+			t.Fatalf("Couldn't decode packet %d: %v", i, err)
+		}
+		total += n
+	}
+	n, err := r.Flush(out)
+	if err != nil {
+		t.Fatalf("Couldn't flush: %v", err)
+	}
+	total += n
+	if total != FRAME_SIZE*NUMBER_OF_FRAMES {
+		t.Errorf("Expected %d samples, got %d", FRAME_SIZE*NUMBER_OF_FRAMES, total)
+	}
+	if stats := dec.ConcealStats(); stats.ConcealedSamples != 0 {
+		t.Errorf("Expected lost packet recovered from redundancy, got %d concealed samples", stats.ConcealedSamples)
+	}
+}
+
+func TestRedundantReceiverStereoOutput(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const NUMBER_OF_FRAMES = 6
+	const LOST = 2
+	enc, err := NewRedundantEncoder(SAMPLE_RATE, 1, AppVoIP, 111)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	addSine(pcm, SAMPLE_RATE, 440)
+	var packets [][]byte
+	for i := 0; i < NUMBER_OF_FRAMES; i++ {
+		data := make([]byte, 2000)
+		n, err := enc.Encode(pcm[i*FRAME_SIZE:(i+1)*FRAME_SIZE], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		packets = append(packets, data[:n])
+	}
+	// Two in a row: the first one has no redundant copy left and is concealed
+	packets[LOST] = nil
+	packets[LOST+1] = nil
+
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if err := dec.SetStereoOutput(true); err != nil {
+		t.Fatalf("Error enabling stereo output: %v", err)
+	}
+	r := NewRedundantReceiver(dec)
+	out := make([]int16, 2*FRAME_SIZE)
+	total := 0
+	for i, p := range packets {
+		n, err := r.Decode(p, out)
+		if err != nil {
+			t.Fatalf("Couldn't decode packet %d: %v", i, err)
+		}
+		total += n
+	}
+	n, err := r.Flush(out)
+	if err != nil {
+		t.Fatalf("Couldn't flush: %v", err)
+	}
+	total += n
+	if total != FRAME_SIZE*NUMBER_OF_FRAMES {
+		t.Errorf("Expected %d samples, got %d", FRAME_SIZE*NUMBER_OF_FRAMES, total)
+	}
+	if stats := dec.ConcealStats(); stats.ConcealedSamples != FRAME_SIZE {
+		t.Errorf("Expected %d concealed samples, got %d", FRAME_SIZE, stats.ConcealedSamples)
+	}
+}
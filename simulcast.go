@@ -0,0 +1,104 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SimulcastLayer is the encoding of one frame at one quality tier.
+type SimulcastLayer struct {
+	// Label of the tier, as passed to NewSimulcastEncoder
+	Label   string
+	Bitrate int
+	Data    []byte
+}
+
+// SimulcastEncoder encodes every frame at several bitrates at once, for SFUs
+// forwarding a different quality tier to every subscriber. Each tier has its
+// own independent encoder, so tiers can be switched between at any packet.
+type SimulcastEncoder struct {
+	labels   []string
+	bitrates []int
+	encs     []*Encoder
+	layers   []SimulcastLayer
+	buf      []byte
+}
+
+// NewSimulcastEncoder creates an encoder for the tiers given as label to
+// bitrate, e.g. {"low": 16000, "high": 64000}. Tiers are ordered by bitrate,
+// lowest first.
+func NewSimulcastEncoder(sample_rate int, channels int, application Application, tiers map[string]int) (*SimulcastEncoder, error) {
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("opus: no simulcast tiers")
+	}
+	s := &SimulcastEncoder{}
+	for label := range tiers {
+		s.labels = append(s.labels, label)
+	}
+	sort.Slice(s.labels, func(i, j int) bool {
+		bi, bj := tiers[s.labels[i]], tiers[s.labels[j]]
+		return bi < bj || bi == bj && s.labels[i] < s.labels[j]
+	})
+	for _, label := range s.labels {
+		bitrate := tiers[label]
+		enc, err := NewEncoder(sample_rate, channels, application)
+		if err != nil {
+			return nil, err
+		}
+		if err := enc.SetBitrate(bitrate); err != nil {
+			return nil, fmt.Errorf("opus: simulcast tier %q: %v", label, err)
+		}
+		s.bitrates = append(s.bitrates, bitrate)
+		s.encs = append(s.encs, enc)
+	}
+	s.layers = make([]SimulcastLayer, len(s.encs))
+	s.buf = make([]byte, len(s.encs)*maxEncodedFrameSize)
+	return s, nil
+}
+
+// Labels returns the labels of the tiers, lowest bitrate first.
+func (s *SimulcastEncoder) Labels() []string {
+	return append([]string(nil), s.labels...)
+}
+
+// Encoder returns the encoder of a tier, for configuration. Returns nil if
+// there is no tier with that label.
+func (s *SimulcastEncoder) Encoder(label string) *Encoder {
+	for i, l := range s.labels {
+		if l == label {
+			return s.encs[i]
+		}
+	}
+	return nil
+}
+
+// Encode encodes a frame at every tier. The returned layers, lowest bitrate
+// first, are only valid until the next call.
+func (s *SimulcastEncoder) Encode(pcm []int16) ([]SimulcastLayer, error) {
+	for i, enc := range s.encs {
+		data := s.buf[i*maxEncodedFrameSize : (i+1)*maxEncodedFrameSize]
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			return nil, err
+		}
+		s.layers[i] = SimulcastLayer{Label: s.labels[i], Bitrate: s.bitrates[i], Data: data[:n]}
+	}
+	return s.layers, nil
+}
+
+// EncodeFloat32 is the same as Encode, but for float32 audio.
+func (s *SimulcastEncoder) EncodeFloat32(pcm []float32) ([]SimulcastLayer, error) {
+	for i, enc := range s.encs {
+		data := s.buf[i*maxEncodedFrameSize : (i+1)*maxEncodedFrameSize]
+		n, err := enc.EncodeFloat32(pcm, data)
+		if err != nil {
+			return nil, err
+		}
+		s.layers[i] = SimulcastLayer{Label: s.labels[i], Bitrate: s.bitrates[i], Data: data[:n]}
+	}
+	return s.layers, nil
+}
@@ -0,0 +1,52 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+)
+
+func TestSimulcastEncoder(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const NUMBER_OF_FRAMES = 20
+	s, err := NewSimulcastEncoder(SAMPLE_RATE, 1, AppAudio, map[string]int{
+		"high": 96000,
+		"low":  12000,
+		"mid":  32000,
+	})
+	if err != nil {
+		t.Fatalf("Error creating new simulcast encoder: %v", err)
+	}
+	labels := s.Labels()
+	if len(labels) != 3 || labels[0] != "low" || labels[1] != "mid" || labels[2] != "high" {
+		t.Errorf("Expected tiers low, mid, high, got %v", labels)
+	}
+	if s.Encoder("mid") == nil || s.Encoder("none") != nil {
+		t.Errorf("Wrong encoder lookup by label")
+	}
+	pcm := make([]int16, FRAME_SIZE*NUMBER_OF_FRAMES)
+	addSine(pcm, SAMPLE_RATE, G4)
+	sizes := make([]int, 3)
+	for i := 0; i < NUMBER_OF_FRAMES; i++ {
+		layers, err := s.Encode(pcm[i*FRAME_SIZE : (i+1)*FRAME_SIZE])
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		for j, l := range layers {
+			if l.Label != labels[j] {
+				t.Errorf("Expected layer %d to be %q, got %q", j, labels[j], l.Label)
+			}
+			sizes[j] += len(l.Data)
+		}
+	}
+	if !(sizes[0] < sizes[1] && sizes[1] < sizes[2]) {
+		t.Errorf("Expected output size to grow with bitrate, got %v", sizes)
+	}
+	if _, err := NewSimulcastEncoder(SAMPLE_RATE, 1, AppAudio, nil); err == nil {
+		t.Errorf("Expected error for no tiers")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"reflect"
+	"strings"
+)
+
+// EncoderSnapshot captures every setting of an encoder at a point in time:
+// the configurable ones from EncoderConfig, expert settings included, as well
+// as the fixed parameters it was initialized with. Settings added to
+// EncoderConfig show up here, and in Diff, automatically.
+type EncoderSnapshot struct {
+	EncoderConfig
+	SampleRate int `json:"sample_rate"`
+	Channels   int `json:"channels"`
+}
+
+// Snapshot returns the current values of all encoder settings.
+func (enc *Encoder) Snapshot() (EncoderSnapshot, error) {
+	var snap EncoderSnapshot
+	cfg, err := ConfigOf(enc)
+	if err != nil {
+		return snap, err
+	}
+	snap.EncoderConfig = cfg
+	snap.SampleRate, err = enc.SampleRate()
+	if err != nil {
+		return snap, err
+	}
+	snap.Channels = enc.channels
+	return snap, nil
+}
+
+// SettingDiff is a single setting which differs between two snapshots.
+type SettingDiff struct {
+	// Name of the setting, as used in the JSON encoding of a snapshot
+	Name string
+	A    interface{}
+	B    interface{}
+}
+
+// Diff lists all settings which differ between two snapshots, e.g. to log
+// exactly which codec parameters differed between experiment cohorts. The
+// result is empty if the snapshots are identical.
+func Diff(a, b EncoderSnapshot) []SettingDiff {
+	return diffStruct(reflect.ValueOf(a), reflect.ValueOf(b), nil)
+}
+
+func diffStruct(a, b reflect.Value, diffs []SettingDiff) []SettingDiff {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			diffs = diffStruct(a.Field(i), b.Field(i), diffs)
+			continue
+		}
+		va, vb := a.Field(i).Interface(), b.Field(i).Interface()
+		if va == vb {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		diffs = append(diffs, SettingDiff{Name: name, A: va, B: vb})
+	}
+	return diffs
+}
@@ -0,0 +1,84 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncoderSnapshot(t *testing.T) {
+	enc, err := NewEncoder(16000, 2, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	a, err := enc.Snapshot()
+	if err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	if a.SampleRate != 16000 || a.Channels != 2 || a.Application != AppVoIP {
+		t.Errorf("Unexpected snapshot: %+v", a)
+	}
+	if diffs := Diff(a, a); len(diffs) != 0 {
+		t.Errorf("Expected no differences, got %v", diffs)
+	}
+	if err := enc.SetComplexity(2); err != nil {
+		t.Fatalf("Error setting complexity: %v", err)
+	}
+	if err := enc.SetDTX(true); err != nil {
+		t.Fatalf("Error setting dtx: %v", err)
+	}
+	b, err := enc.Snapshot()
+	if err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	diffs := Diff(a, b)
+	if len(diffs) != 2 {
+		t.Fatalf("Expected 2 differences, got %v", diffs)
+	}
+	if diffs[0].Name != "complexity" || diffs[0].B != 2 {
+		t.Errorf("Unexpected first difference: %+v", diffs[0])
+	}
+	if diffs[1].Name != "dtx" || diffs[1].A != false || diffs[1].B != true {
+		t.Errorf("Unexpected second difference: %+v", diffs[1])
+	}
+}
+
+func TestEncoderSnapshotExpert(t *testing.T) {
+	enc, err := NewEncoder(48000, 2, AppAudio)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	a, err := enc.Snapshot()
+	if err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	if err := enc.SetVBR(false); err != nil {
+		t.Fatalf("Error setting vbr: %v", err)
+	}
+	if err := enc.SetSignal(SignalMusic); err != nil {
+		t.Fatalf("Error setting signal: %v", err)
+	}
+	if err := enc.SetLSBDepth(16); err != nil {
+		t.Fatalf("Error setting lsb depth: %v", err)
+	}
+	if err := enc.SetExpertFrameDuration(FrameDuration10ms); err != nil {
+		t.Fatalf("Error setting expert frame duration: %v", err)
+	}
+	b, err := enc.Snapshot()
+	if err != nil {
+		t.Fatalf("Error taking snapshot: %v", err)
+	}
+	diffs := Diff(a, b)
+	expected := []SettingDiff{
+		{Name: "cbr", A: false, B: true},
+		{Name: "signal", A: Signal(0), B: SignalMusic},
+		{Name: "lsb_depth", A: 0, B: 16},
+		{Name: "expert_frame_duration", A: FrameDuration(0), B: FrameDuration10ms},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("Expected differences %v, got %v", expected, diffs)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// SpectrumFunc receives the magnitude spectrum of one analysis window of
+// decoded audio, e.g. to draw a spectrogram. There are window/2+1 bins; bin i
+// is at i*sample_rate/window Hz. Magnitudes are relative to full scale: a full
+// scale sine gives about 1 in its bin. The slice is only valid during the call.
+type SpectrumFunc func(magnitudes []float64)
+
+// spectrumTap computes the magnitude spectrum of the (downmixed) PCM passing
+// through it with a Hann window, every hop samples. A nil tap does nothing.
+type spectrumTap struct {
+	f        SpectrumFunc
+	channels int
+	hop      int
+	// Samples of the current window so far
+	buf []float64
+	// Samples still to skip when hop is larger than the window
+	skip    int
+	window  []float64
+	twiddle []complex128
+	fft     []complex128
+	mags    []float64
+	// Scale to full scale: 2 over the sum of the window
+	scale float64
+}
+
+func newSpectrumTap(channels, window, hop int, f SpectrumFunc) (*spectrumTap, error) {
+	if window < 2 || window&(window-1) != 0 {
+		return nil, fmt.Errorf("opus: spectrum window must be a power of two, got %d", window)
+	}
+	if hop <= 0 {
+		return nil, fmt.Errorf("opus: invalid spectrum hop: %d", hop)
+	}
+	t := &spectrumTap{
+		f:        f,
+		channels: channels,
+		hop:      hop,
+		buf:      make([]float64, 0, window),
+		window:   make([]float64, window),
+		twiddle:  make([]complex128, window/2),
+		fft:      make([]complex128, window),
+		mags:     make([]float64, window/2+1),
+	}
+	var sum float64
+	for i := range t.window {
+		t.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(window))
+		sum += t.window[i]
+	}
+	t.scale = 2 / sum
+	for i := range t.twiddle {
+		t.twiddle[i] = cmplx.Exp(complex(0, -2*math.Pi*float64(i)/float64(window)))
+	}
+	return t, nil
+}
+
+func (t *spectrumTap) add(pcm []int16, samples int) {
+	if t == nil {
+		return
+	}
+	for i := 0; i < samples; i++ {
+		var v float64
+		for _, s := range pcm[i*t.channels : (i+1)*t.channels] {
+			v += float64(s)
+		}
+		t.sample(v / 32768 / float64(t.channels))
+	}
+}
+
+func (t *spectrumTap) addFloat32(pcm []float32, samples int) {
+	if t == nil {
+		return
+	}
+	for i := 0; i < samples; i++ {
+		var v float64
+		for _, s := range pcm[i*t.channels : (i+1)*t.channels] {
+			v += float64(s)
+		}
+		t.sample(v / float64(t.channels))
+	}
+}
+
+func (t *spectrumTap) sample(v float64) {
+	if t.skip > 0 {
+		t.skip--
+		return
+	}
+	t.buf = append(t.buf, v)
+	if len(t.buf) < cap(t.buf) {
+		return
+	}
+	t.analyze()
+	if t.hop >= len(t.buf) {
+		t.skip = t.hop - len(t.buf)
+		t.buf = t.buf[:0]
+	} else {
+		t.buf = t.buf[:copy(t.buf, t.buf[t.hop:])]
+	}
+}
+
+// analyze computes the spectrum of the full window in buf and hands it to f.
+func (t *spectrumTap) analyze() {
+	n := len(t.fft)
+	// Load in bit reversed order for an in place radix-2 FFT
+	bits := 0
+	for 1<<bits < n {
+		bits++
+	}
+	for i, v := range t.buf {
+		j := 0
+		for b := 0; b < bits; b++ {
+			j |= (i >> b & 1) << (bits - 1 - b)
+		}
+		t.fft[j] = complex(v*t.window[i], 0)
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := n / size
+		for start := 0; start < n; start += size {
+			for k := 0; k < size/2; k++ {
+				w := t.twiddle[k*step] * t.fft[start+k+size/2]
+				u := t.fft[start+k]
+				t.fft[start+k] = u + w
+				t.fft[start+k+size/2] = u - w
+			}
+		}
+	}
+	for i := range t.mags {
+		t.mags[i] = cmplx.Abs(t.fft[i]) * t.scale
+	}
+	t.f(t.mags)
+}
@@ -0,0 +1,60 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+)
+
+func TestSpectrumTap(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const WINDOW = 1024
+	const HOP = 512
+	// Exactly on bin 40
+	const FREQ = 40.0 * SAMPLE_RATE / WINDOW
+	pcm := make([]float32, 2*WINDOW*4)
+	addSineFloat32(pcm, SAMPLE_RATE, FREQ)
+	// Same signal on both channels
+	stereo := make([]float32, 2*len(pcm))
+	for i, s := range pcm {
+		stereo[2*i] = s
+		stereo[2*i+1] = s
+	}
+	var spectra int
+	tap, err := newSpectrumTap(2, WINDOW, HOP, func(mags []float64) {
+		spectra++
+		if len(mags) != WINDOW/2+1 {
+			t.Fatalf("Expected %d bins, got %d", WINDOW/2+1, len(mags))
+		}
+		peak := 0
+		for i, m := range mags {
+			if m > mags[peak] {
+				peak = i
+			}
+		}
+		if peak != 40 || mags[peak] < 0.9 || mags[peak] > 1.1 {
+			t.Errorf("Expected full scale peak in bin 40, got %f in bin %d", mags[peak], peak)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Error creating spectrum tap: %v", err)
+	}
+	tap.addFloat32(stereo, len(pcm))
+	if expected := (len(pcm)-WINDOW)/HOP + 1; spectra != expected {
+		t.Errorf("Expected %d spectra, got %d", expected, spectra)
+	}
+
+	// Hop larger than the window skips samples
+	spectra = 0
+	tap, _ = newSpectrumTap(2, WINDOW, 3*WINDOW, func([]float64) { spectra++ })
+	tap.addFloat32(stereo, len(pcm))
+	if spectra != 3 {
+		t.Errorf("Expected 3 spectra with a large hop, got %d", spectra)
+	}
+
+	if _, err := newSpectrumTap(1, 1000, HOP, func([]float64) {}); err == nil {
+		t.Errorf("Expected error for a window which isn't a power of two")
+	}
+}
@@ -9,6 +9,7 @@ package opus
 import (
 	"fmt"
 	"io"
+	"time"
 	"unsafe"
 )
 
@@ -38,6 +39,14 @@ type Stream struct {
 	read    io.Reader
 	// Preallocated buffer to pass to the reader
 	buf []byte
+	// Error of the last failed read, to return instead of libopusfile's
+	// generic read error
+	readErr  error
+	levels   *levelMeter
+	spectrum *spectrumTap
+	// See SetMonoOutput and SetStereoOutput
+	mono   bool
+	stereo bool
 }
 
 var streams = newStreamsMap()
@@ -65,6 +74,7 @@ func go_readcallback(p unsafe.Pointer, cbuf *C.uchar, cmaxbytes C.int) C.int {
 		if err == io.EOF {
 			return 0
 		} else {
+			stream.readErr = err
 			return -1
 		}
 	}
@@ -82,10 +92,29 @@ func NewStream(read io.Reader) (*Stream, error) {
 	return &s, nil
 }
 
+// NewStreamSerial is like NewStream, but decodes the logical stream with the
+// given serial number from an Ogg container multiplexing several, see
+// ListOggStreams. Pages of all other logical streams are skipped.
+func NewStreamSerial(read io.Reader, serial uint32) (*Stream, error) {
+	return NewStream(newOggFilter(read, serial))
+}
+
+// NewStreamAux is like NewStream, but hands every packet of the other logical
+// streams in the Ogg container (e.g. Ogg Skeleton, subtitles) to aux, so the
+// auxiliary data doesn't need a second parser over the same input. The first
+// Opus logical stream is decoded. aux is called while the stream reads its
+// input, i.e. from Init and Read, and must not use the stream.
+func NewStreamAux(read io.Reader, aux OggPacketFunc) (*Stream, error) {
+	f := newOggFilter(read, 0)
+	f.auto = true
+	f.aux = aux
+	return NewStream(f)
+}
+
 // Init initializes a stream with an io.Reader to fetch opus encoded data from
-// on demand. Errors from the reader are all transformed to an EOF, any actual
-// error information is lost. The same happens when a read returns succesfully,
-// but with zero bytes.
+// on demand. Errors from the reader other than io.EOF are returned by Init and
+// Read as is. A read which returns succesfully, but with zero bytes, is taken
+// as an EOF.
 func (s *Stream) Init(read io.Reader) error {
 	if s.oggfile != nil {
 		return fmt.Errorf("opus stream is already initialized")
@@ -108,12 +137,36 @@ func (s *Stream) Init(read io.Reader) error {
 	defer streams.Del(s)
 	oggfile := C.my_open_callbacks(C.uintptr_t(s.id), &errno)
 	if errno != 0 {
-		return StreamError(errno)
+		return s.error(int(errno))
 	}
 	s.oggfile = oggfile
 	return nil
 }
 
+// error returns the error of the failed read that made libopusfile fail, if
+// any, or the libopusfile error otherwise.
+func (s *Stream) error(code int) error {
+	if err := s.readErr; err != nil {
+		s.readErr = nil
+		return err
+	}
+	return StreamError(code)
+}
+
+// SetReadDeadline sets the read deadline of the underlying reader, e.g. a
+// net.Conn, for live streams over the network. A Read which can't get data
+// in time fails with the reader's timeout error (for net.Conn one matching
+// os.ErrDeadlineExceeded) instead of io.EOF, so players can tell a stalled
+// stream from its end and show that they're buffering. Read can be called
+// again after a timeout.
+func (s *Stream) SetReadDeadline(t time.Time) error {
+	d, ok := s.read.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return fmt.Errorf("opus: stream reader doesn't support deadlines")
+	}
+	return d.SetReadDeadline(t)
+}
+
 // Read a chunk of raw opus data from the stream and decode it. Returns the
 // number of decoded samples per channel. This means that a dual channel
 // (stereo) feed will have twice as many samples as the value returned.
@@ -133,17 +186,26 @@ func (s *Stream) Read(pcm []int16) (int, error) {
 	}
 	streams.Save(s)
 	defer streams.Del(s)
+	var li C.int
 	n := C.op_read(
 		s.oggfile,
 		(*C.opus_int16)(&pcm[0]),
-		C.int(len(pcm)),
-		nil)
+		C.int(s.readSize(len(pcm))),
+		&li)
 	if n < 0 {
-		return 0, StreamError(n)
+		return 0, s.error(int(n))
 	}
 	if n == 0 {
 		return 0, io.EOF
 	}
+	s.levels.add(pcm, int(n))
+	s.spectrum.add(pcm, int(n))
+	channels := int(C.op_channel_count(s.oggfile, li))
+	if s.mono {
+		downmix(pcm, int(n), channels)
+	} else if s.stereo && channels == 1 {
+		upmix(pcm, int(n))
+	}
 	return int(n), nil
 }
 
@@ -157,20 +219,120 @@ func (s *Stream) ReadFloat32(pcm []float32) (int, error) {
 	}
 	streams.Save(s)
 	defer streams.Del(s)
+	var li C.int
 	n := C.op_read_float(
 		s.oggfile,
 		(*C.float)(&pcm[0]),
-		C.int(len(pcm)),
-		nil)
+		C.int(s.readSize(len(pcm))),
+		&li)
 	if n < 0 {
-		return 0, StreamError(n)
+		return 0, s.error(int(n))
 	}
 	if n == 0 {
 		return 0, io.EOF
 	}
+	s.levels.addFloat32(pcm, int(n))
+	s.spectrum.addFloat32(pcm, int(n))
+	channels := int(C.op_channel_count(s.oggfile, li))
+	if s.mono {
+		downmixFloat32(pcm, int(n), channels)
+	} else if s.stereo && channels == 1 {
+		upmixFloat32(pcm, int(n))
+	}
 	return int(n), nil
 }
 
+// SetLevelMeter makes the stream report the RMS and peak level of every
+// channel of its output to f, once per period of decoded audio. The levels are
+// computed on the samples as they are read, without a second pass over the
+// PCM. A nil f turns metering off.
+func (s *Stream) SetLevelMeter(period time.Duration, f LevelFunc) error {
+	if s.oggfile == nil {
+		return fmt.Errorf("opus stream is uninitialized or already closed")
+	}
+	if f == nil {
+		s.levels = nil
+		return nil
+	}
+	// libopusfile always decodes at 48 kHz
+	samples := FrameSamples(GranuleSampleRate, period)
+	if samples <= 0 {
+		return fmt.Errorf("opus: invalid level meter period: %v", period)
+	}
+	channels := int(C.op_channel_count(s.oggfile, -1))
+	s.levels = newLevelMeter(channels, samples, f)
+	return nil
+}
+
+// SetSpectrumTap makes the stream compute the magnitude spectrum of its
+// output, downmixed to mono, and pass it to f. Each analysis covers window
+// samples (a power of two, e.g. 1024) and a new one starts every hop samples,
+// at 48 kHz. A nil f turns the tap off.
+func (s *Stream) SetSpectrumTap(window int, hop int, f SpectrumFunc) error {
+	if s.oggfile == nil {
+		return fmt.Errorf("opus stream is uninitialized or already closed")
+	}
+	if f == nil {
+		s.spectrum = nil
+		return nil
+	}
+	t, err := newSpectrumTap(int(C.op_channel_count(s.oggfile, -1)), window, hop, f)
+	if err != nil {
+		return err
+	}
+	s.spectrum = t
+	return nil
+}
+
+// SetMonoOutput makes Read and ReadFloat32 always write mono, averaging the
+// channels of stereo and multichannel streams. The buffer must still have room
+// for all channels of the stream. Level meters and spectrum taps see the
+// audio before the downmix.
+func (s *Stream) SetMonoOutput(enable bool) error {
+	if s.oggfile == nil {
+		return fmt.Errorf("opus stream is uninitialized or already closed")
+	}
+	if enable && s.stereo {
+		return fmt.Errorf("opus: mono and stereo output can't be combined")
+	}
+	s.mono = enable
+	return nil
+}
+
+// SetStereoOutput makes Read and ReadFloat32 write mono streams as stereo,
+// with the same audio on both channels, for playback devices which only take
+// stereo. The buffer must have room for two channels, and the returned sample
+// counts stay per channel. Has no effect on streams with more channels. Level
+// meters and spectrum taps see the audio before the upmix.
+func (s *Stream) SetStereoOutput(enable bool) error {
+	if s.oggfile == nil {
+		return fmt.Errorf("opus stream is uninitialized or already closed")
+	}
+	if enable && s.mono {
+		return fmt.Errorf("opus: mono and stereo output can't be combined")
+	}
+	s.stereo = enable
+	return nil
+}
+
+// readSize returns how many values of a buffer of the given length
+// libopusfile may fill, leaving room for the upmix.
+func (s *Stream) readSize(length int) int {
+	if s.stereo && C.op_channel_count(s.oggfile, -1) == 1 {
+		return length / 2
+	}
+	return length
+}
+
+// Serial returns the serial number of the logical stream currently being
+// decoded.
+func (s *Stream) Serial() (uint32, error) {
+	if s.oggfile == nil {
+		return 0, fmt.Errorf("opus stream is uninitialized or already closed")
+	}
+	return uint32(C.op_serialno(s.oggfile, -1)), nil
+}
+
 func (s *Stream) Close() error {
 	if s.oggfile == nil {
 		return fmt.Errorf("opus stream is uninitialized or already closed")
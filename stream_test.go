@@ -7,13 +7,17 @@
 package opus
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestStreamIllegal(t *testing.T) {
@@ -132,3 +136,193 @@ func TestCloser(t *testing.T) {
 		t.Error("Expected opus stream to call .Close on the reader")
 	}
 }
+
+func TestStreamSerial(t *testing.T) {
+	data, serial, other := muxSpeech(t)
+	expected := opus2pcm(t, "testdata/speech_8.opus", 10000)
+	for _, s := range []uint32{serial, other} {
+		stream, err := NewStreamSerial(bytes.NewReader(data), s)
+		if err != nil {
+			t.Fatalf("Error creating stream for serial %d: %v", s, err)
+		}
+		got, err := stream.Serial()
+		if err != nil {
+			t.Fatalf("Error getting serial: %v", err)
+		}
+		if got != s {
+			t.Errorf("Expected serial %d, got %d", s, got)
+		}
+		pcm := readStreamPcm(t, stream, 10000)
+		if !reflect.DeepEqual(pcm, expected) {
+			t.Errorf("Decoded stream %d differs from original", s)
+		}
+		stream.Close()
+	}
+}
+
+func TestStreamAux(t *testing.T) {
+	data, _, other := muxSpeech(t)
+	aux := map[uint32]int{}
+	stream, err := NewStreamAux(bytes.NewReader(data), func(serial uint32, packet []byte) {
+		aux[serial]++
+	})
+	if err != nil {
+		t.Fatalf("Error creating stream: %v", err)
+	}
+	defer stream.Close()
+	pcm := readStreamPcm(t, stream, 10000)
+	if !reflect.DeepEqual(pcm, opus2pcm(t, "testdata/speech_8.opus", 10000)) {
+		t.Errorf("Decoded stream differs from original")
+	}
+	if aux[other] == 0 || len(aux) != 2 {
+		t.Errorf("Expected packets of the Skeleton and second Opus stream, got %v", aux)
+	}
+}
+
+func TestStreamReadDeadline(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/speech_8.opus")
+	if err != nil {
+		t.Fatalf("Error reading test file: %v", err)
+	}
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	// Send part of the stream, then stall
+	go server.Write(data[:len(data)/2])
+	stream, err := NewStream(client)
+	if err != nil {
+		t.Fatalf("Error creating stream: %v", err)
+	}
+	if err := stream.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+		t.Fatalf("Error setting deadline: %v", err)
+	}
+	pcm := make([]int16, 10000)
+	for {
+		_, err = stream.Read(pcm)
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	file, err := NewStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error creating stream: %v", err)
+	}
+	if err := file.SetReadDeadline(time.Now()); err == nil {
+		t.Errorf("Expected error setting deadline on a reader without deadlines")
+	}
+}
+
+func TestStreamLevelMeter(t *testing.T) {
+	f := mustOpenFile(t, "testdata/speech_8.opus")
+	defer f.Close()
+	s, err := NewStream(f)
+	if err != nil {
+		t.Fatalf("Error creating stream: %v", err)
+	}
+	var samples, loud int
+	err = s.SetLevelMeter(100*time.Millisecond, func(e LevelEvent) {
+		if len(e.RMS) != 1 || len(e.Peak) != 1 {
+			t.Fatalf("Expected levels for 1 channel, got %d", len(e.RMS))
+		}
+		samples += e.Samples
+		if e.Peak[0] > 0.01 {
+			loud++
+		}
+	})
+	if err != nil {
+		t.Fatalf("Error setting level meter: %v", err)
+	}
+	pcm := make([]int16, 10000)
+	var total int
+	for {
+		n, err := s.Read(pcm)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading stream: %v", err)
+		}
+		total += n
+	}
+	if samples == 0 || samples > total || total-samples >= 4800 {
+		t.Errorf("Level events cover %d of %d samples", samples, total)
+	}
+	if loud == 0 {
+		t.Errorf("Expected some events with speech in them")
+	}
+}
+
+// The Stream API of upstream github.com/hraban/opus, see api_test.go.
+var (
+	_ func(io.Reader) (*Stream, error)      = NewStream
+	_ func(*Stream, io.Reader) error        = (*Stream).Init
+	_ func(*Stream, []int16) (int, error)   = (*Stream).Read
+	_ func(*Stream, []float32) (int, error) = (*Stream).ReadFloat32
+	_ func(*Stream) error                   = (*Stream).Close
+	_ error                                 = ErrStreamBadTimestamp
+)
+
+func TestStreamMonoOutput(t *testing.T) {
+	f := mustOpenFile(t, "testdata/speech_8.opus")
+	defer f.Close()
+	s, err := NewStream(f)
+	if err != nil {
+		t.Fatalf("Error creating stream: %v", err)
+	}
+	if err := s.SetMonoOutput(true); err != nil {
+		t.Fatalf("Error enabling mono output: %v", err)
+	}
+	var pcm []int16
+	buf := make([]int16, 10000)
+	for {
+		n, err := s.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading stream: %v", err)
+		}
+		pcm = append(pcm, buf[:n]...)
+	}
+	// The file is mono already, so nothing changes
+	if !reflect.DeepEqual(pcm, opus2pcm(t, "testdata/speech_8.opus", 10000)) {
+		t.Errorf("Mono output of a mono stream differs from normal output")
+	}
+}
+
+func TestStreamStereoOutput(t *testing.T) {
+	f := mustOpenFile(t, "testdata/speech_8.opus")
+	defer f.Close()
+	s, err := NewStream(f)
+	if err != nil {
+		t.Fatalf("Error creating stream: %v", err)
+	}
+	if err := s.SetStereoOutput(true); err != nil {
+		t.Fatalf("Error enabling stereo output: %v", err)
+	}
+	var pcm []int16
+	buf := make([]int16, 10000)
+	for {
+		n, err := s.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading stream: %v", err)
+		}
+		pcm = append(pcm, buf[:2*n]...)
+	}
+	mono := opus2pcm(t, "testdata/speech_8.opus", 10000)
+	if len(pcm) != 2*len(mono) {
+		t.Fatalf("Expected %d stereo samples, got %d", 2*len(mono), len(pcm))
+	}
+	for i, s := range mono {
+		if pcm[2*i] != s || pcm[2*i+1] != s {
+			t.Fatalf("Sample %d is %d/%d, expected %d on both channels", i, pcm[2*i], pcm[2*i+1], s)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SweepOptions lists the encoder settings to try in Sweep. Every combination
+// of bitrate, complexity and frame duration is tried.
+type SweepOptions struct {
+	Application    Application
+	Bitrates       []int
+	Complexities   []int
+	FrameDurations []time.Duration
+}
+
+// SweepResult is the outcome of encoding and decoding a sample with one
+// combination of settings.
+type SweepResult struct {
+	Bitrate       int           `json:"bitrate"`
+	Complexity    int           `json:"complexity"`
+	FrameDuration time.Duration `json:"frame_duration"`
+	// Actual bitrate of the encoded audio, in bits per second
+	OutBitrate float64 `json:"out_bitrate"`
+	// Mean SNR over 20 ms segments of the decoded audio, in dB. A crude,
+	// non-perceptual measure: good for comparing settings against each other
+	// on the same sample, not as an absolute judgement of quality.
+	SegmentalSNR float64 `json:"segmental_snr"`
+	// Encode and decode speed, in multiples of real time
+	EncodeSpeed float64 `json:"encode_speed"`
+	DecodeSpeed float64 `json:"decode_speed"`
+}
+
+// Sweep encodes and decodes a PCM sample with every combination of settings
+// in opts, and measures the resulting bitrate, quality and CPU cost. Run it on
+// representative content (speech, music, mixed) to pick settings per content
+// type. Audio beyond the last whole frame is ignored.
+func Sweep(pcm []int16, sample_rate int, channels int, opts SweepOptions) ([]SweepResult, error) {
+	if len(opts.Bitrates) == 0 || len(opts.Complexities) == 0 || len(opts.FrameDurations) == 0 {
+		return nil, fmt.Errorf("opus: sweep needs at least one bitrate, complexity and frame duration")
+	}
+	var results []SweepResult
+	for _, b := range opts.Bitrates {
+		for _, c := range opts.Complexities {
+			for _, f := range opts.FrameDurations {
+				r, err := sweepOne(pcm, sample_rate, channels, opts.Application, b, c, f)
+				if err != nil {
+					return nil, fmt.Errorf("opus: sweep at %d bps, complexity %d, %v frames: %v", b, c, f, err)
+				}
+				results = append(results, r)
+			}
+		}
+	}
+	return results, nil
+}
+
+// PickBitrate returns the result with the lowest output bitrate whose
+// segmental SNR is at least minSNR. Returns false if none qualifies.
+func PickBitrate(results []SweepResult, minSNR float64) (SweepResult, bool) {
+	var best SweepResult
+	found := false
+	for _, r := range results {
+		if r.SegmentalSNR >= minSNR && (!found || r.OutBitrate < best.OutBitrate) {
+			best = r
+			found = true
+		}
+	}
+	return best, found
+}
+
+func sweepOne(pcm []int16, sample_rate, channels int, app Application, bitrate, complexity int, frame time.Duration) (SweepResult, error) {
+	res := SweepResult{Bitrate: bitrate, Complexity: complexity, FrameDuration: frame}
+	enc, err := NewEncoder(sample_rate, channels, app)
+	if err != nil {
+		return res, err
+	}
+	if err := enc.SetBitrate(bitrate); err != nil {
+		return res, err
+	}
+	if err := enc.SetComplexity(complexity); err != nil {
+		return res, err
+	}
+	frameLen := FrameSamples(sample_rate, frame) * channels
+	frames := len(pcm) / frameLen
+	if frames == 0 {
+		return res, fmt.Errorf("sample shorter than one frame")
+	}
+	audio := SamplesDuration(sample_rate, frames*frameLen/channels).Seconds()
+
+	packets := make([][]byte, frames)
+	size := 0
+	start := time.Now()
+	for i := range packets {
+		data := make([]byte, RecommendedBufferSize)
+		n, err := enc.Encode(pcm[i*frameLen:(i+1)*frameLen], data)
+		if err != nil {
+			return res, err
+		}
+		packets[i] = data[:n]
+		size += n
+	}
+	res.EncodeSpeed = audio / time.Since(start).Seconds()
+	res.OutBitrate = float64(size*8) / audio
+
+	dec, err := NewDecoder(sample_rate, channels)
+	if err != nil {
+		return res, err
+	}
+	out := make([]int16, frames*frameLen)
+	start = time.Now()
+	for i, p := range packets {
+		if _, err := dec.Decode(p, out[i*frameLen:(i+1)*frameLen]); err != nil {
+			return res, err
+		}
+	}
+	res.DecodeSpeed = audio / time.Since(start).Seconds()
+
+	// The decoded audio lags behind the input by the encoder lookahead
+	lookahead, err := enc.Lookahead()
+	if err != nil {
+		return res, err
+	}
+	delay := lookahead * channels
+	res.SegmentalSNR = segmentalSNR(pcm[:len(out)-delay], out[delay:], FrameSamples(sample_rate, 20*time.Millisecond)*channels)
+	return res, nil
+}
+
+// segmentalSNR is the mean SNR in dB over segments of the given length,
+// ignoring silent segments and clamped to [-10, 60] dB per segment, as usual.
+func segmentalSNR(ref, out []int16, segment int) float64 {
+	var total float64
+	var n int
+	for start := 0; start+segment <= len(ref) && start+segment <= len(out); start += segment {
+		var sig, noise float64
+		for i := start; i < start+segment; i++ {
+			s := float64(ref[i])
+			d := s - float64(out[i])
+			sig += s * s
+			noise += d * d
+		}
+		if sig < float64(segment) {
+			continue
+		}
+		snr := 60.0
+		if noise > 0 {
+			snr = math.Min(60, math.Max(-10, 10*math.Log10(sig/noise)))
+		}
+		total += snr
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / float64(n)
+}
@@ -0,0 +1,64 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSegmentalSNR(t *testing.T) {
+	ref := make([]int16, 960)
+	for i := range ref {
+		ref[i] = int16(1000 * (i%2*2 - 1))
+	}
+	if snr := segmentalSNR(ref, ref, 480); snr != 60 {
+		t.Errorf("Expected 60 dB for identical signals, got %v", snr)
+	}
+	out := make([]int16, len(ref))
+	for i := range out {
+		out[i] = ref[i] / 10
+	}
+	// Noise is 0.9 times the signal: 0.9 dB
+	if snr := segmentalSNR(ref, out, 480); snr < 0.8 || snr > 1 {
+		t.Errorf("Expected about 0.9 dB, got %v", snr)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	pcm := make([]int16, SAMPLE_RATE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	results, err := Sweep(pcm, SAMPLE_RATE, 1, SweepOptions{
+		Application:    AppAudio,
+		Bitrates:       []int{12000, 64000},
+		Complexities:   []int{10},
+		FrameDurations: []time.Duration{20 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Error sweeping: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	low, high := results[0], results[1]
+	if low.OutBitrate >= high.OutBitrate {
+		t.Errorf("Expected higher output bitrate at 64 kbps: %v vs %v", low.OutBitrate, high.OutBitrate)
+	}
+	if low.SegmentalSNR >= high.SegmentalSNR {
+		t.Errorf("Expected better quality at 64 kbps: %v vs %v", low.SegmentalSNR, high.SegmentalSNR)
+	}
+	if low.EncodeSpeed <= 0 || low.DecodeSpeed <= 0 {
+		t.Errorf("Expected positive speeds: %+v", low)
+	}
+	best, ok := PickBitrate(results, high.SegmentalSNR)
+	if !ok || best.Bitrate != 64000 {
+		t.Errorf("Expected to pick 64 kbps, got %+v (%t)", best, ok)
+	}
+	if _, ok := PickBitrate(results, 1000); ok {
+		t.Errorf("Expected no result above 1000 dB")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+// Package timestretch changes the speed of audio without changing its pitch,
+// e.g. to play back a podcast at 1.5× speed. It uses WSOLA (waveform
+// similarity overlap-add), which works well for speech and reasonably well for
+// music at moderate ratios.
+//
+// This package is pure Go and works on decoded PCM, so it can be used on the
+// output of any opus.Decoder or opus.Stream.
+package timestretch
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// Length of the overlap-add frames, in milliseconds
+	frameMs = 20
+	// Maximum distance from the nominal position searched for the most
+	// similar frame, as a fraction of the frame length
+	tolerance = 4
+)
+
+// Stretch changes the speed of interleaved PCM audio by ratio, e.g. 1.5 plays
+// back 1.5× as fast and 0.5 at half speed, without changing the pitch. The
+// result is approximately len(pcm)/ratio samples long. Input shorter than
+// 20 ms is returned unchanged.
+func Stretch(pcm []int16, ratio float64, sample_rate int, channels int) ([]int16, error) {
+	if err := check(len(pcm), ratio, sample_rate, channels); err != nil {
+		return nil, err
+	}
+	in := make([]float64, len(pcm))
+	for i, s := range pcm {
+		in[i] = float64(s)
+	}
+	out := stretch(in, ratio, sample_rate, channels)
+	res := make([]int16, len(out))
+	for i, s := range out {
+		s = math.Round(s)
+		if s > math.MaxInt16 {
+			s = math.MaxInt16
+		} else if s < math.MinInt16 {
+			s = math.MinInt16
+		}
+		res[i] = int16(s)
+	}
+	return res, nil
+}
+
+// StretchFloat32 is the same as Stretch, but for float32 audio.
+func StretchFloat32(pcm []float32, ratio float64, sample_rate int, channels int) ([]float32, error) {
+	if err := check(len(pcm), ratio, sample_rate, channels); err != nil {
+		return nil, err
+	}
+	in := make([]float64, len(pcm))
+	for i, s := range pcm {
+		in[i] = float64(s)
+	}
+	out := stretch(in, ratio, sample_rate, channels)
+	res := make([]float32, len(out))
+	for i, s := range out {
+		res[i] = float32(s)
+	}
+	return res, nil
+}
+
+func check(length int, ratio float64, sample_rate int, channels int) error {
+	if !(ratio > 0) || math.IsInf(ratio, 1) {
+		return fmt.Errorf("timestretch: invalid ratio: %v", ratio)
+	}
+	if sample_rate <= 0 {
+		return fmt.Errorf("timestretch: invalid sample rate: %d", sample_rate)
+	}
+	if channels <= 0 {
+		return fmt.Errorf("timestretch: invalid number of channels: %d", channels)
+	}
+	if length%channels != 0 {
+		return fmt.Errorf("timestretch: input length %d is not a multiple of %d channels", length, channels)
+	}
+	return nil
+}
+
+// stretch does the actual WSOLA on interleaved audio.
+func stretch(in []float64, ratio float64, sample_rate int, channels int) []float64 {
+	n := len(in) / channels
+	// Frame length, even so it splits into two halves exactly
+	size := sample_rate * frameMs / 1000 &^ 1
+	if n < size || size < 4 {
+		return append([]float64(nil), in...)
+	}
+	hop := size / 2
+	delta := size / tolerance
+	// Periodic Hann window: two halves overlapping by hop sum to 1
+	window := make([]float64, size)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size))
+	}
+
+	target := int(math.Round(float64(n) / ratio))
+	outLen := target + size
+	out := make([]float64, outLen*channels)
+	weight := make([]float64, outLen)
+	add := func(from, to int) {
+		for i := 0; i < size; i++ {
+			for c := 0; c < channels; c++ {
+				out[(to+i)*channels+c] += window[i] * in[(from+i)*channels+c]
+			}
+			weight[to+i] += window[i]
+		}
+	}
+
+	add(0, 0)
+	prev := 0
+	end := size
+	for k := 1; ; k++ {
+		to := k * hop
+		if to+size > outLen {
+			break
+		}
+		nominal := int(math.Round(float64(k) * float64(hop) * ratio))
+		if nominal+size > n {
+			break
+		}
+		lo := nominal - delta
+		if lo < 0 {
+			lo = 0
+		}
+		hi := nominal + delta
+		if hi > n-size {
+			hi = n - size
+		}
+		// Pick the candidate whose first half best matches the natural
+		// continuation of the previous frame, i.e. the part it overlaps.
+		natural := prev + hop
+		best, bestScore := nominal, math.Inf(-1)
+		for cand := lo; cand <= hi; cand++ {
+			var corr, energy float64
+			for i := 0; i < hop*channels; i++ {
+				x := in[cand*channels+i]
+				corr += x * in[natural*channels+i]
+				energy += x * x
+			}
+			score := corr / math.Sqrt(energy+1e-9)
+			if score > bestScore {
+				best, bestScore = cand, score
+			}
+		}
+		add(best, to)
+		prev = best
+		end = to + size
+	}
+
+	if end > target {
+		end = target
+	}
+	out = out[:end*channels]
+	for i := 0; i < end; i++ {
+		if weight[i] > 1e-3 {
+			for c := 0; c < channels; c++ {
+				out[i*channels+c] /= weight[i]
+			}
+		}
+	}
+	return out
+}
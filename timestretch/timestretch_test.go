@@ -0,0 +1,85 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package timestretch
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(samples int, sample_rate int, freq float64) []int16 {
+	pcm := make([]int16, samples)
+	for i := range pcm {
+		pcm[i] = int16(10000 * math.Sin(2*math.Pi*freq*float64(i)/float64(sample_rate)))
+	}
+	return pcm
+}
+
+// crossings returns the number of zero crossings per sample.
+func crossings(pcm []int16) float64 {
+	n := 0
+	for i := 1; i < len(pcm); i++ {
+		if (pcm[i-1] < 0) != (pcm[i] < 0) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(pcm))
+}
+
+func TestStretch(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const G4 = 391.995
+	in := sine(SAMPLE_RATE, SAMPLE_RATE, G4)
+	for _, ratio := range []float64{0.5, 0.8, 1, 1.25, 1.5, 2} {
+		out, err := Stretch(in, ratio, SAMPLE_RATE, 1)
+		if err != nil {
+			t.Fatalf("Error stretching by %v: %v", ratio, err)
+		}
+		want := float64(len(in)) / ratio
+		// The tail of the input may be lost to frame alignment
+		if d := math.Abs(float64(len(out)) - want); d > SAMPLE_RATE*50/1000 {
+			t.Errorf("Ratio %v: expected about %v samples, got %d", ratio, want, len(out))
+		}
+		// Same pitch: same number of zero crossings per sample
+		if c, w := crossings(out), crossings(in); math.Abs(c-w)/w > 0.05 {
+			t.Errorf("Ratio %v: pitch changed: %v crossings per sample, expected %v", ratio, c, w)
+		}
+	}
+}
+
+func TestStretchStereo(t *testing.T) {
+	const SAMPLE_RATE = 16000
+	mono := sine(SAMPLE_RATE/2, SAMPLE_RATE, 440)
+	in := make([]float32, 2*len(mono))
+	for i, s := range mono {
+		in[2*i] = float32(s) / 32768
+		in[2*i+1] = -float32(s) / 32768
+	}
+	out, err := StretchFloat32(in, 1.5, SAMPLE_RATE, 2)
+	if err != nil {
+		t.Fatalf("Error stretching: %v", err)
+	}
+	if len(out)%2 != 0 {
+		t.Fatalf("Output not interleaved stereo: %d samples", len(out))
+	}
+	for i := 0; i < len(out); i += 2 {
+		if math.Abs(float64(out[i]+out[i+1])) > 1e-4 {
+			t.Fatalf("Channels mixed up at sample %d: %v, %v", i/2, out[i], out[i+1])
+		}
+	}
+}
+
+func TestStretchInvalid(t *testing.T) {
+	pcm := make([]int16, 100)
+	if _, err := Stretch(pcm, 0, 48000, 1); err == nil {
+		t.Errorf("Expected error for zero ratio")
+	}
+	if _, err := Stretch(pcm, math.NaN(), 48000, 1); err == nil {
+		t.Errorf("Expected error for NaN ratio")
+	}
+	if _, err := Stretch(pcm, 1, 48000, 3); err == nil {
+		t.Errorf("Expected error for odd length")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"sync"
+	"time"
+)
+
+// CallTiming sums up the time an encoder or decoder spent in libopus, see
+// EnableTiming. libopus is single threaded, so the time spent in a call is CPU
+// time on one core, unless the OS preempted the thread.
+type CallTiming struct {
+	// Number of encode or decode calls (including PLC and FEC)
+	Calls int64 `json:"calls"`
+	// Total and longest time spent in libopus
+	Total time.Duration `json:"total"`
+	Max   time.Duration `json:"max"`
+	// Duration of the audio encoded or decoded by those calls
+	Audio time.Duration `json:"audio"`
+}
+
+// Mean returns the average time per call.
+func (t CallTiming) Mean() time.Duration {
+	if t.Calls == 0 {
+		return 0
+	}
+	return t.Total / time.Duration(t.Calls)
+}
+
+// StreamsPerCore estimates how many real time streams with the same settings
+// one core can handle: the duration of the audio divided by the time it took
+// to process. Returns 0 if nothing was timed yet.
+func (t CallTiming) StreamsPerCore() float64 {
+	if t.Total == 0 {
+		return 0
+	}
+	return float64(t.Audio) / float64(t.Total)
+}
+
+type callTimer struct {
+	mu sync.Mutex
+	t  CallTiming
+}
+
+// start returns the start time of a call, or the zero time if timing is off.
+func (c *callTimer) start() time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func (c *callTimer) stop(start time.Time, sample_rate int, samples int) {
+	if c == nil {
+		return
+	}
+	d := time.Since(start)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t.Calls++
+	c.t.Total += d
+	if d > c.t.Max {
+		c.t.Max = d
+	}
+	if samples > 0 {
+		c.t.Audio += SamplesDuration(sample_rate, samples)
+	}
+}
+
+func (c *callTimer) get() CallTiming {
+	if c == nil {
+		return CallTiming{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// EnableTiming starts measuring the time every encode call spends in
+// libopus, for capacity planning at different complexity settings without a
+// profiler. Enabling it again resets the measurements. Timing costs two clock
+// reads per call.
+func (enc *Encoder) EnableTiming() error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	enc.timing = &callTimer{}
+	return nil
+}
+
+// DisableTiming stops timing and discards the measurements.
+func (enc *Encoder) DisableTiming() {
+	enc.timing = nil
+}
+
+// Timing returns the measurements since timing was enabled. Safe to call
+// while another goroutine is encoding.
+func (enc *Encoder) Timing() CallTiming {
+	return enc.timing.get()
+}
+
+// EnableTiming is the decoding counterpart of Encoder.EnableTiming.
+func (dec *Decoder) EnableTiming() error {
+	if dec.p == nil {
+		return dec.errUninitialized()
+	}
+	dec.timing = &callTimer{}
+	return nil
+}
+
+// DisableTiming stops timing and discards the measurements.
+func (dec *Decoder) DisableTiming() {
+	dec.timing = nil
+}
+
+// Timing returns the measurements since timing was enabled. Safe to call
+// while another goroutine is decoding.
+func (dec *Decoder) Timing() CallTiming {
+	return dec.timing.get()
+}
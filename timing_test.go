@@ -0,0 +1,71 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTiming(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const NUMBER_OF_FRAMES = 10
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	if timing := enc.Timing(); timing.Calls != 0 {
+		t.Errorf("Expected no timing before enabling, got %+v", timing)
+	}
+	if err := enc.EnableTiming(); err != nil {
+		t.Fatalf("Error enabling encoder timing: %v", err)
+	}
+	if err := dec.EnableTiming(); err != nil {
+		t.Fatalf("Error enabling decoder timing: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	for i := 0; i < NUMBER_OF_FRAMES; i++ {
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		if _, err := dec.Decode(data[:n], pcm); err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+	}
+	if err := dec.DecodePLC(pcm); err != nil {
+		t.Fatalf("Couldn't conceal: %v", err)
+	}
+	for name, timing := range map[string]CallTiming{"encoder": enc.Timing(), "decoder": dec.Timing()} {
+		calls := int64(NUMBER_OF_FRAMES)
+		if name == "decoder" {
+			calls++
+		}
+		if timing.Calls != calls {
+			t.Errorf("%s: expected %d calls, got %d", name, calls, timing.Calls)
+		}
+		if timing.Audio != time.Duration(calls)*20*time.Millisecond {
+			t.Errorf("%s: expected %v of audio, got %v", name, time.Duration(calls)*20*time.Millisecond, timing.Audio)
+		}
+		if timing.Total <= 0 || timing.Max > timing.Total || timing.Mean() > timing.Max {
+			t.Errorf("%s: inconsistent timing %+v", name, timing)
+		}
+		if timing.StreamsPerCore() <= 0 {
+			t.Errorf("%s: expected positive streams per core, got %f", name, timing.StreamsPerCore())
+		}
+	}
+	enc.DisableTiming()
+	if timing := enc.Timing(); timing.Calls != 0 {
+		t.Errorf("Expected no timing after disabling, got %+v", timing)
+	}
+}
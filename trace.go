@@ -0,0 +1,124 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceEntry records the decisions the encoder made for a single packet.
+type TraceEntry struct {
+	// Sequence number of the packet since tracing was enabled
+	Packet int64 `json:"packet"`
+	// Target bitrate at the time, see SetBitrate
+	Bitrate int `json:"bitrate"`
+	// Bandwidth and mode the encoder chose
+	Bandwidth Bandwidth `json:"bandwidth"`
+	Mode      Mode      `json:"mode"`
+	// Whether the packet is a DTX packet, i.e. the encoder decided there was
+	// nothing worth sending
+	DTX bool `json:"dtx"`
+	// Size of the packet in bytes
+	Size     int           `json:"size"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Packets this small carry no audio, only the TOC byte and maybe a frame
+// count: the encoder is in DTX.
+const maxDTXPacketSize = 2
+
+// encoderTrace is a ring buffer of trace entries.
+type encoderTrace struct {
+	mu      sync.Mutex
+	entries []TraceEntry
+	next    int64
+}
+
+func (t *encoderTrace) record(enc *Encoder, packet []byte) {
+	info, err := ParsePacket(packet)
+	if err != nil {
+		// Can't happen for packets from libopus
+		return
+	}
+	bitrate, _ := enc.Bitrate()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries[t.next%int64(len(t.entries))] = TraceEntry{
+		Packet:    t.next,
+		Bitrate:   bitrate,
+		Bandwidth: info.Bandwidth,
+		Mode:      info.Mode,
+		DTX:       len(packet) <= maxDTXPacketSize,
+		Size:      len(packet),
+		Duration:  info.Duration(),
+	}
+	t.next++
+}
+
+// EnableTrace starts recording the encoder's decisions for the last size
+// packets, to diagnose quality problems in production without re-encoding
+// offline. Enabling it again clears the trace. Tracing costs an extra cgo call
+// per packet.
+func (enc *Encoder) EnableTrace(size int) error {
+	if enc.p == nil {
+		return enc.errUninitialized()
+	}
+	if size <= 0 {
+		return fmt.Errorf("opus: invalid trace size: %d", size)
+	}
+	enc.trace = &encoderTrace{entries: make([]TraceEntry, size)}
+	return nil
+}
+
+// DisableTrace stops tracing and discards the trace.
+func (enc *Encoder) DisableTrace() {
+	enc.trace = nil
+}
+
+// Trace returns the recorded trace, oldest packet first. Safe to call while
+// another goroutine is encoding. Returns nil if tracing isn't enabled.
+func (enc *Encoder) Trace() []TraceEntry {
+	t := enc.trace
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	size := int64(len(t.entries))
+	start := int64(0)
+	if t.next > size {
+		start = t.next - size
+	}
+	res := make([]TraceEntry, 0, t.next-start)
+	for i := start; i < t.next; i++ {
+		res = append(res, t.entries[i%size])
+	}
+	return res
+}
+
+// WriteTraceCSV writes a trace as CSV, with a header row. Durations are in
+// milliseconds. For JSON, marshal the trace with encoding/json.
+func WriteTraceCSV(w io.Writer, trace []TraceEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"packet", "bitrate", "bandwidth", "mode", "dtx", "size", "duration_ms"})
+	for _, e := range trace {
+		cw.Write([]string{
+			strconv.FormatInt(e.Packet, 10),
+			strconv.Itoa(e.Bitrate),
+			e.Bandwidth.String(),
+			e.Mode.String(),
+			strconv.FormatBool(e.DTX),
+			strconv.Itoa(e.Size),
+			strconv.FormatFloat(float64(e.Duration)/float64(time.Millisecond), 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
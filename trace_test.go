@@ -0,0 +1,78 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoderTrace(t *testing.T) {
+	const G4 = 391.995
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	const TRACE_SIZE = 4
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	if trace := enc.Trace(); trace != nil {
+		t.Errorf("Expected no trace before enabling, got %v", trace)
+	}
+	if err := enc.EnableTrace(TRACE_SIZE); err != nil {
+		t.Fatalf("Error enabling trace: %v", err)
+	}
+	if err := enc.SetBitrate(24000); err != nil {
+		t.Fatalf("Error setting bitrate: %v", err)
+	}
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, G4)
+	data := make([]byte, 1000)
+	sizes := []int{}
+	for i := 0; i < 6; i++ {
+		n, err := enc.Encode(pcm, data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		sizes = append(sizes, n)
+	}
+	trace := enc.Trace()
+	if len(trace) != TRACE_SIZE {
+		t.Fatalf("Expected %d trace entries, got %d", TRACE_SIZE, len(trace))
+	}
+	for i, e := range trace {
+		if e.Packet != int64(i+2) {
+			t.Errorf("Expected packet %d, got %d", i+2, e.Packet)
+		}
+		if e.Size != sizes[i+2] {
+			t.Errorf("Packet %d: expected size %d, got %d", e.Packet, sizes[i+2], e.Size)
+		}
+		if e.Bitrate != 24000 {
+			t.Errorf("Packet %d: expected bitrate 24000, got %d", e.Packet, e.Bitrate)
+		}
+		if e.Duration != 20*time.Millisecond {
+			t.Errorf("Packet %d: expected 20ms, got %v", e.Packet, e.Duration)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTraceCSV(&buf, trace); err != nil {
+		t.Fatalf("Error writing CSV: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != TRACE_SIZE+1 {
+		t.Errorf("Expected %d CSV lines, got %d", TRACE_SIZE+1, lines)
+	}
+	if _, err := json.Marshal(trace); err != nil {
+		t.Errorf("Error marshalling trace: %v", err)
+	}
+
+	enc.DisableTrace()
+	if trace := enc.Trace(); trace != nil {
+		t.Errorf("Expected no trace after disabling, got %v", trace)
+	}
+}
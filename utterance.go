@@ -0,0 +1,207 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"math"
+	"time"
+)
+
+// ASRSampleRate is the sample rate of the audio in an Utterance: 16 kHz, what
+// most speech recognition services expect.
+const ASRSampleRate = 16000
+
+// Utterance is a stretch of speech cut out of a stream by an UtteranceChunker.
+type Utterance struct {
+	// Time of the first sample since the start of the stream
+	Start time.Duration
+	// Mono samples at ASRSampleRate
+	PCM []int16
+}
+
+// Duration returns the duration of the utterance.
+func (u Utterance) Duration() time.Duration {
+	return SamplesDuration(ASRSampleRate, len(u.PCM))
+}
+
+// Voice activity detection parameters, in frames of vadFrame.
+const (
+	vadFrame = ASRSampleRate / 50 // 20 ms
+	// Speech is this much louder than the noise floor...
+	vadThresholdDB = 9
+	// ...and louder than this, in dB relative to full scale
+	vadMinLevelDB = -50
+	// Initial noise floor, and how fast it follows rising noise
+	vadInitialFloorDB = -60
+	vadFloorRiseDB    = 0.1
+	// Silence kept after speech before the utterance is cut
+	vadHangover = 15
+	// Audio kept from before the detected start, to not clip the onset
+	vadPreroll = 10
+	// Utterances shorter than this are dropped as clicks
+	vadMinSpeech = 5
+	// Longer utterances are cut into several, as many services limit
+	// the length of a single request
+	vadMaxFrames = 30 * 50
+)
+
+// UtteranceChunker decodes an Opus stream to 16 kHz mono and cuts out the
+// utterances, as found by a simple energy based voice activity detector with
+// an adaptive noise floor. The result is ready to be sent to a speech
+// recognition service, one request per utterance.
+type UtteranceChunker struct {
+	dec      *Decoder
+	channels int
+	scratch  []int16
+	// Samples of the current frame
+	frame []int16
+	// Samples (at 16 kHz) of the stream before the current frame
+	pos     int64
+	floorDB float64
+	// Recent frames before any speech, for the pre-roll
+	preroll [][]int16
+	// Current utterance, if any
+	speaking bool
+	current  Utterance
+	// Frames of speech in the current utterance, and of silence at its end
+	speech  int
+	silence int
+	frames  int
+}
+
+// NewUtteranceChunker creates an UtteranceChunker for a stream with the given
+// number of channels.
+func NewUtteranceChunker(channels int) (*UtteranceChunker, error) {
+	dec, err := NewDecoder(ASRSampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	return &UtteranceChunker{
+		dec:      dec,
+		channels: channels,
+		scratch:  make([]int16, int(GranuleSamplesAt(maxPacketSamples48k, ASRSampleRate))*channels),
+		frame:    make([]int16, 0, vadFrame),
+		floorDB:  vadInitialFloorDB,
+	}, nil
+}
+
+// Packet decodes the next packet of the stream, or conceals it if data is nil
+// (lost), and returns the utterances it completed.
+func (c *UtteranceChunker) Packet(data []byte) ([]Utterance, error) {
+	var n int
+	var err error
+	if data == nil {
+		n, err = c.lost()
+	} else {
+		n, err = c.dec.Decode(data, c.scratch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var done []Utterance
+	for i := 0; i < n; i++ {
+		var v int
+		for _, s := range c.scratch[i*c.channels : (i+1)*c.channels] {
+			v += int(s)
+		}
+		c.frame = append(c.frame, int16(v/c.channels))
+		if len(c.frame) == vadFrame {
+			if u, ok := c.nextFrame(); ok {
+				done = append(done, u)
+			}
+		}
+	}
+	return done, nil
+}
+
+func (c *UtteranceChunker) lost() (int, error) {
+	n, err := c.dec.LastPacketDuration()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		n = FrameSamples(ASRSampleRate, defaultLostFrame)
+	}
+	if err := c.dec.DecodePLC(c.scratch[:n*c.channels]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Flush returns the utterance in progress at the end of the stream, if any.
+func (c *UtteranceChunker) Flush() []Utterance {
+	if !c.speaking {
+		return nil
+	}
+	// Include the partial frame as is
+	c.current.PCM = append(c.current.PCM, c.frame...)
+	c.frame = c.frame[:0]
+	u, ok := c.end()
+	if !ok {
+		return nil
+	}
+	return []Utterance{u}
+}
+
+// nextFrame runs the voice activity detector on a complete frame and returns
+// an utterance if it ended with this frame.
+func (c *UtteranceChunker) nextFrame() (Utterance, bool) {
+	start := c.pos
+	c.pos += int64(len(c.frame))
+	var sum float64
+	for _, s := range c.frame {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+	level := 10 * math.Log10(sum/float64(len(c.frame))+1e-10)
+	active := level > c.floorDB+vadThresholdDB && level > vadMinLevelDB
+	if level < c.floorDB {
+		c.floorDB = level
+	} else if !active {
+		c.floorDB += vadFloorRiseDB
+	}
+	frame := append([]int16(nil), c.frame...)
+	c.frame = c.frame[:0]
+
+	if !c.speaking {
+		if !active {
+			c.preroll = append(c.preroll, frame)
+			if len(c.preroll) > vadPreroll {
+				c.preroll = c.preroll[1:]
+			}
+			return Utterance{}, false
+		}
+		c.speaking = true
+		c.current = Utterance{Start: SamplesDuration(ASRSampleRate, int(start)-len(c.preroll)*vadFrame)}
+		for _, f := range c.preroll {
+			c.current.PCM = append(c.current.PCM, f...)
+		}
+		c.frames = len(c.preroll)
+		c.preroll = c.preroll[:0]
+	}
+	c.current.PCM = append(c.current.PCM, frame...)
+	c.frames++
+	if active {
+		c.speech++
+		c.silence = 0
+	} else {
+		c.silence++
+	}
+	if c.silence >= vadHangover || c.frames >= vadMaxFrames {
+		return c.end()
+	}
+	return Utterance{}, false
+}
+
+// end finishes the current utterance. Returns false if it was too short to
+// be speech.
+func (c *UtteranceChunker) end() (Utterance, bool) {
+	u := c.current
+	ok := c.speech >= vadMinSpeech
+	c.speaking = false
+	c.current = Utterance{}
+	c.speech, c.silence, c.frames = 0, 0, 0
+	return u, ok
+}
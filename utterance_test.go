@@ -0,0 +1,67 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestUtteranceChunker(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	// Quiet background, with bursts of loud noise as speech
+	pcm := make([]int16, 5*SAMPLE_RATE)
+	rng := rand.New(rand.NewSource(1))
+	for i := range pcm {
+		pcm[i] = int16(rng.Intn(41) - 20)
+	}
+	bursts := []struct{ start, end time.Duration }{
+		{500 * time.Millisecond, 1500 * time.Millisecond},
+		{2500 * time.Millisecond, 3200 * time.Millisecond},
+	}
+	for _, b := range bursts {
+		for i := FrameSamples(SAMPLE_RATE, b.start); i < FrameSamples(SAMPLE_RATE, b.end); i++ {
+			pcm[i] = int16(rng.Intn(16001) - 8000)
+		}
+	}
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	c, err := NewUtteranceChunker(1)
+	if err != nil {
+		t.Fatalf("Error creating utterance chunker: %v", err)
+	}
+	var utterances []Utterance
+	data := make([]byte, 1000)
+	for i := 0; i+FRAME_SIZE <= len(pcm); i += FRAME_SIZE {
+		n, err := enc.Encode(pcm[i:i+FRAME_SIZE], data)
+		if err != nil {
+			t.Fatalf("Couldn't encode data: %v", err)
+		}
+		us, err := c.Packet(data[:n])
+		if err != nil {
+			t.Fatalf("Couldn't decode data: %v", err)
+		}
+		utterances = append(utterances, us...)
+	}
+	utterances = append(utterances, c.Flush()...)
+	if len(utterances) != len(bursts) {
+		t.Fatalf("Expected %d utterances, got %d", len(bursts), len(utterances))
+	}
+	for i, u := range utterances {
+		b := bursts[i]
+		// Pre-roll before, hangover after, and some codec delay
+		if u.Start > b.start || u.Start < b.start-300*time.Millisecond {
+			t.Errorf("Utterance %d starts at %v, expected shortly before %v", i, u.Start, b.start)
+		}
+		end := u.Start + u.Duration()
+		if end < b.end || end > b.end+500*time.Millisecond {
+			t.Errorf("Utterance %d ends at %v, expected shortly after %v", i, end, b.end)
+		}
+	}
+}
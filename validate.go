@@ -0,0 +1,83 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Arguments are checked on the Go side before calling into libopus: libopus
+// reports most mistakes as a bare OPUS_BAD_ARG, which doesn't tell the user
+// what's wrong, and some (e.g. an uninitialized state) crash instead.
+
+// Sample rates supported by libopus, in Hz.
+var validSampleRates = []int{8000, 12000, 16000, 24000, 48000}
+
+// Frame durations supported by libopus.
+var validFrameDurations = []time.Duration{
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	40 * time.Millisecond,
+	60 * time.Millisecond,
+	80 * time.Millisecond,
+	100 * time.Millisecond,
+	120 * time.Millisecond,
+}
+
+func validateSampleRate(sample_rate int) error {
+	for _, r := range validSampleRates {
+		if r == sample_rate {
+			return nil
+		}
+	}
+	return fmt.Errorf("opus: unsupported sample rate %d Hz, must be one of %v", sample_rate, validSampleRates)
+}
+
+func validateChannels(channels int) error {
+	if channels != 1 && channels != 2 {
+		return fmt.Errorf("Number of channels must be 1 or 2: %d", channels)
+	}
+	return nil
+}
+
+// validateFrameSize checks that the number of samples per channel makes for a
+// frame duration libopus can encode. The error lists all valid options, with
+// the exact buffer lengths for this sample rate and channel count.
+func validateFrameSize(sample_rate int, channels int, samples int) error {
+	for _, d := range validFrameDurations {
+		if samples == FrameSamples(sample_rate, d) {
+			return nil
+		}
+	}
+	var durations, lengths string
+	for i, d := range validFrameDurations {
+		sep := ", "
+		if i == 0 {
+			sep = ""
+		} else if i == len(validFrameDurations)-1 {
+			sep = " or "
+		}
+		durations += sep + strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+		lengths += sep + strconv.Itoa(FrameSamples(sample_rate, d)*channels)
+	}
+	return fmt.Errorf("opus: invalid frame size: %d samples per channel (%.2f ms at %d Hz). "+
+		"Frames must be %s ms long, i.e. a buffer of %s samples for %d channel(s) at %d Hz",
+		samples, float64(samples)*1000/float64(sample_rate), sample_rate,
+		durations, lengths, channels, sample_rate)
+}
+
+// validateConcealSize checks the buffer size for FEC and PLC decoding, which
+// must be a multiple of 2.5 ms.
+func validateConcealSize(sample_rate int, samples int) error {
+	if (samples*400)%sample_rate != 0 {
+		return fmt.Errorf("opus: invalid buffer size for concealment: %d samples per channel (%.2f ms at %d Hz) is not a multiple of 2.5 ms",
+			samples, float64(samples)*1000/float64(sample_rate), sample_rate)
+	}
+	return nil
+}
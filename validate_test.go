@@ -0,0 +1,93 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFrameSize(t *testing.T) {
+	valid := map[int][]int{
+		8000:  {20, 40, 80, 160, 320, 480, 640, 800, 960},
+		48000: {120, 240, 480, 960, 1920, 2880, 3840, 4800, 5760},
+	}
+	for rate, sizes := range valid {
+		for _, n := range sizes {
+			if err := validateFrameSize(rate, 1, n); err != nil {
+				t.Errorf("Unexpected error for %d samples at %d Hz: %v", n, rate, err)
+			}
+		}
+	}
+	for _, n := range []int{0, 1, 100, 481, 6000} {
+		if err := validateFrameSize(48000, 1, n); err == nil {
+			t.Errorf("Expected error for %d samples at 48000 Hz", n)
+		}
+	}
+}
+
+func TestValidateSampleRate(t *testing.T) {
+	for _, rate := range []int{8000, 12000, 16000, 24000, 48000} {
+		if err := validateSampleRate(rate); err != nil {
+			t.Errorf("Unexpected error for %d Hz: %v", rate, err)
+		}
+	}
+	for _, rate := range []int{0, 44100, 96000} {
+		if err := validateSampleRate(rate); err == nil {
+			t.Errorf("Expected error for %d Hz", rate)
+		}
+	}
+}
+
+func TestEncodeInvalidFrameSize(t *testing.T) {
+	enc, err := NewEncoder(48000, 2, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	data := make([]byte, 1000)
+	// 10 ms of mono data, but this is a stereo encoder: 5 ms per channel,
+	// which is fine.
+	if _, err := enc.Encode(make([]int16, 480), data); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := enc.Encode(make([]int16, 1000), data); err == nil || err == ErrBadArg {
+		t.Errorf("Expected descriptive frame size error, got: %v", err)
+	}
+	if _, err := enc.EncodeFloat32(make([]float32, 1000), data); err == nil || err == ErrBadArg {
+		t.Errorf("Expected descriptive frame size error, got: %v", err)
+	}
+}
+
+func TestUninitializedCtl(t *testing.T) {
+	var enc Encoder
+	if err := enc.SetBitrate(12000); err != errEncUninitialized {
+		t.Errorf("Expected \"unitialized encoder\" error: %v", err)
+	}
+	if _, err := enc.Complexity(); err != errEncUninitialized {
+		t.Errorf("Expected \"unitialized encoder\" error: %v", err)
+	}
+	var dec Decoder
+	if _, err := dec.LastPacketDuration(); err != errDecUninitialized {
+		t.Errorf("Expected \"unitialized decoder\" error: %v", err)
+	}
+}
+
+func TestFrameSizeErrorMessage(t *testing.T) {
+	err := validateFrameSize(16000, 2, 100)
+	if err == nil {
+		t.Fatal("Expected error for 100 samples at 16000 Hz")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"100 samples per channel",
+		"6.25 ms",
+		"2.5, 5, 10, 20, 40, 60, 80, 100 or 120 ms",
+		"80, 160, 320, 640, 1280, 1920, 2560, 3200 or 3840 samples for 2 channel(s)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Frame size error %q doesn't contain %q", msg, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// WaveformBucket is the level of one stretch of audio in a waveform overview,
+// over all channels, relative to full scale.
+type WaveformBucket struct {
+	Peak float64
+	RMS  float64
+}
+
+// Waveforms only need the envelope, so decode at the lowest rate: that's much
+// cheaper than 48 kHz, and leaves fewer samples to measure.
+const (
+	waveformSampleRate = 8000
+	// Levels are kept per block of 10 ms until the length is known
+	waveformBlock = waveformSampleRate / 100
+)
+
+// Waveform computes an overview of the levels of a whole Ogg Opus file, split
+// into the given number of equally long buckets, e.g. one per pixel of a
+// waveform display. The file is decoded at 8 kHz with the package's own Ogg
+// demuxer, so it doesn't need libopusfile, nor a seekable input. Memory use
+// grows with the length of the file by about 20 bytes per 10 ms.
+func Waveform(r io.Reader, buckets int) ([]WaveformBucket, error) {
+	if buckets <= 0 {
+		return nil, fmt.Errorf("opus: invalid number of waveform buckets: %d", buckets)
+	}
+	p := newPushDecoder(waveformSampleRate)
+	var blocks []WaveformBucket
+	var peak, sum float64
+	var n int
+	buf := make([]byte, 32*1024)
+	for {
+		size, err := r.Read(buf)
+		frames, ferr := p.Feed(buf[:size])
+		if ferr != nil {
+			return nil, ferr
+		}
+		for _, f := range frames {
+			channels := p.channels
+			for i, s := range f.PCM {
+				v := float64(s) / 32768
+				sum += v * v
+				if v = math.Abs(v); v > peak {
+					peak = v
+				}
+				if (i+1)%channels != 0 {
+					continue
+				}
+				n++
+				if n == waveformBlock {
+					blocks = append(blocks, WaveformBucket{Peak: peak, RMS: sum / float64(n*channels)})
+					peak, sum, n = 0, 0, 0
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if n > 0 {
+		blocks = append(blocks, WaveformBucket{Peak: peak, RMS: sum / float64(n*p.channels)})
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("opus: no Opus audio in stream")
+	}
+	return bucketize(blocks, buckets), nil
+}
+
+// bucketize merges blocks holding a peak and a mean square each into buckets
+// holding a peak and an RMS. With more buckets than blocks, blocks are
+// repeated.
+func bucketize(blocks []WaveformBucket, buckets int) []WaveformBucket {
+	out := make([]WaveformBucket, buckets)
+	for i := range out {
+		start := i * len(blocks) / buckets
+		end := (i + 1) * len(blocks) / buckets
+		if end <= start {
+			end = start + 1
+		}
+		var sum float64
+		for _, b := range blocks[start:end] {
+			sum += b.RMS
+			if b.Peak > out[i].Peak {
+				out[i].Peak = b.Peak
+			}
+		}
+		out[i].RMS = math.Sqrt(sum / float64(end-start))
+	}
+	return out
+}
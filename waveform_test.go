@@ -0,0 +1,45 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWaveform(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/speech_8.opus")
+	if err != nil {
+		t.Fatalf("Error reading test file: %v", err)
+	}
+	for _, buckets := range []int{1, 50, 10000} {
+		w, err := Waveform(bytes.NewReader(data), buckets)
+		if err != nil {
+			t.Fatalf("Error computing waveform: %v", err)
+		}
+		if len(w) != buckets {
+			t.Fatalf("Expected %d buckets, got %d", buckets, len(w))
+		}
+		var loud int
+		for i, b := range w {
+			if b.RMS > b.Peak || b.Peak > 1 {
+				t.Errorf("Bucket %d of %d: implausible levels %+v", i, buckets, b)
+			}
+			if b.Peak > 0.05 {
+				loud++
+			}
+		}
+		if loud == 0 {
+			t.Errorf("Expected speech in %d buckets, got none", buckets)
+		}
+	}
+	if _, err := Waveform(bytes.NewReader(data), 0); err == nil {
+		t.Errorf("Expected error for 0 buckets")
+	}
+	if _, err := Waveform(bytes.NewReader([]byte("not an Ogg file")), 10); err == nil {
+		t.Errorf("Expected error for input without Opus audio")
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// lockedThread runs functions one at a time on a dedicated, locked OS thread.
+type lockedThread struct {
+	mu     sync.Mutex
+	jobs   chan func()
+	done   chan error
+	closed bool
+}
+
+func newLockedThread() *lockedThread {
+	t := &lockedThread{
+		jobs: make(chan func()),
+		done: make(chan error),
+	}
+	go t.loop()
+	return t
+}
+
+func (t *lockedThread) loop() {
+	// Never unlocked: the thread is discarded when this goroutine exits.
+	runtime.LockOSThread()
+	for f := range t.jobs {
+		t.done <- runJob(f)
+	}
+}
+
+// runJob runs f, returning a panic as an error rather than letting it kill the
+// loop, which would leave run waiting forever.
+func runJob(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("opus: panic in worker: %v", r)
+		}
+	}()
+	f()
+	return nil
+}
+
+func (t *lockedThread) run(f func()) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrWorkerClosed
+	}
+	t.jobs <- f
+	return <-t.done
+}
+
+func (t *lockedThread) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.jobs)
+	}
+}
+
+// EncoderWorker performs all cgo calls of an encoder on a dedicated, locked
+// OS thread. Under heavy concurrent load this avoids the scheduler migrating
+// goroutines between threads around every cgo call, at the cost of a channel
+// round trip per call. Calls from multiple goroutines are serialized.
+//
+// Close the worker when done to release the thread.
+type EncoderWorker struct {
+	enc    *Encoder
+	thread *lockedThread
+}
+
+// NewEncoderWorker starts a worker thread for the encoder. The encoder must
+// not be used directly anymore while the worker is running.
+func NewEncoderWorker(enc *Encoder) *EncoderWorker {
+	return &EncoderWorker{
+		enc:    enc,
+		thread: newLockedThread(),
+	}
+}
+
+// Encode is Encoder.Encode, run on the worker thread.
+func (w *EncoderWorker) Encode(pcm []int16, data []byte) (n int, err error) {
+	if rerr := w.thread.run(func() { n, err = w.enc.Encode(pcm, data) }); rerr != nil {
+		return 0, rerr
+	}
+	return
+}
+
+// EncodeFloat32 is Encoder.EncodeFloat32, run on the worker thread.
+func (w *EncoderWorker) EncodeFloat32(pcm []float32, data []byte) (n int, err error) {
+	if rerr := w.thread.run(func() { n, err = w.enc.EncodeFloat32(pcm, data) }); rerr != nil {
+		return 0, rerr
+	}
+	return
+}
+
+// Do runs an arbitrary function with the encoder on the worker thread, e.g.
+// to change its settings. A panic in f is returned as an error.
+func (w *EncoderWorker) Do(f func(enc *Encoder)) error {
+	return w.thread.run(func() { f(w.enc) })
+}
+
+// Close stops the worker thread. The encoder itself remains valid and may be
+// used directly again afterwards.
+func (w *EncoderWorker) Close() {
+	w.thread.close()
+}
+
+// DecoderWorker is the decoding counterpart of EncoderWorker.
+type DecoderWorker struct {
+	dec    *Decoder
+	thread *lockedThread
+}
+
+// NewDecoderWorker starts a worker thread for the decoder. The decoder must
+// not be used directly anymore while the worker is running.
+func NewDecoderWorker(dec *Decoder) *DecoderWorker {
+	return &DecoderWorker{
+		dec:    dec,
+		thread: newLockedThread(),
+	}
+}
+
+// Decode is Decoder.Decode, run on the worker thread.
+func (w *DecoderWorker) Decode(data []byte, pcm []int16) (n int, err error) {
+	if rerr := w.thread.run(func() { n, err = w.dec.Decode(data, pcm) }); rerr != nil {
+		return 0, rerr
+	}
+	return
+}
+
+// DecodeFloat32 is Decoder.DecodeFloat32, run on the worker thread.
+func (w *DecoderWorker) DecodeFloat32(data []byte, pcm []float32) (n int, err error) {
+	if rerr := w.thread.run(func() { n, err = w.dec.DecodeFloat32(data, pcm) }); rerr != nil {
+		return 0, rerr
+	}
+	return
+}
+
+// Do runs an arbitrary function with the decoder on the worker thread, e.g.
+// for PLC or FEC decoding. A panic in f is returned as an error.
+func (w *DecoderWorker) Do(f func(dec *Decoder)) error {
+	return w.thread.run(func() { f(w.dec) })
+}
+
+// Close stops the worker thread. The decoder itself remains valid and may be
+// used directly again afterwards.
+func (w *DecoderWorker) Close() {
+	w.thread.close()
+}
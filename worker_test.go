@@ -0,0 +1,89 @@
+// Copyright © Go Opus Authors (see AUTHORS file)
+//
+// License for use of this code is detailed in the LICENSE file
+
+package opus
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWorkers(t *testing.T) {
+	const SAMPLE_RATE = 48000
+	const FRAME_SIZE = SAMPLE_RATE * 20 / 1000
+	enc, err := NewEncoder(SAMPLE_RATE, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	dec, err := NewDecoder(SAMPLE_RATE, 1)
+	if err != nil || dec == nil {
+		t.Fatalf("Error creating new decoder: %v", err)
+	}
+	ew := NewEncoderWorker(enc)
+	dw := NewDecoderWorker(dec)
+
+	if err := ew.Do(func(enc *Encoder) {
+		if err := enc.SetBitrate(24000); err != nil {
+			t.Errorf("Error setting bitrate: %v", err)
+		}
+	}); err != nil {
+		t.Fatalf("Error running on encoder worker: %v", err)
+	}
+
+	pcm := make([]int16, FRAME_SIZE)
+	addSine(pcm, SAMPLE_RATE, 440)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := make([]byte, 1000)
+			out := make([]int16, FRAME_SIZE)
+			for j := 0; j < 10; j++ {
+				n, err := ew.Encode(pcm, data)
+				if err != nil {
+					t.Errorf("Couldn't encode data: %v", err)
+					return
+				}
+				n, err = dw.Decode(data[:n], out)
+				if err != nil {
+					t.Errorf("Couldn't decode data: %v", err)
+					return
+				}
+				if n != FRAME_SIZE {
+					t.Errorf("Length mismatch: %d samples in, %d out", FRAME_SIZE, n)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	ew.Close()
+	dw.Close()
+	if _, err := ew.Encode(pcm, make([]byte, 1000)); err != ErrWorkerClosed {
+		t.Errorf("Expected worker closed error, got: %v", err)
+	}
+	if _, err := dw.Decode([]byte{0}, pcm); err != ErrWorkerClosed {
+		t.Errorf("Expected worker closed error, got: %v", err)
+	}
+	// Closing twice is harmless
+	ew.Close()
+}
+
+func TestWorkerPanic(t *testing.T) {
+	enc, err := NewEncoder(48000, 1, AppVoIP)
+	if err != nil || enc == nil {
+		t.Fatalf("Error creating new encoder: %v", err)
+	}
+	w := NewEncoderWorker(enc)
+	defer w.Close()
+	if err := w.Do(func(enc *Encoder) { panic("boom") }); err == nil {
+		t.Errorf("Expected error for panicking job")
+	}
+	// The worker survives and keeps serving calls
+	if err := w.Do(func(enc *Encoder) {}); err != nil {
+		t.Errorf("Worker unusable after panic: %v", err)
+	}
+}